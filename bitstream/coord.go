@@ -0,0 +1,160 @@
+package bitstream
+
+import "math"
+
+// Sizes of the coordinate encodings.
+const (
+	CoordIntegerBits    = 14
+	CoordFractionalBits = 5
+	CoordResolution     = 1.0 / (1 << CoordFractionalBits)
+
+	// CoordIntegerBitsMP is the size of the integer part of multiplayer
+	// coordinates that are within the bounds of the map.
+	CoordIntegerBitsMP              = 11
+	CoordFractionalBitsLowPrecision = 3
+	CoordResolutionLowPrecision     = 1.0 / (1 << CoordFractionalBitsLowPrecision)
+
+	// NormalFractionalBits is the size of the components of unit vectors.
+	NormalFractionalBits = 11
+	NormalResolution     = 1.0 / (1<<NormalFractionalBits - 1)
+)
+
+// CoordType is the precision of a multiplayer or cell coordinate.
+type CoordType int
+
+const (
+	// CoordNormal coordinates have a fraction of 5 bits.
+	CoordNormal CoordType = iota
+	// CoordIntegral coordinates have no fraction.
+	CoordIntegral
+	// CoordLowPrecision coordinates have a fraction of 3 bits.
+	CoordLowPrecision
+)
+
+// fraction reads the fractional part of a coordinate of type t.
+func (r *Reader) fraction(t CoordType) float32 {
+	if t == CoordLowPrecision {
+		return float32(r.ReadBits(CoordFractionalBitsLowPrecision)) * CoordResolutionLowPrecision
+	}
+	return float32(r.ReadBits(CoordFractionalBits)) * CoordResolution
+}
+
+// ReadBitCoord reads a world coordinate: flags for the presence of an integer
+// and a fractional part, a sign, an integer part of 14 bits and a fraction
+// of 5 bits.
+func (r *Reader) ReadBitCoord() float32 {
+	hasInt, hasFract := r.ReadBit(), r.ReadBit()
+	if !hasInt && !hasFract {
+		return 0
+	}
+	negative := r.ReadBit()
+	var v float32
+	if hasInt {
+		v = float32(r.ReadBits(CoordIntegerBits) + 1)
+	}
+	if hasFract {
+		v += float32(r.ReadBits(CoordFractionalBits)) * CoordResolution
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// ReadBitCoordMP reads a multiplayer world coordinate of type t, which uses
+// fewer integer bits for coordinates within the bounds of the map.
+func (r *Reader) ReadBitCoordMP(t CoordType) float32 {
+	intBits := CoordIntegerBits
+	if r.ReadBit() {
+		intBits = CoordIntegerBitsMP
+	}
+
+	var v float32
+	var negative bool
+	if t == CoordIntegral {
+		if r.ReadBit() {
+			negative = r.ReadBit()
+			v = float32(r.ReadBits(intBits) + 1)
+		}
+	} else {
+		hasInt := r.ReadBit()
+		negative = r.ReadBit()
+		if hasInt {
+			v = float32(r.ReadBits(intBits) + 1)
+		}
+		v += r.fraction(t)
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// ReadBitCellCoord reads a coordinate relative to its cell in the world
+// grid, of type t with an integer part of bits bits.
+func (r *Reader) ReadBitCellCoord(bits int, t CoordType) float32 {
+	v := float32(r.ReadBits(bits))
+	if t != CoordIntegral {
+		v += r.fraction(t)
+	}
+	return v
+}
+
+// ReadBitNormal reads a component of a unit vector: a sign and a magnitude
+// of 11 bits.
+func (r *Reader) ReadBitNormal() float32 {
+	negative := r.ReadBit()
+	v := float32(r.ReadBits(NormalFractionalBits)) * NormalResolution
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// ReadBitVec3Coord reads a vector of ReadBitCoord components, each preceded
+// by a flag for whether it is non-zero.
+func (r *Reader) ReadBitVec3Coord() [3]float32 {
+	var present [3]bool
+	for i := range present {
+		present[i] = r.ReadBit()
+	}
+	var v [3]float32
+	for i := range v {
+		if present[i] {
+			v[i] = r.ReadBitCoord()
+		}
+	}
+	return v
+}
+
+// ReadBitVec3Normal reads a unit vector. Only its x and y components are
+// stored, the z component is derived from them and a sign.
+func (r *Reader) ReadBitVec3Normal() [3]float32 {
+	hasX, hasY := r.ReadBit(), r.ReadBit()
+	var v [3]float32
+	if hasX {
+		v[0] = r.ReadBitNormal()
+	}
+	if hasY {
+		v[1] = r.ReadBitNormal()
+	}
+	negativeZ := r.ReadBit()
+	if xy := v[0]*v[0] + v[1]*v[1]; xy < 1 {
+		v[2] = float32(math.Sqrt(float64(1 - xy)))
+	}
+	if negativeZ {
+		v[2] = -v[2]
+	}
+	return v
+}
+
+// ReadBitAngle reads an angle in degrees, quantized to bits bits.
+func (r *Reader) ReadBitAngle(bits int) float32 {
+	return float32(r.ReadBits(bits)) * (360 / float32(uint64(1)<<uint(bits)))
+}
+
+// ReadQuantizedFloat reads a float in [low, high] quantized to bits bits.
+func (r *Reader) ReadQuantizedFloat(bits int, low, high float32) float32 {
+	v := float32(r.ReadBits(bits)) / float32(uint64(1)<<uint(bits)-1)
+	return low + (high-low)*v
+}
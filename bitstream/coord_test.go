@@ -0,0 +1,163 @@
+package bitstream
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReadBitCoord(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float32
+		bits     int
+	}{
+		{pack(0, 1, 0, 1), 0, 2},
+		// integer 1 is stored as 0
+		{pack(1, 1, 0, 1, 0, 1, 0, 14), 1, 17},
+		{pack(1, 1, 1, 1, 0, 1, 99, 14, 16, 5), 100.5, 22},
+		{pack(0, 1, 1, 1, 1, 1, 8, 5), -0.25, 8},
+		{pack(1, 1, 0, 1, 1, 1, 16383, 14), -16384, 17},
+	}
+	for _, tt := range tests {
+		r := NewReader(tt.data)
+		if v := r.ReadBitCoord(); v != tt.expected || r.Pos() != tt.bits {
+			t.Errorf("ReadBitCoord(%x): expected %v in %d bits, got %v in %d", tt.data, tt.expected, tt.bits, v, r.Pos())
+		}
+	}
+}
+
+func TestReadBitCoordMP(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      CoordType
+		data     []byte
+		expected float32
+		bits     int
+	}{
+		{"integral zero", CoordIntegral, pack(1, 1, 0, 1), 0, 2},
+		{"integral in bounds", CoordIntegral, pack(1, 1, 1, 1, 1, 1, 41, 11), -42, 14},
+		{"integral out of bounds", CoordIntegral, pack(0, 1, 1, 1, 0, 1, 9999, 14), 10000, 17},
+		{"normal fraction only", CoordNormal, pack(1, 1, 0, 1, 0, 1, 4, 5), 0.125, 8},
+		{"normal", CoordNormal, pack(1, 1, 1, 1, 1, 1, 9, 11, 31, 5), -10.96875, 19},
+		{"normal out of bounds", CoordNormal, pack(0, 1, 1, 1, 0, 1, 2047, 14, 1, 5), 2048.03125, 22},
+		{"low precision", CoordLowPrecision, pack(1, 1, 1, 1, 0, 1, 0, 11, 3, 3), 1.375, 17},
+		{"low precision negative", CoordLowPrecision, pack(1, 1, 0, 1, 1, 1, 7, 3), -0.875, 6},
+	}
+	for _, tt := range tests {
+		r := NewReader(tt.data)
+		if v := r.ReadBitCoordMP(tt.typ); v != tt.expected || r.Pos() != tt.bits {
+			t.Errorf("%s: expected %v in %d bits, got %v in %d", tt.name, tt.expected, tt.bits, v, r.Pos())
+		}
+	}
+}
+
+func TestReadBitCellCoord(t *testing.T) {
+	tests := []struct {
+		typ      CoordType
+		data     []byte
+		expected float32
+		bits     int
+	}{
+		{CoordIntegral, pack(37, 15), 37, 15},
+		{CoordNormal, pack(37, 15, 16, 5), 37.5, 20},
+		{CoordLowPrecision, pack(37, 15, 6, 3), 37.75, 18},
+	}
+	for _, tt := range tests {
+		r := NewReader(tt.data)
+		if v := r.ReadBitCellCoord(15, tt.typ); v != tt.expected || r.Pos() != tt.bits {
+			t.Errorf("type %d: expected %v in %d bits, got %v in %d", tt.typ, tt.expected, tt.bits, v, r.Pos())
+		}
+	}
+}
+
+func TestReadBitNormal(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float32
+	}{
+		{pack(0, 1, 0, 11), 0},
+		{pack(0, 1, 2047, 11), 1},
+		{pack(1, 1, 2047, 11), -1},
+		{pack(1, 1, 1023, 11), -1023.0 / 2047},
+	}
+	for _, tt := range tests {
+		if v := NewReader(tt.data).ReadBitNormal(); v != tt.expected {
+			t.Errorf("ReadBitNormal(%x): expected %v, got %v", tt.data, tt.expected, v)
+		}
+	}
+}
+
+func TestReadBitVec3(t *testing.T) {
+	coord := NewReader(pack(1, 1, 0, 1, 1, 1, 1, 1, 0, 1, 0, 1, 4, 14, 0, 1, 1, 1, 1, 1, 16, 5))
+	if v := coord.ReadBitVec3Coord(); v != [3]float32{5, 0, -0.5} || coord.Pos() != 28 {
+		t.Errorf("expected [5 0 -0.5] in 28 bits, got %v in %d", v, coord.Pos())
+	}
+
+	tests := []struct {
+		data     []byte
+		expected [3]float32
+	}{
+		{pack(0, 1, 0, 1, 0, 1), [3]float32{0, 0, 1}},
+		{pack(0, 1, 0, 1, 1, 1), [3]float32{0, 0, -1}},
+		{pack(1, 1, 0, 1, 1, 1, 2047, 11, 0, 1), [3]float32{-1, 0, 0}},
+		{pack(1, 1, 1, 1, 0, 1, 0, 11, 1, 1, 0, 11, 1, 1), [3]float32{0, 0, -1}},
+	}
+	for _, tt := range tests {
+		if v := NewReader(tt.data).ReadBitVec3Normal(); v != tt.expected {
+			t.Errorf("ReadBitVec3Normal(%x): expected %v, got %v", tt.data, tt.expected, v)
+		}
+	}
+
+	diag := NewReader(pack(1, 1, 1, 1, 0, 1, 1024, 11, 0, 1, 1024, 11, 0, 1)).ReadBitVec3Normal()
+	length := math.Sqrt(float64(diag[0]*diag[0] + diag[1]*diag[1] + diag[2]*diag[2]))
+	if math.Abs(length-1) > 1e-6 || diag[2] <= 0 {
+		t.Errorf("expected a unit vector with positive z, got %v", diag)
+	}
+}
+
+func TestReadFloats(t *testing.T) {
+	if v := NewReader(pack(uint64(math.Float32bits(-3.25)), 32)).ReadFloat(); v != -3.25 {
+		t.Errorf("expected -3.25, got %v", v)
+	}
+	if v := NewReader(pack(64, 8)).ReadBitAngle(8); v != 90 {
+		t.Errorf("expected 90, got %v", v)
+	}
+	if v := NewReader(pack(0xffff, 16)).ReadBitAngle(16); v != 360-360.0/65536 {
+		t.Errorf("expected %v, got %v", 360-360.0/65536, v)
+	}
+	tests := []struct {
+		v        uint64
+		expected float32
+	}{
+		{0, -100},
+		{1023, 100},
+		{341, -100 + 200*341.0/1023},
+	}
+	for _, tt := range tests {
+		if v := NewReader(pack(tt.v, 10)).ReadQuantizedFloat(10, -100, 100); math.Abs(float64(v-tt.expected)) > 1e-4 {
+			t.Errorf("ReadQuantizedFloat(%d): expected %v, got %v", tt.v, tt.expected, v)
+		}
+	}
+}
+
+func BenchmarkReadBitCoordMP(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() >= 20 {
+			r.ReadBitCoordMP(CoordNormal)
+		}
+	}
+}
+
+func BenchmarkReadBitVec3Normal(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() >= 27 {
+			r.ReadBitVec3Normal()
+		}
+	}
+}
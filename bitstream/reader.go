@@ -0,0 +1,249 @@
+// Package bitstream reads the bit packed data of Source engine demos, such
+// as entity updates and string table entries. Its primitives match those of
+// the engine's bf_read, bit for bit.
+//
+// Reads past the end of the data, or of an invalid number of bits, return
+// zero values and set an error that is reported by Err, so that a decoder
+// can read a whole update and check for an error once.
+package bitstream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrBitCount is the error of a read of a number of bits out of the range
+// the method supports.
+var ErrBitCount = errors.New("bitstream: invalid bit count")
+
+// Reader reads bits from a byte slice, least significant bit first.
+type Reader struct {
+	data []byte
+	pos  int // in bits
+	err  error
+}
+
+// NewReader returns a Reader reading data.
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Reset makes r read data from the start, clearing its error.
+func (r *Reader) Reset(data []byte) {
+	*r = Reader{data: data}
+}
+
+// Err returns io.ErrUnexpectedEOF if a read went past the end of the data,
+// or ErrBitCount if a read was of an invalid number of bits. Only the first
+// error is kept.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Pos returns the number of bits read.
+func (r *Reader) Pos() int {
+	return r.pos
+}
+
+// Len returns the number of bits left to read.
+func (r *Reader) Len() int {
+	return len(r.data)*8 - r.pos
+}
+
+// Seek moves to bit pos of the data.
+func (r *Reader) Seek(pos int) {
+	if pos < 0 || pos > len(r.data)*8 {
+		r.overflow()
+		return
+	}
+	r.pos = pos
+}
+
+// Skip skips n bits.
+func (r *Reader) Skip(n int) {
+	r.Seek(r.pos + n)
+}
+
+func (r *Reader) overflow() {
+	r.fail(io.ErrUnexpectedEOF)
+}
+
+// fail moves to the end of the data, so that all further reads fail too.
+func (r *Reader) fail(err error) {
+	r.pos = len(r.data) * 8
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// ReadBit reads a single bit.
+func (r *Reader) ReadBit() bool {
+	i := r.pos >> 3
+	if i >= len(r.data) {
+		r.overflow()
+		return false
+	}
+	b := r.data[i]>>(r.pos&7)&1 != 0
+	r.pos++
+	return b
+}
+
+// ReadBits reads an unsigned integer of n bits, n <= 32.
+func (r *Reader) ReadBits(n int) uint32 {
+	if n <= 0 || n > 32 {
+		if n != 0 {
+			r.fail(ErrBitCount)
+		}
+		return 0
+	}
+	if n > r.Len() {
+		r.overflow()
+		return 0
+	}
+	i := r.pos >> 3
+	var v uint64
+	if i+8 <= len(r.data) {
+		v = binary.LittleEndian.Uint64(r.data[i:])
+	} else {
+		// near the end, gather the remaining bytes one at a time
+		for j := len(r.data) - 1; j >= i; j-- {
+			v = v<<8 | uint64(r.data[j])
+		}
+	}
+	v >>= uint(r.pos & 7)
+	r.pos += n
+	return uint32(v & (1<<uint(n) - 1))
+}
+
+// ReadSignedBits reads a two's complement integer of n bits, n <= 32.
+func (r *Reader) ReadSignedBits(n int) int32 {
+	if n <= 0 || n > 32 {
+		if n != 0 {
+			r.fail(ErrBitCount)
+		}
+		return 0
+	}
+	v := r.ReadBits(n)
+	shift := uint(32 - n)
+	return int32(v<<shift) >> shift
+}
+
+// ReadBits64 reads an unsigned integer of n bits, n <= 64.
+func (r *Reader) ReadBits64(n int) uint64 {
+	if n < 0 || n > 64 {
+		r.fail(ErrBitCount)
+		return 0
+	}
+	if n <= 32 {
+		return uint64(r.ReadBits(n))
+	}
+	lo := uint64(r.ReadBits(32))
+	return lo | uint64(r.ReadBits(n-32))<<32
+}
+
+// ReadBytes reads n bytes, which need not be byte aligned.
+func (r *Reader) ReadBytes(n int) []byte {
+	if n < 0 || n*8 > r.Len() {
+		r.overflow()
+		return nil
+	}
+	b := make([]byte, n)
+	if r.pos&7 == 0 {
+		copy(b, r.data[r.pos>>3:])
+		r.pos += n * 8
+		return b
+	}
+	for i := range b {
+		b[i] = byte(r.ReadBits(8))
+	}
+	return b
+}
+
+// ReadString reads a null terminated string. The terminator is consumed but
+// not returned.
+func (r *Reader) ReadString() string {
+	var b []byte
+	for {
+		c := byte(r.ReadBits(8))
+		if c == 0 || r.err != nil {
+			return string(b)
+		}
+		b = append(b, c)
+	}
+}
+
+// ReadStringMax reads a null terminated string of at most max bytes, like
+// ReadString. A string that is not terminated within max bytes is cut off
+// there, and the reader stops after the last byte read.
+func (r *Reader) ReadStringMax(max int) string {
+	var b []byte
+	for len(b) < max {
+		c := byte(r.ReadBits(8))
+		if c == 0 || r.err != nil {
+			break
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// ReadUBitVar reads an unsigned integer of 4, 8, 12 or 32 bits, whose size
+// is given by 2 bits after the low nibble. It is the encoding of entity
+// index deltas in PacketEntities.
+func (r *Reader) ReadUBitVar() uint32 {
+	v := r.ReadBits(6)
+	switch v & (16 | 32) {
+	case 16:
+		v = v&15 | r.ReadBits(4)<<4
+	case 32:
+		v = v&15 | r.ReadBits(8)<<4
+	case 48:
+		v = v&15 | r.ReadBits(32-4)<<4
+	}
+	return v
+}
+
+// ReadVarInt32 reads a protocol buffer style varint of at most 5 bytes.
+func (r *Reader) ReadVarInt32() uint32 {
+	var v uint32
+	for i := uint(0); i < 5; i++ {
+		b := r.ReadBits(8)
+		v |= (b & 0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return v
+}
+
+// ReadSignedVarInt32 reads a zigzag encoded varint of at most 5 bytes.
+func (r *Reader) ReadSignedVarInt32() int32 {
+	v := r.ReadVarInt32()
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+// ReadVarInt64 reads a protocol buffer style varint of at most 10 bytes.
+func (r *Reader) ReadVarInt64() uint64 {
+	var v uint64
+	for i := uint(0); i < 10; i++ {
+		b := uint64(r.ReadBits(8))
+		v |= (b & 0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return v
+}
+
+// ReadSignedVarInt64 reads a zigzag encoded varint of at most 10 bytes.
+func (r *Reader) ReadSignedVarInt64() int64 {
+	v := r.ReadVarInt64()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// ReadFloat reads an IEEE 754 float of 32 bits.
+func (r *Reader) ReadFloat() float32 {
+	return math.Float32frombits(r.ReadBits(32))
+}
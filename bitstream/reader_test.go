@@ -0,0 +1,289 @@
+package bitstream
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// pack encodes pairs of values and bit counts, least significant bit first,
+// the way the engine's bf_write does.
+func pack(pairs ...uint64) []byte {
+	var b []byte
+	n := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		v, bits := pairs[i], int(pairs[i+1])
+		for j := 0; j < bits; j++ {
+			if n%8 == 0 {
+				b = append(b, 0)
+			}
+			if v>>uint(j)&1 != 0 {
+				b[n/8] |= 1 << uint(n%8)
+			}
+			n++
+		}
+	}
+	return b
+}
+
+func TestReadBits(t *testing.T) {
+	r := NewReader([]byte{0xac, 0x02, 0xff, 0x00, 0x12, 0x34, 0x56, 0x78, 0x9a})
+	tests := []struct {
+		bits     int
+		expected uint32
+	}{
+		{4, 0xc},
+		{8, 0x2a},
+		{0, 0},
+		{12, 0xff0},
+		{32, 0x56341200},
+		{1, 0},
+		{15, 0x4d3c},
+	}
+	for _, tt := range tests {
+		pos := r.Pos()
+		if v := r.ReadBits(tt.bits); v != tt.expected {
+			t.Errorf("ReadBits(%d) at bit %d: expected %#x, got %#x", tt.bits, pos, tt.expected, v)
+		}
+	}
+	if r.Len() != 0 || r.Err() != nil {
+		t.Errorf("expected the end of the data, got %d bits left and error %v", r.Len(), r.Err())
+	}
+}
+
+func TestReadSignedBits(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		bits     int
+		expected int32
+	}{
+		{pack(5, 4), 4, 5},
+		{pack(0xf, 4), 4, -1},
+		{pack(0x8, 4), 4, -8},
+		{pack(0x9a78, 16), 16, -25992},
+		{pack(0x80000000, 32), 32, math.MinInt32},
+		{pack(1, 1), 1, -1},
+	}
+	for _, tt := range tests {
+		if v := NewReader(tt.data).ReadSignedBits(tt.bits); v != tt.expected {
+			t.Errorf("ReadSignedBits(%d) of %x: expected %d, got %d", tt.bits, tt.data, tt.expected, v)
+		}
+	}
+}
+
+func TestReadBitsUnaligned(t *testing.T) {
+	// every width at every offset, across the fast and the slow path
+	for offset := 0; offset < 8; offset++ {
+		for bits := 1; bits <= 32; bits++ {
+			v := uint64(0xdeadbeef) & (1<<uint(bits) - 1)
+			for _, tail := range []uint64{0, 64} {
+				data := pack(0x55, uint64(offset), v, uint64(bits), 0, tail)
+				r := NewReader(data)
+				r.Skip(offset)
+				if got := r.ReadBits(bits); uint64(got) != v {
+					t.Errorf("ReadBits(%d) at bit %d with %d bits after: expected %#x, got %#x", bits, offset, tail, v, got)
+				}
+			}
+		}
+	}
+}
+
+func TestReadBits64(t *testing.T) {
+	r := NewReader(pack(3, 2, 0x123456789abcdef0, 64, 0x1ffffffff, 33))
+	r.Skip(2)
+	if v := r.ReadBits64(64); v != 0x123456789abcdef0 {
+		t.Errorf("expected 0x123456789abcdef0, got %#x", v)
+	}
+	if v := r.ReadBits64(33); v != 0x1ffffffff {
+		t.Errorf("expected 0x1ffffffff, got %#x", v)
+	}
+}
+
+func TestReadVarInt(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		unsigned uint64
+		signed   int64
+	}{
+		{[]byte{0x00}, 0, 0},
+		{[]byte{0x01}, 1, -1},
+		{[]byte{0x02}, 2, 1},
+		{[]byte{0xac, 0x02}, 300, 150},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0x0f}, math.MaxUint32, math.MinInt32},
+		{[]byte{0xfe, 0xff, 0xff, 0xff, 0x0f}, math.MaxUint32 - 1, math.MaxInt32},
+	}
+	for _, tt := range tests {
+		if v := NewReader(tt.data).ReadVarInt32(); uint64(v) != tt.unsigned {
+			t.Errorf("ReadVarInt32(%x): expected %d, got %d", tt.data, tt.unsigned, v)
+		}
+		if v := NewReader(tt.data).ReadSignedVarInt32(); int64(v) != tt.signed {
+			t.Errorf("ReadSignedVarInt32(%x): expected %d, got %d", tt.data, tt.signed, v)
+		}
+		if v := NewReader(tt.data).ReadVarInt64(); v != tt.unsigned {
+			t.Errorf("ReadVarInt64(%x): expected %d, got %d", tt.data, tt.unsigned, v)
+		}
+		if v := NewReader(tt.data).ReadSignedVarInt64(); v != tt.signed {
+			t.Errorf("ReadSignedVarInt64(%x): expected %d, got %d", tt.data, tt.signed, v)
+		}
+	}
+
+	max64 := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if v := NewReader(max64).ReadVarInt64(); v != math.MaxUint64 {
+		t.Errorf("expected %d, got %d", uint64(math.MaxUint64), v)
+	}
+	// 32 bit varints stop after 5 bytes
+	r := NewReader(max64)
+	r.ReadVarInt32()
+	if r.Pos() != 40 {
+		t.Errorf("expected ReadVarInt32 to stop at bit 40, got %d", r.Pos())
+	}
+}
+
+func TestReadUBitVar(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected uint32
+		bits     int
+	}{
+		{pack(7, 4, 0, 2), 7, 6},
+		{pack(0xf, 4, 1, 2, 0xa, 4), 0xaf, 10},
+		{pack(0x3, 4, 2, 2, 0xbc, 8), 0xbc3, 14},
+		{pack(0x1, 4, 3, 2, 0xfffffff, 28), 0xfffffff1, 34},
+	}
+	for _, tt := range tests {
+		r := NewReader(tt.data)
+		if v := r.ReadUBitVar(); v != tt.expected || r.Pos() != tt.bits {
+			t.Errorf("ReadUBitVar(%x): expected %#x in %d bits, got %#x in %d", tt.data, tt.expected, tt.bits, v, r.Pos())
+		}
+	}
+}
+
+func TestReadBytesAndStrings(t *testing.T) {
+	aligned := NewReader([]byte("abc\x00def\x00ghijkl"))
+	if s := aligned.ReadString(); s != "abc" {
+		t.Errorf("expected abc, got %q", s)
+	}
+	if s := aligned.ReadStringMax(2); s != "de" {
+		t.Errorf("expected de, got %q", s)
+	}
+	if s := aligned.ReadString(); s != "f" {
+		t.Errorf("expected f, got %q", s)
+	}
+	if b := aligned.ReadBytes(3); string(b) != "ghi" {
+		t.Errorf("expected ghi, got %q", b)
+	}
+	if s := aligned.ReadString(); s != "jkl" || aligned.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected an unterminated jkl and io.ErrUnexpectedEOF, got %q and %v", s, aligned.Err())
+	}
+
+	unaligned := NewReader(pack(1, 3, 'h', 8, 'i', 8, 0, 8, 0xfe, 8, 0x01, 8))
+	unaligned.Skip(3)
+	if s := unaligned.ReadString(); s != "hi" {
+		t.Errorf("expected hi, got %q", s)
+	}
+	if b := unaligned.ReadBytes(2); !bytes.Equal(b, []byte{0xfe, 0x01}) {
+		t.Errorf("expected fe01, got %x", b)
+	}
+	if unaligned.Err() != nil {
+		t.Errorf("unexpected error %v", unaligned.Err())
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	r := NewReader([]byte{0xff, 0xff})
+	r.ReadBits(12)
+	if v := r.ReadBits(5); v != 0 || r.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected 0 and io.ErrUnexpectedEOF, got %d and %v", v, r.Err())
+	}
+	if r.ReadBit() || r.Len() != 0 {
+		t.Errorf("expected the reader to stay at the end")
+	}
+
+	r.Reset([]byte{0xff})
+	if r.Err() != nil || !r.ReadBit() {
+		t.Errorf("expected Reset to clear the error")
+	}
+	r.Seek(9)
+	if r.Err() != io.ErrUnexpectedEOF {
+		t.Errorf("expected seeking past the end to fail")
+	}
+	if b := NewReader([]byte{1}).ReadBytes(2); b != nil {
+		t.Errorf("expected no bytes, got %x", b)
+	}
+
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	counts := []struct {
+		name string
+		read func(r *Reader) uint64
+	}{
+		{"ReadBits(-1)", func(r *Reader) uint64 { return uint64(r.ReadBits(-1)) }},
+		{"ReadBits(33)", func(r *Reader) uint64 { return uint64(r.ReadBits(33)) }},
+		{"ReadSignedBits(-1)", func(r *Reader) uint64 { return uint64(r.ReadSignedBits(-1)) }},
+		{"ReadSignedBits(33)", func(r *Reader) uint64 { return uint64(r.ReadSignedBits(33)) }},
+		{"ReadBits64(-1)", func(r *Reader) uint64 { return r.ReadBits64(-1) }},
+		{"ReadBits64(65)", func(r *Reader) uint64 { return r.ReadBits64(65) }},
+	}
+	for _, tt := range counts {
+		r := NewReader(data)
+		r.ReadBit()
+		if v := tt.read(r); v != 0 || r.Err() != ErrBitCount {
+			t.Errorf("%s: expected 0 and ErrBitCount, got %d and %v", tt.name, v, r.Err())
+		}
+		if r.ReadBit() || r.Len() != 0 {
+			t.Errorf("%s: expected the reader to stay at the end", tt.name)
+		}
+	}
+}
+
+var benchData = func() []byte {
+	b := make([]byte, 64<<10)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}()
+
+func BenchmarkReadBits(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() >= 17 {
+			r.ReadBits(17)
+		}
+	}
+}
+
+func BenchmarkReadBit(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() > 0 {
+			r.ReadBit()
+		}
+	}
+}
+
+func BenchmarkReadVarInt32(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() >= 40 {
+			r.ReadVarInt32()
+		}
+	}
+}
+
+func BenchmarkReadUBitVar(b *testing.B) {
+	r := NewReader(benchData)
+	b.SetBytes(int64(len(benchData)))
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchData)
+		for r.Len() >= 34 {
+			r.ReadUBitVar()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Command demotables lists the server classes of a demo, or the flattened
+// props of one class in the order entity updates refer to them.
+//
+//	demotables [-class name] file.dem
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ajmadsen/replayanalyzer/demo"
+)
+
+func main() {
+	class := flag.String("class", "", "server class `name` to list the props of, such as CCSPlayer")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("demotables: ")
+	if flag.NArg() != 1 {
+		log.Fatal("usage: demotables [-class name] file.dem")
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	h, st, err := readSendTables(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if *class == "" {
+		fmt.Fprintf(w, "%s, network protocol %d, %d classes\n", h.MapName, h.NetworkProtocol, len(st.Classes))
+		writeClasses(w, st)
+	} else {
+		c := st.Class(*class)
+		if c == nil {
+			log.Fatalf("no server class %s", *class)
+		}
+		writeProps(w, c)
+	}
+	w.Flush()
+}
+
+// readSendTables reads the demo in r up to its DataTables frame.
+func readSendTables(r io.Reader) (*demo.Header, *demo.SendTables, error) {
+	dr, err := demo.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		f, err := dr.Next()
+		if err == io.EOF {
+			return nil, nil, errors.New("no data tables in demo")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if f.Command == demo.DataTables {
+			st, err := demo.ParseDataTables(f.Data)
+			return dr.Header(), st, err
+		}
+	}
+}
+
+func writeClasses(w io.Writer, st *demo.SendTables) {
+	for _, c := range st.Classes {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d props\n", c.ID, c.Name, c.DTName, len(c.Props))
+	}
+}
+
+func writeProps(w io.Writer, c *demo.ServerClass) {
+	for i, p := range c.Props {
+		fmt.Fprintf(w, "%d\t%s\t%v\t%s", i, p.Name, p.Prop.Type, p.Table.Name)
+		switch {
+		case p.ArrayElem != nil:
+			fmt.Fprintf(w, "\t%d x %v", p.Prop.NumElements, p.ArrayElem.Type)
+		case p.Prop.NumBits > 0:
+			fmt.Fprintf(w, "\t%d bits", p.Prop.NumBits)
+		default:
+			fmt.Fprintf(w, "\t")
+		}
+		fmt.Fprintf(w, "\tpriority %d\tflags %#x\n", p.Prop.Priority, uint32(p.Prop.Flags))
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/demo"
+)
+
+// dataTables is a DataTables frame payload of one send table, DT_Thing with
+// an 8 bit m_x, and one class CThing using it.
+var dataTables = []byte{
+	// svc_SendTable of 24 bytes: net_table_name and one prop
+	9, 24,
+	0x12, 8, 'D', 'T', '_', 'T', 'h', 'i', 'n', 'g',
+	0x22, 12,
+	0x08, 0, // type Int
+	0x12, 3, 'm', '_', 'x',
+	0x48, 8, // num_bits
+	0x20, 0x80, 0x01, // priority 128
+	// the end table
+	9, 2, 0x08, 1,
+	// one class
+	1, 0,
+	0, 0, 'C', 'T', 'h', 'i', 'n', 'g', 0, 'D', 'T', '_', 'T', 'h', 'i', 'n', 'g', 0,
+}
+
+func testDemo(t *testing.T, frames ...[]byte) *bytes.Buffer {
+	var buf bytes.Buffer
+	if err := demo.WriteHeader(&buf, &demo.Header{DemoProtocol: demo.DemoProtocol, NetworkProtocol: 13790, MapName: "de_inferno"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		buf.Write(f)
+	}
+	return &buf
+}
+
+// frame encodes a frame without command info.
+func frame(cmd demo.Command, data []byte) []byte {
+	b := []byte{byte(cmd), 0, 0, 0, 0, 0}
+	if data != nil {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(data)))
+		b = append(append(b, n[:]...), data...)
+	}
+	return b
+}
+
+func TestReadSendTables(t *testing.T) {
+	buf := testDemo(t, frame(demo.ConsoleCmd, []byte("echo\x00")), frame(demo.DataTables, dataTables), frame(demo.Stop, nil))
+	h, st, err := readSendTables(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.MapName != "de_inferno" {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	var out bytes.Buffer
+	writeClasses(&out, st)
+	if s := out.String(); s != "0\tCThing\tDT_Thing\t1 props\n" {
+		t.Errorf("unexpected classes %q", s)
+	}
+	out.Reset()
+	writeProps(&out, st.Class("CThing"))
+	if s := out.String(); s != "0\tm_x\tInt\tDT_Thing\t8 bits\tpriority 128\tflags 0x0\n" {
+		t.Errorf("unexpected props %q", s)
+	}
+
+	_, _, err = readSendTables(testDemo(t, frame(demo.Stop, nil)))
+	if err == nil || !strings.Contains(err.Error(), "no data tables") {
+		t.Errorf("expected no data tables, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Command steamusage summarizes the disk usage of the local Steam libraries:
+// free space, the size of each installed app and its workshop content, and
+// the size of the CS:GO demo folders.
+//
+//	steamusage [-steam path]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ajmadsen/replayanalyzer/csgo"
+	"github.com/ajmadsen/replayanalyzer/steam"
+)
+
+func main() {
+	steamPath := flag.String("steam", "", "Steam installation `path`, found automatically if empty")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("steamusage: ")
+
+	if *steamPath == "" {
+		p, err := steam.GetInstallPath()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*steamPath = p
+	}
+
+	reports, err := steam.Report(*steamPath, steam.ReportOptions{
+		AppDirs: map[int][]string{csgo.AppID: {csgo.ReplaysDir}},
+	})
+	if err != nil {
+		// some libraries could not be read, report the others
+		log.Print(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s", r.Path)
+		if r.Label != "" {
+			fmt.Fprintf(w, " (%s)", r.Label)
+		}
+		if r.TotalSpace > 0 {
+			fmt.Fprintf(w, ": %s free of %s", formatBytes(int64(r.FreeSpace)), formatBytes(int64(r.TotalSpace)))
+		}
+		fmt.Fprintf(w, ", %s used by Steam\n", formatBytes(r.Used()))
+
+		for _, a := range r.Apps {
+			fmt.Fprintf(w, "  %d\t%s\t%v\t%s", a.AppID, a.Name, a.State, formatBytes(a.SizeOnDisk))
+			if a.WorkshopSize > 0 {
+				fmt.Fprintf(w, "\tworkshop %s", formatBytes(a.WorkshopSize))
+			}
+			var dirs []string
+			for dir := range a.DirSizes {
+				dirs = append(dirs, dir)
+			}
+			sort.Strings(dirs)
+			for _, dir := range dirs {
+				fmt.Fprintf(w, "\t%s %s", dir, formatBytes(a.DirSizes[dir]))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	w.Flush()
+}
+
+// formatBytes formats n with a binary unit, such as "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                   "0 B",
+		1023:                "1023 B",
+		1024:                "1.0 KiB",
+		1536:                "1.5 KiB",
+		15000000000:         "14.0 GiB",
+		1 << 50:             "1.0 PiB",
+		9223372036854775807: "8.0 EiB",
+	}
+	for n, expected := range tests {
+		if s := formatBytes(n); s != expected {
+			t.Errorf("formatBytes(%d): expected %q, got %q", n, expected, s)
+		}
+	}
+}
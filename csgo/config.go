@@ -0,0 +1,283 @@
+package csgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// Config is a parsed CS:GO config: the binds, cvars and aliases set by
+// config.cfg and the video settings from video.txt. Bind keys and cvar and
+// alias names are lower case, as the console treats them case-insensitively.
+type Config struct {
+	Binds   map[string]string
+	Cvars   map[string]string
+	Aliases map[string]string
+	Video   map[string]string
+}
+
+// UserConfig is the config of a local Steam account.
+type UserConfig struct {
+	User   steam.LoginUser
+	Config *Config
+}
+
+// ConfigDiff is a setting that differs between two configs. A and B are the
+// values on either side, with InA and InB false when the setting is only
+// present on the other side.
+type ConfigDiff struct {
+	// Section is one of "bind", "cvar", "alias" or "video".
+	Section string
+	Name    string
+	A, B    string
+	InA     bool
+	InB     bool
+}
+
+func (d ConfigDiff) String() string {
+	a, b := fmt.Sprintf("%q", d.A), fmt.Sprintf("%q", d.B)
+	if !d.InA {
+		a = "(unset)"
+	}
+	if !d.InB {
+		b = "(unset)"
+	}
+	return fmt.Sprintf("%s %s: %s -> %s", d.Section, d.Name, a, b)
+}
+
+func newConfig() *Config {
+	return &Config{
+		Binds:   map[string]string{},
+		Cvars:   map[string]string{},
+		Aliases: map[string]string{},
+		Video:   map[string]string{},
+	}
+}
+
+// UserConfigDir returns the directory holding the CS:GO config of the user
+// whose userdata directory is userDataPath.
+func UserConfigDir(userDataPath string) string {
+	return path.Join(userDataPath, fmt.Sprint(AppID), "local", "cfg")
+}
+
+// ParseConfig parses console commands as found in config.cfg or an autoexec.
+// Commands other than bind, unbind, unbindall and alias are taken to set the
+// cvar they name.
+func ParseConfig(r io.Reader) (*Config, error) {
+	c := newConfig()
+	if err := c.parse(r); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) parse(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		for _, cmd := range splitCommands(s.Text()) {
+			c.apply(cmd)
+		}
+	}
+	return s.Err()
+}
+
+func (c *Config) apply(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	name := strings.ToLower(args[0])
+	switch name {
+	case "bind":
+		if len(args) >= 3 {
+			c.Binds[strings.ToLower(args[1])] = args[2]
+		}
+	case "unbind":
+		if len(args) >= 2 {
+			delete(c.Binds, strings.ToLower(args[1]))
+		}
+	case "unbindall":
+		c.Binds = map[string]string{}
+	case "alias":
+		if len(args) >= 3 {
+			c.Aliases[strings.ToLower(args[1])] = args[2]
+		}
+	default:
+		if len(args) >= 2 {
+			c.Cvars[name] = strings.Join(args[1:], " ")
+		}
+	}
+}
+
+// splitCommands tokenizes a console line. Commands are separated by
+// semicolons outside of quotes, and // starts a comment.
+func splitCommands(line string) [][]string {
+	var cmds [][]string
+	var args []string
+	var tok []byte
+	inTok, quoted := false, false
+
+	endTok := func() {
+		if inTok {
+			args = append(args, string(tok))
+		}
+		tok, inTok = tok[:0], false
+	}
+	endCmd := func() {
+		endTok()
+		if len(args) > 0 {
+			cmds = append(cmds, args)
+		}
+		args = nil
+	}
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quoted:
+			if ch == '"' {
+				quoted = false
+				endTok()
+			} else {
+				tok = append(tok, ch)
+			}
+		case ch == '"':
+			endTok()
+			quoted, inTok = true, true
+		case ch == ';':
+			endCmd()
+		case ch == '/' && i+1 < len(line) && line[i+1] == '/':
+			endCmd()
+			return cmds
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			endTok()
+		default:
+			tok = append(tok, ch)
+			inTok = true
+		}
+	}
+	endCmd()
+
+	return cmds
+}
+
+// ReadUserConfig reads config.cfg and, if present, video.txt of the user
+// whose userdata directory is userDataPath.
+func ReadUserConfig(userDataPath string) (*Config, error) {
+	return ReadUserConfigFS(vfs.OS(), vfs.Abs(userDataPath))
+}
+
+// ReadUserConfigFS is like ReadUserConfig, reading from fsys.
+func ReadUserConfigFS(fsys fs.FS, userDataPath string) (*Config, error) {
+	dir := UserConfigDir(userDataPath)
+
+	f, err := fsys.Open(vfs.Name(path.Join(dir, "config.cfg")))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := newConfig()
+	if err := c.parse(f); err != nil {
+		return nil, err
+	}
+
+	videoName := path.Join(dir, "video.txt")
+	vf, err := fsys.Open(vfs.Name(videoName))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer vf.Close()
+
+	video, err := keyvalues.ParseNamed(vf, videoName)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range video.Children {
+		for _, n := range v.Children {
+			if !n.Section {
+				c.Video[strings.ToLower(n.Key)] = n.Value
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// GetUserConfigs reads the CS:GO config of every local account of the Steam
+// installation at steamPath that has one.
+func GetUserConfigs(steamPath string) ([]UserConfig, error) {
+	return GetUserConfigsFS(vfs.OS(), vfs.Abs(steamPath))
+}
+
+// GetUserConfigsFS is like GetUserConfigs, reading from fsys.
+func GetUserConfigsFS(fsys fs.FS, steamPath string) ([]UserConfig, error) {
+	users, err := steam.GetLoginUsersFS(fsys, steamPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []UserConfig
+	for _, u := range users {
+		if u.UserDataPath == "" {
+			continue
+		}
+		c, err := ReadUserConfigFS(fsys, u.UserDataPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config of %v: %v", u.AccountName, err)
+		}
+		configs = append(configs, UserConfig{User: u, Config: c})
+	}
+
+	return configs, nil
+}
+
+// DiffConfigs returns the settings that differ between a and b, ordered by
+// section and name.
+func DiffConfigs(a, b *Config) []ConfigDiff {
+	var diffs []ConfigDiff
+	diffs = diffSection(diffs, "bind", a.Binds, b.Binds)
+	diffs = diffSection(diffs, "cvar", a.Cvars, b.Cvars)
+	diffs = diffSection(diffs, "alias", a.Aliases, b.Aliases)
+	diffs = diffSection(diffs, "video", a.Video, b.Video)
+	return diffs
+}
+
+func diffSection(diffs []ConfigDiff, section string, a, b map[string]string) []ConfigDiff {
+	names := map[string]bool{}
+	for k := range a {
+		names[k] = true
+	}
+	for k := range b {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		av, inA := a[k]
+		bv, inB := b[k]
+		if inA == inB && av == bv {
+			continue
+		}
+		diffs = append(diffs, ConfigDiff{Section: section, Name: k, A: av, B: bv, InA: inA, InB: inB})
+	}
+	return diffs
+}
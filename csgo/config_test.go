@@ -0,0 +1,118 @@
+package csgo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+const testConfigCfg = `unbindall
+bind "0" "slot10"
+bind "MOUSE1" "+attack"
+bind "SPACE" "+jump"
+bind "x" "+jumpthrow"
+alias "+jumpthrow" "+jump;-attack"; alias "-jumpthrow" "-jump"
+cl_crosshairsize "2.5" // keep it small
+sensitivity "2.2"
+unbind "x"
+name "player one"
+viewmodel_fov 68
+host_writeconfig
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(testConfigCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Config{
+		Binds: map[string]string{
+			"0":      "slot10",
+			"mouse1": "+attack",
+			"space":  "+jump",
+		},
+		Cvars: map[string]string{
+			"cl_crosshairsize": "2.5",
+			"sensitivity":      "2.2",
+			"name":             "player one",
+			"viewmodel_fov":    "68",
+		},
+		Aliases: map[string]string{
+			"+jumpthrow": "+jump;-attack",
+			"-jumpthrow": "-jump",
+		},
+		Video: map[string]string{},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+}
+
+func TestGetUserConfigs(t *testing.T) {
+	tp := "/steam"
+	fsys := vfstest.Tree(underRoot(tp, []string{
+		"Dconfig",
+		"Fconfig/loginusers.vdf\n" + `"users"
+{
+	"76561197960287930" { "AccountName" "one" "MostRecent" "1" }
+	"76561198000000001" { "AccountName" "two" "MostRecent" "0" }
+	"76561198000000002" { "AccountName" "three" "MostRecent" "0" }
+}`,
+		"Duserdata",
+		"Duserdata/22202",
+		"Duserdata/22202/730",
+		"Duserdata/22202/730/local",
+		"Duserdata/22202/730/local/cfg",
+		"Fuserdata/22202/730/local/cfg/config.cfg\n" + testConfigCfg,
+		"Fuserdata/22202/730/local/cfg/video.txt\n" + `"VideoConfig"
+{
+	"setting.defaultres"		"1920"
+	"setting.defaultresheight"		"1080"
+}`,
+		"Duserdata/39734273",
+		"Duserdata/39734273/730",
+		"Duserdata/39734273/730/local",
+		"Duserdata/39734273/730/local/cfg",
+		"Fuserdata/39734273/730/local/cfg/config.cfg\n" + `bind "mouse1" "+attack"
+bind "mouse5" "+voicerecord"
+sensitivity "1.8"
+cl_crosshairsize "2.5"
+viewmodel_fov "68"
+name "player two"
+alias "+jumpthrow" "+jump;-attack"
+alias "-jumpthrow" "-jump"
+`,
+		"Duserdata/39734274",
+	})...)
+
+	configs, err := GetUserConfigsFS(fsys, tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[0].User.AccountName != "one" || configs[1].User.AccountName != "two" {
+		t.Fatalf("expected configs for users one and two, got %+v", configs)
+	}
+	if configs[0].Config.Video["setting.defaultres"] != "1920" {
+		t.Errorf("video settings not read: %v", configs[0].Config.Video)
+	}
+
+	var diffs []string
+	for _, d := range DiffConfigs(configs[0].Config, configs[1].Config) {
+		diffs = append(diffs, d.String())
+	}
+	expected := []string{
+		`bind 0: "slot10" -> (unset)`,
+		`bind mouse5: (unset) -> "+voicerecord"`,
+		`bind space: "+jump" -> (unset)`,
+		`cvar name: "player one" -> "player two"`,
+		`cvar sensitivity: "2.2" -> "1.8"`,
+		`video setting.defaultres: "1920" -> (unset)`,
+		`video setting.defaultresheight: "1080" -> (unset)`,
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("expected diff\n%v\ngot\n%v", strings.Join(expected, "\n"), strings.Join(diffs, "\n"))
+	}
+}
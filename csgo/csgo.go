@@ -1,68 +1,80 @@
 package csgo
 
 import (
-	"bytes"
-	"io/ioutil"
-	"os"
-	"path"
-	"path/filepath"
-	"regexp"
+	"context"
+	"io/fs"
 	"time"
-	"unicode"
+
+	"github.com/ajmadsen/replayanalyzer/steam"
 )
 
+// AppID is the Steam app id of CS:GO.
+const AppID = 730
+
+// ReplaysDir is the directory, relative to a CS:GO install, that matchmaking
+// demos are downloaded to.
+const ReplaysDir = "csgo/replays"
+
+// GetInstallPaths returns the CS:GO install directories found in the given
+// Steam libraries. Installs are resolved from the app manifests Steam keeps
+// in each library. Libraries that cannot be read are reported in a
+// steam.ScanErrors returned together with the installs found in the others.
 func GetInstallPaths(libraryPaths []string) ([]string, error) {
-	var csgoPaths []string
-	for _, p := range libraryPaths {
-		apppath := path.Join(p, "steamapps", "common")
-		walkfun := func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			p = path.Clean(filepath.ToSlash(p))
-			dir, file := path.Split(p)
-			dir = path.Clean(dir)
-			if info.IsDir() && p != apppath && dir != apppath {
-				return filepath.SkipDir
-			}
-			if file == "steam_appid.txt" {
-				b, err := ioutil.ReadFile(p)
-				if err != nil {
-					return err
-				}
+	return steam.GetAppInstallPaths(libraryPaths, AppID)
+}
 
-				b = bytes.TrimFunc(b, func(r rune) bool {
-					return unicode.IsSpace(r) || r == rune(0)
-				})
-				if match, err := regexp.Match(`^730$`, b); match && err == nil {
-					csgoPaths = append(csgoPaths, path.Join(filepath.ToSlash(p), ".."))
-					return filepath.SkipDir
-				}
-			}
-			return nil
-		}
-		err := filepath.Walk(apppath, walkfun)
-		if err != nil {
-			return nil, err
-		}
-	}
+// GetInstallPathsFS is like GetInstallPaths, reading from fsys.
+func GetInstallPathsFS(fsys fs.FS, libraryPaths []string) ([]string, error) {
+	return steam.GetAppInstallPathsFS(fsys, libraryPaths, AppID)
+}
+
+// GetInstalls is like GetInstallPaths, but returns the app manifests of the
+// installs. An install that is outdated or mid-update may not match the
+// network protocol of recent demos, so check its InstallState before relying
+// on it.
+func GetInstalls(libraryPaths []string) ([]*steam.AppManifest, error) {
+	return steam.GetAppInstalls(libraryPaths, AppID)
+}
 
-	return csgoPaths, nil
+// GetInstallsFS is like GetInstalls, reading from fsys.
+func GetInstallsFS(fsys fs.FS, libraryPaths []string) ([]*steam.AppManifest, error) {
+	return steam.GetAppInstallsFS(fsys, libraryPaths, AppID)
 }
 
+// GetDemos returns the .dem files in the given replay directories modified
+// after since, oldest first. It is a shorthand for QueryDemos.
 func GetDemos(replayPaths []string, since time.Time) ([]string, error) {
-	var demos []string
-	for _, c := range replayPaths {
-		paths, _ := filepath.Glob(path.Join(c, "*.dem"))
-		for _, p := range paths {
-			info, err := os.Stat(p)
-			if err != nil {
-				return nil, err
-			}
-			if info.ModTime().After(since) {
-				demos = append(demos, path.Clean(filepath.ToSlash(p)))
-			}
-		}
+	return demoPaths(QueryDemos(DemoQuery{Dirs: replayPaths, Since: since}))
+}
+
+// GetDemosFS is like GetDemos, reading from fsys.
+func GetDemosFS(fsys fs.FS, replayPaths []string, since time.Time) ([]string, error) {
+	return demoPaths(QueryDemosFS(fsys, DemoQuery{Dirs: replayPaths, Since: since}))
+}
+
+func demoPaths(demos []DemoFile, err error) ([]string, error) {
+	var paths []string
+	for _, d := range demos {
+		paths = append(paths, d.Path)
+	}
+	return paths, err
+}
+
+// GetInstallPathsContext is a concurrent GetInstallPaths. See
+// steam.GetAppInstallPathsContext.
+func GetInstallPathsContext(ctx context.Context, libraryPaths []string, opts steam.ScanOptions, found func(string)) error {
+	return steam.GetAppInstallPathsContext(ctx, libraryPaths, AppID, opts, found)
+}
+
+// GetDemosContext is a concurrent GetDemos. Demos are passed to found as
+// they are found, so they come in no particular order. See steam.ScanPaths.
+func GetDemosContext(ctx context.Context, replayPaths []string, since time.Time, opts steam.ScanOptions, found func(string)) error {
+	scan := func(ctx context.Context, fsys fs.FS, c string, found func(string)) error {
+		s := newDemoScanner(fsys, DemoQuery{Since: since})
+		s.scan(ctx, c, func(f DemoFile) {
+			found(f.Path)
+		})
+		return s.errs.Err()
 	}
-	return demos, nil
+	return steam.ScanPaths(ctx, replayPaths, opts, scan, found)
 }
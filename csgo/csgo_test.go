@@ -1,19 +1,25 @@
 package csgo
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
-	"os"
+	"io/fs"
 	"path"
-	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
 )
 
 var testTreePaths = []string{
 	"Dsteamapps",
+	"Fsteamapps/appmanifest_730.acf\n\"AppState\" { \"appid\" \"730\" \"installdir\" \"game1\" }",
+	"Fsteamapps/appmanifest_7300.acf\n\"AppState\" { \"appid\" \"7300\" \"installdir\" \"game2\" }",
 	"Dsteamapps/common",
 	"Dsteamapps/common/game1",
 	"Fsteamapps/common/game1/steam_appid.txt\n730",
@@ -35,122 +41,151 @@ var testTreePaths = []string{
 	"Fsteamapps/common/game5/subdir/steam_appid.txt\n730",
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// underRoot prefixes the name of each vfstest.Tree entry with root.
+func underRoot(root string, entries []string) []string {
+	var prefixed []string
+	for _, e := range entries {
+		name, contents := e[1:], ""
+		if nl := strings.Index(name, "\n"); nl >= 0 {
+			name, contents = name[:nl], name[nl:]
+		}
+		prefixed = append(prefixed, e[:1]+path.Join(root, name)+contents)
+	}
+	return prefixed
 }
 
-func getRandomString() string {
-	charSet := "abcdefghijklmnopqrstuvwxyz0123456789"
-	s := ""
-	for i := 0; i < 8; i++ {
-		s += string(charSet[rand.Intn(len(charSet))])
-	}
-	return s
+// failFS fails to open the names in fail.
+type failFS struct {
+	fs.FS
+	fail map[string]error
 }
 
-func makeTestTree(paths []string) (string, error) {
-	tmpDir := filepath.ToSlash(path.Join(os.TempDir(), getRandomString()))
-	err := os.MkdirAll(tmpDir, os.ModeDir)
-	if err != nil {
-		return "", err
-	}
-
-	for _, p := range paths {
-		t := p[0]
-		p = p[1:]
-		switch t {
-		case 'D':
-			dirName := path.Join(tmpDir, p)
-			err = os.Mkdir(dirName, os.ModeDir)
-			if err != nil {
-				return "", err
-			}
-		case 'F':
-			nl := strings.Index(p, "\n")
-			var fileName, contents string
-			if nl > 0 {
-				fileName = p[:nl]
-				contents = p[nl+1:]
-			} else {
-				fileName = p
-			}
-			fileName = path.Join(tmpDir, fileName)
-			err = ioutil.WriteFile(fileName, []byte(contents), 0644)
-			if err != nil {
-				return "", err
-			}
-		default:
-			return "", fmt.Errorf("invalid type %v", t)
-		}
+func (f failFS) Open(name string) (fs.File, error) {
+	if err, ok := f.fail[name]; ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
 	}
-
-	return tmpDir, nil
+	return f.FS.Open(name)
 }
 
 func TestGetCsgoPaths(t *testing.T) {
-	var testPaths []string
-	solutions := map[string]bool{}
+	var entries, testPaths, expected []string
 	for i := 0; i < 5; i++ {
-		tp, err := makeTestTree(testTreePaths)
-		if err != nil {
-			t.Error(err)
-		}
-		defer os.RemoveAll(tp)
+		tp := fmt.Sprintf("/library%d", i)
+		entries = append(entries, underRoot(tp, testTreePaths)...)
 		testPaths = append(testPaths, tp)
-		solutions[path.Join(tp, "steamapps/common/game1")] = true
+		expected = append(expected, path.Join(tp, "steamapps/common/game1"))
 	}
+	entries = append(entries, "Fbroken/steamapps/appmanifest_730.acf\n\"AppState\" {")
+	testPaths = append(testPaths, "/missing", "/broken")
 
-	ps, err := GetInstallPaths(testPaths)
-	if err != nil {
-		t.Error(err)
+	// an unreadable library in the middle must not hide the later ones
+	fsys := failFS{vfstest.Tree(entries...), map[string]error{
+		"library1/steamapps/appmanifest_730.acf": fs.ErrPermission,
+	}}
+	expected = append(expected[:1], expected[2:]...)
+
+	ps, err := GetInstallPathsFS(fsys, testPaths)
+	if !reflect.DeepEqual(ps, expected) {
+		t.Errorf("expected %v, got %v", expected, ps)
 	}
 
-	revmap := map[string]bool{}
-	for _, p := range ps {
-		if !solutions[p] {
-			t.Errorf("returned %v which does not look like CSGO", p)
-		}
-		revmap[p] = true
+	errs, ok := err.(steam.ScanErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected three scan errors, got %v", err)
+	}
+	problems := []struct{ op, path string }{
+		{"open", "/library1/steamapps/appmanifest_730.acf"},
+		{"stat", "/missing/steamapps"},
+		{"parse", "/broken/steamapps/appmanifest_730.acf"},
 	}
-	for s := range solutions {
-		if !revmap[s] {
-			t.Errorf("did not return path %v", s)
+	for i, p := range problems {
+		if errs[i].Op != p.op || errs[i].Path != p.path {
+			t.Errorf("problem %d: expected %v %v, got %v", i, p.op, p.path, errs[i])
 		}
 	}
+	if !errors.Is(errs[0], fs.ErrPermission) {
+		t.Errorf("expected a permission error, got %v", errs[0])
+	}
+}
+
+func TestGetInstalls(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Flib1/steamapps/appmanifest_730.acf\n\"AppState\" { \"appid\" \"730\" \"installdir\" \"csgo\" \"StateFlags\" \"4\" \"buildid\" \"7\" }",
+		"Dlib1/steamapps/common/csgo",
+		"Flib2/steamapps/appmanifest_730.acf\n\"AppState\" { \"appid\" \"730\" \"installdir\" \"csgo\" \"StateFlags\" \"1030\" \"buildid\" \"6\" \"TargetBuildID\" \"7\" }",
+		"Dlib2/steamapps/common/csgo",
+	)
+
+	installs, err := GetInstallsFS(fsys, []string{"/lib1", "/lib2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installs) != 2 {
+		t.Fatalf("expected two installs, got %+v", installs)
+	}
+	if p, s := installs[0].InstallPath(), installs[0].InstallState(); p != "/lib1/steamapps/common/csgo" || s != steam.Installed {
+		t.Errorf("expected an installed /lib1, got %v %v", p, s)
+	}
+	if p, s := installs[1].InstallPath(), installs[1].InstallState(); p != "/lib2/steamapps/common/csgo" || s != steam.Updating {
+		t.Errorf("expected an updating /lib2, got %v %v", p, s)
+	}
 }
 
 func TestGetCsgoDemos(t *testing.T) {
-	var testPaths []string
-	solutions := map[string]bool{}
+	var entries, testPaths, expected []string
 	for i := 0; i < 5; i++ {
-		tp, err := makeTestTree(testTreePaths)
-		if err != nil {
-			t.Error(err)
-		}
-		defer os.RemoveAll(tp)
+		tp := fmt.Sprintf("/library%d", i)
+		entries = append(entries, underRoot(tp, testTreePaths)...)
 		tp = path.Join(tp, "steamapps", "common", "game1", "csgo", "replays")
 		testPaths = append(testPaths, tp)
-		for _, p := range []string{"12345.dem", "34567.dem"} {
-			p = path.Join(tp, p)
-			solutions[p] = true
+		expected = append(expected, path.Join(tp, "34567.dem"))
+	}
+	testPaths = append(testPaths, "/missing")
+
+	since := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := vfstest.Tree(entries...)
+	for name, f := range fsys {
+		switch path.Base(name) {
+		case "12345.dem":
+			f.ModTime = since.Add(-time.Hour)
+		case "34567.dem":
+			f.ModTime = since.Add(time.Hour)
 		}
 	}
 
-	demos, err := GetDemos(testPaths, time.Time{})
+	demos, err := GetDemosFS(fsys, testPaths, since)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(demos, expected) {
+		t.Errorf("expected %v, got %v", expected, demos)
 	}
+}
 
-	revmap := map[string]bool{}
-	for _, d := range demos {
-		if !solutions[d] {
-			t.Errorf("found a demo file outside of tree %v", d)
-		}
-		revmap[d] = true
+func TestGetCsgoDemosContext(t *testing.T) {
+	var entries, testPaths, expected []string
+	for i := 0; i < 5; i++ {
+		tp := fmt.Sprintf("/library%d", i)
+		entries = append(entries, underRoot(tp, testTreePaths)...)
+		tp = path.Join(tp, "steamapps", "common", "game1", "csgo", "replays")
+		testPaths = append(testPaths, tp)
+		expected = append(expected, path.Join(tp, "12345.dem"), path.Join(tp, "34567.dem"))
 	}
-	for s := range solutions {
-		if !revmap[s] {
-			t.Errorf("did not find demo %v", s)
-		}
+
+	fsys := vfstest.Tree(entries...)
+	for _, f := range fsys {
+		f.ModTime = time.Now()
+	}
+
+	var demos []string
+	err := GetDemosContext(context.Background(), testPaths, time.Time{}, steam.ScanOptions{FS: fsys, Workers: 2}, func(p string) {
+		demos = append(demos, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(demos)
+	if !reflect.DeepEqual(demos, expected) {
+		t.Errorf("expected %v, got %v", expected, demos)
 	}
 }
@@ -0,0 +1,257 @@
+package csgo
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/demo"
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// DemoFile is a demo found by QueryDemos.
+type DemoFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// Header is the demo's header if DemoQuery.ReadHeaders is set and the
+	// header could be read.
+	Header *demo.Header
+}
+
+// DemoSort is the order of the demos returned by QueryDemos.
+type DemoSort int
+
+const (
+	// SortByTime orders demos by modification time, oldest first.
+	SortByTime DemoSort = iota
+	// SortBySize orders demos by size, smallest first.
+	SortBySize
+	// SortByName orders demos by file name.
+	SortByName
+)
+
+// DemoQuery selects demos for QueryDemos. The zero value of each field
+// matches every demo, so a query with only Dirs set lists the .dem files in
+// them, oldest first.
+type DemoQuery struct {
+	// Dirs are the directories to search.
+	Dirs []string
+	// Recursive searches the subdirectories of Dirs too, following
+	// symbolic links.
+	Recursive bool
+
+	// Include are path.Match patterns, at least one of which the file name
+	// of a demo must match. If empty, "*.dem" is used.
+	Include []string
+	// Exclude are path.Match patterns of file and directory names to skip,
+	// such as "*_backup.dem" or "old".
+	Exclude []string
+
+	// MinSize and MaxSize bound the size of demos in bytes. A zero MaxSize
+	// means no upper bound.
+	MinSize, MaxSize int64
+	// Since and Until bound the modification time of demos, which must be
+	// after Since and before Until. A zero time means no bound.
+	Since, Until time.Time
+
+	// SortBy is the order of the result, and Reverse reverses it.
+	SortBy  DemoSort
+	Reverse bool
+
+	// ReadHeaders reads the header of each demo into DemoFile.Header.
+	ReadHeaders bool
+}
+
+// QueryDemos returns the demos selected by q. The same file reached through
+// symbolic links, or listed in several of q.Dirs, is only returned once.
+// Missing directories are skipped, and directories or files that cannot be
+// read are reported in a steam.ScanErrors returned together with the demos
+// found elsewhere.
+func QueryDemos(q DemoQuery) ([]DemoFile, error) {
+	dirs := make([]string, len(q.Dirs))
+	for i, d := range q.Dirs {
+		dirs[i] = vfs.Abs(d)
+	}
+	q.Dirs = dirs
+	return QueryDemosFS(vfs.OS(), q)
+}
+
+// QueryDemosFS is like QueryDemos, reading from fsys. Files reached through
+// symbolic links are only recognized as the same if fsys reports the
+// os.FileInfo of the host, as vfs.OS does.
+func QueryDemosFS(fsys fs.FS, q DemoQuery) ([]DemoFile, error) {
+	s := newDemoScanner(fsys, q)
+	var demos []DemoFile
+	for _, d := range q.Dirs {
+		s.scan(context.Background(), d, func(f DemoFile) {
+			demos = append(demos, f)
+		})
+	}
+	sortDemos(demos, q.SortBy, q.Reverse)
+	return demos, s.errs.Err()
+}
+
+func sortDemos(demos []DemoFile, by DemoSort, reverse bool) {
+	sort.SliceStable(demos, func(i, j int) bool {
+		if reverse {
+			i, j = j, i
+		}
+		a, b := demos[i], demos[j]
+		switch {
+		case by == SortByTime && !a.ModTime.Equal(b.ModTime):
+			return a.ModTime.Before(b.ModTime)
+		case by == SortBySize && a.Size != b.Size:
+			return a.Size < b.Size
+		case by == SortByName && path.Base(a.Path) != path.Base(b.Path):
+			return path.Base(a.Path) < path.Base(b.Path)
+		}
+		return a.Path < b.Path
+	})
+}
+
+// demoScanner finds the demos of a query, remembering what it has seen so
+// that files and directories reached twice are only visited once.
+type demoScanner struct {
+	fsys fs.FS
+	q    DemoQuery
+	errs steam.ScanErrors
+
+	seenPaths map[string]bool
+	// seenInfos holds the infos seen by size, for os.SameFile
+	seenInfos map[int64][]fs.FileInfo
+}
+
+func newDemoScanner(fsys fs.FS, q DemoQuery) *demoScanner {
+	if len(q.Include) == 0 {
+		q.Include = []string{"*.dem"}
+	}
+	return &demoScanner{
+		fsys:      fsys,
+		q:         q,
+		seenPaths: map[string]bool{},
+		seenInfos: map[int64][]fs.FileInfo{},
+	}
+}
+
+// seen reports whether the file or directory p with info was seen before,
+// and marks it as seen.
+func (s *demoScanner) seen(p string, info fs.FileInfo) bool {
+	if s.seenPaths[p] {
+		return true
+	}
+	s.seenPaths[p] = true
+	for _, o := range s.seenInfos[info.Size()] {
+		if os.SameFile(o, info) {
+			return true
+		}
+	}
+	s.seenInfos[info.Size()] = append(s.seenInfos[info.Size()], info)
+	return false
+}
+
+// scan passes the demos in dir to found, stopping early if ctx is done.
+func (s *demoScanner) scan(ctx context.Context, dir string, found func(DemoFile)) {
+	dir = path.Clean(filepath.ToSlash(dir))
+	info, err := fs.Stat(s.fsys, vfs.Name(dir))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		s.errs.Add("stat", dir, err)
+		return
+	}
+	if !info.IsDir() || s.seen(dir, info) {
+		return
+	}
+	s.walk(ctx, dir, found)
+}
+
+func (s *demoScanner) walk(ctx context.Context, dir string, found func(DemoFile)) {
+	entries, err := fs.ReadDir(s.fsys, vfs.Name(dir))
+	if err != nil {
+		s.errs.Add("readdir", dir, err)
+		// ReadDir may still have returned the entries it got to
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if matchAny(s.q.Exclude, e.Name()) {
+			continue
+		}
+		isDir := e.IsDir()
+		if !isDir && !matchAny(s.q.Include, e.Name()) && e.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		if isDir && !s.q.Recursive {
+			continue
+		}
+
+		p := path.Join(dir, e.Name())
+		var info fs.FileInfo
+		if e.Type()&fs.ModeSymlink != 0 {
+			// follow the link to see what it points to
+			info, err = fs.Stat(s.fsys, vfs.Name(p))
+		} else {
+			info, err = e.Info()
+		}
+		if err != nil {
+			s.errs.Add("stat", p, err)
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			if s.q.Recursive && !s.seen(p, info) {
+				s.walk(ctx, p, found)
+			}
+		case info.Mode().IsRegular():
+			if !matchAny(s.q.Include, e.Name()) || !s.matches(info) || s.seen(p, info) {
+				continue
+			}
+			f := DemoFile{Path: p, Size: info.Size(), ModTime: info.ModTime()}
+			if s.q.ReadHeaders {
+				f.Header, err = readDemoHeader(s.fsys, p)
+				if err != nil {
+					s.errs.Add("read", p, err)
+				}
+			}
+			found(f)
+		}
+	}
+}
+
+// matches reports whether a demo with info satisfies the size and time
+// bounds of the query.
+func (s *demoScanner) matches(info fs.FileInfo) bool {
+	q := s.q
+	size, mtime := info.Size(), info.ModTime()
+	return size >= q.MinSize && (q.MaxSize == 0 || size <= q.MaxSize) &&
+		(q.Since.IsZero() || mtime.After(q.Since)) &&
+		(q.Until.IsZero() || mtime.Before(q.Until))
+}
+
+func readDemoHeader(fsys fs.FS, name string) (*demo.Header, error) {
+	f, err := fsys.Open(vfs.Name(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return demo.ReadHeader(f)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package csgo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/demo"
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func testDemoHeader(mapName string) string {
+	var buf bytes.Buffer
+	demo.WriteHeader(&buf, &demo.Header{
+		DemoProtocol:    demo.DemoProtocol,
+		NetworkProtocol: 13753,
+		ServerName:      "Valve CS:GO EU West Server",
+		ClientName:      "GOTV Demo",
+		MapName:         mapName,
+		GameDirectory:   "csgo",
+	})
+	return buf.String()
+}
+
+func TestQueryDemos(t *testing.T) {
+	base := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name     string
+		size     int
+		modified time.Duration
+	}{
+		{"replays/match730_1.dem", 300, 3 * time.Hour},
+		{"replays/match730_1.dem.info", 10, 3 * time.Hour},
+		{"replays/match730_2.dem", 100, 1 * time.Hour},
+		{"scrim.dem", 200, 2 * time.Hour},
+		{"pov/a_backup.dem", 50, 4 * time.Hour},
+		{"pov/old/pov0.dem", 400, 5 * time.Hour},
+	}
+	fsys := vfstest.Tree()
+	for _, f := range files {
+		fsys[vfs.Name("/csgo/"+f.name)] = &fstest.MapFile{Data: make([]byte, f.size), ModTime: base.Add(f.modified)}
+	}
+
+	run := func(q DemoQuery) []string {
+		t.Helper()
+		q.Dirs = []string{"/csgo", "/csgo/replays", "/missing"}
+		demos, err := QueryDemosFS(fsys, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, d := range demos {
+			names = append(names, d.Path[len("/csgo/"):])
+		}
+		return names
+	}
+
+	tests := []struct {
+		q        DemoQuery
+		expected []string
+	}{
+		{DemoQuery{}, []string{"replays/match730_2.dem", "scrim.dem", "replays/match730_1.dem"}},
+		{DemoQuery{Recursive: true}, []string{"replays/match730_2.dem", "scrim.dem", "replays/match730_1.dem", "pov/a_backup.dem", "pov/old/pov0.dem"}},
+		{DemoQuery{Recursive: true, Exclude: []string{"*_backup.dem", "old"}}, []string{"replays/match730_2.dem", "scrim.dem", "replays/match730_1.dem"}},
+		{DemoQuery{Recursive: true, Include: []string{"match*"}}, []string{"replays/match730_2.dem", "replays/match730_1.dem", "replays/match730_1.dem.info"}},
+		{DemoQuery{Recursive: true, MinSize: 100, MaxSize: 300}, []string{"replays/match730_2.dem", "scrim.dem", "replays/match730_1.dem"}},
+		{DemoQuery{Recursive: true, Since: base.Add(time.Hour), Until: base.Add(4 * time.Hour)}, []string{"scrim.dem", "replays/match730_1.dem"}},
+		{DemoQuery{Recursive: true, SortBy: SortBySize, Reverse: true}, []string{"pov/old/pov0.dem", "replays/match730_1.dem", "scrim.dem", "replays/match730_2.dem", "pov/a_backup.dem"}},
+		{DemoQuery{SortBy: SortByName}, []string{"replays/match730_1.dem", "replays/match730_2.dem", "scrim.dem"}},
+	}
+	for i, tt := range tests {
+		if names := run(tt.q); !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%d: expected %v, got %v", i, tt.expected, names)
+		}
+	}
+}
+
+func TestQueryDemosHeaders(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Fcsgo/replays/match730_1.dem\n"+testDemoHeader("de_cache"),
+		"Fcsgo/replays/broken.dem\nHL2DEMO",
+	)
+	demos, err := QueryDemosFS(fsys, DemoQuery{Dirs: []string{"/csgo/replays"}, ReadHeaders: true, SortBy: SortByName})
+	if len(demos) != 2 || demos[0].Header != nil || demos[1].Header == nil || demos[1].Header.MapName != "de_cache" {
+		t.Fatalf("expected the header of match730_1.dem only, got %+v", demos)
+	}
+	if err == nil || err.(steam.ScanErrors)[0].Path != "/csgo/replays/broken.dem" {
+		t.Errorf("expected the broken header to be reported, got %v", err)
+	}
+}
+
+func TestQueryDemosSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "demos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	replays := filepath.Join(dir, "replays")
+	if err := os.Mkdir(replays, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(replays, "match730_1.dem"), []byte("demo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(replays, filepath.Join(dir, "linked")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(replays, "match730_1.dem"), filepath.Join(dir, "copy.dem")); err != nil {
+		t.Fatal(err)
+	}
+
+	demos, err := QueryDemos(DemoQuery{Dirs: []string{dir, filepath.Join(dir, "linked")}, Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(demos) != 1 {
+		t.Errorf("expected the demo once, got %+v", demos)
+	}
+}
@@ -0,0 +1,190 @@
+package csgo
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// GameDir is the game directory of a CS:GO install, relative to the install
+// directory. Console commands such as record resolve paths against it.
+const GameDir = "csgo"
+
+// ReplayOptions name the demo directories that cannot be found from an
+// install alone.
+type ReplayOptions struct {
+	// LaunchOptions are CS:GO launch options, such as those returned by
+	// steam.GetLaunchOptions for each local account. The values of
+	// +demo_dir and +demo_path, and of other +demo_* options that are paths
+	// rather than settings such as "+demo_index 0", are searched for demos.
+	LaunchOptions []string
+	// ExtraDirs are further directories to search, such as where demos are
+	// archived. Relative directories are relative to the game directory.
+	ExtraDirs []string
+}
+
+// ReplayDirs returns the directories of the CS:GO install at installPath
+// that demos land in: ReplaysDir for downloaded matchmaking demos and the
+// game directory for the output of record. Directories that do not exist
+// are left out. Directories whose existence cannot be checked are reported
+// in a steam.ScanErrors returned together with the others.
+func ReplayDirs(installPath string) ([]string, error) {
+	return ReplayDirsFS(vfs.OS(), vfs.Abs(installPath))
+}
+
+// ReplayDirsFS is like ReplayDirs, reading from fsys.
+func ReplayDirsFS(fsys fs.FS, installPath string) ([]string, error) {
+	return ReplayDirsWithOptionsFS(fsys, installPath, ReplayOptions{})
+}
+
+// ReplayDirsWithOptions is like ReplayDirs, adding the directories named by
+// opts.
+func ReplayDirsWithOptions(installPath string, opts ReplayOptions) ([]string, error) {
+	return ReplayDirsWithOptionsFS(vfs.OS(), vfs.Abs(installPath), opts)
+}
+
+// ReplayDirsWithOptionsFS is like ReplayDirsWithOptions, reading from fsys.
+func ReplayDirsWithOptionsFS(fsys fs.FS, installPath string, opts ReplayOptions) ([]string, error) {
+	installPath = path.Clean(filepath.ToSlash(installPath))
+	gameDir := path.Join(installPath, GameDir)
+
+	candidates := []string{path.Join(installPath, ReplaysDir), gameDir}
+	for _, o := range opts.LaunchOptions {
+		candidates = append(candidates, launchOptionDemoDirs(o)...)
+	}
+	candidates = append(candidates, opts.ExtraDirs...)
+
+	var dirs []string
+	var errs steam.ScanErrors
+	seen := map[string]bool{}
+	for _, d := range candidates {
+		// launch options come from Windows more often than not
+		d = strings.Replace(d, `\`, "/", -1)
+		if !isAbsPath(d) {
+			d = path.Join(gameDir, d)
+		}
+		d = path.Clean(d)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+
+		info, err := fs.Stat(fsys, vfs.Name(d))
+		if os.IsNotExist(err) || err == nil && !info.IsDir() {
+			continue
+		}
+		if err != nil {
+			errs.Add("stat", d, err)
+			continue
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, errs.Err()
+}
+
+// demoDirOptions are the launch options whose value is a demo directory.
+var demoDirOptions = map[string]bool{
+	"+demo_dir":  true,
+	"+demo_path": true,
+}
+
+// launchOptionDemoDirs returns the directories named by the +demo_* options
+// in the launch options o: the values of demoDirOptions, and the values of
+// other +demo_* options that contain a path separator.
+func launchOptionDemoDirs(o string) []string {
+	args := steam.SplitLaunchOptions(o)
+
+	var dirs []string
+	for i := 0; i+1 < len(args); i++ {
+		opt, v := strings.ToLower(args[i]), args[i+1]
+		if !strings.HasPrefix(opt, "+demo_") || steam.IsLaunchFlag(v) {
+			continue
+		}
+		if demoDirOptions[opt] || strings.ContainsAny(v, `/\`) {
+			dirs = append(dirs, v)
+		}
+		i++
+	}
+	return dirs
+}
+
+// isAbsPath reports whether the slash separated p is absolute on any
+// platform, so that Windows paths from launch options are recognized
+// elsewhere too.
+func isAbsPath(p string) bool {
+	return path.IsAbs(p) || len(p) >= 3 && p[1] == ':' && p[2] == '/'
+}
+
+// FindDemos returns the demos modified after since of every CS:GO install of
+// the Steam installation at steamPath. The ReplayDirs of each install are
+// searched, with the launch options of every local account and extraDirs.
+// Libraries, accounts and directories that cannot be read are reported in a
+// steam.ScanErrors returned together with the demos found elsewhere.
+func FindDemos(steamPath string, since time.Time, extraDirs []string) ([]string, error) {
+	return FindDemosFS(vfs.OS(), vfs.Abs(steamPath), since, extraDirs)
+}
+
+// FindDemosFS is like FindDemos, reading from fsys.
+func FindDemosFS(fsys fs.FS, steamPath string, since time.Time, extraDirs []string) ([]string, error) {
+	libs, err := steam.GetLibraryPathsFS(fsys, steamPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs steam.ScanErrors
+	addErr := func(op, p string, err error) {
+		if se, ok := err.(steam.ScanErrors); ok {
+			errs = append(errs, se...)
+		} else if err != nil {
+			errs.Add(op, p, err)
+		}
+	}
+
+	installs, err := GetInstallPathsFS(fsys, libs)
+	addErr("scan", steamPath, err)
+
+	opts := ReplayOptions{ExtraDirs: extraDirs}
+	users, err := steam.GetLoginUsersFS(fsys, steamPath)
+	if !os.IsNotExist(err) {
+		addErr("read", path.Join(steamPath, "config", "loginusers.vdf"), err)
+	}
+	for _, u := range users {
+		if u.UserDataPath == "" {
+			continue
+		}
+		o, err := steam.GetLaunchOptionsFS(fsys, u.UserDataPath, AppID)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			addErr("read", u.UserDataPath, err)
+			continue
+		}
+		if o != "" {
+			opts.LaunchOptions = append(opts.LaunchOptions, o)
+		}
+	}
+
+	var replayDirs []string
+	seen := map[string]bool{}
+	for _, p := range installs {
+		dirs, err := ReplayDirsWithOptionsFS(fsys, p, opts)
+		addErr("scan", p, err)
+		for _, d := range dirs {
+			if !seen[d] {
+				seen[d] = true
+				replayDirs = append(replayDirs, d)
+			}
+		}
+	}
+
+	demos, err := GetDemosFS(fsys, replayDirs, since)
+	addErr("scan", steamPath, err)
+	return demos, errs.Err()
+}
@@ -0,0 +1,93 @@
+package csgo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func TestLaunchOptionDemoDirs(t *testing.T) {
+	dirs := launchOptionDemoDirs(`-novid +demo_path "D:\My Demos" -tickrate 128 +demo_recordcommands 1 +demo_index 0 +demo_dir pov +demo_archive "//nas/csgo demos" -high`)
+	expected := []string{`D:\My Demos`, "pov", "//nas/csgo demos"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("expected %q, got %q", expected, dirs)
+	}
+}
+
+func TestReplayDirs(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Dgame/csgo/replays",
+		"Dgame/csgo/pov",
+		"Dgame/csgo/1",
+		"Fgame/csgo/notadir",
+		"DD:/My Demos",
+		"Darchive",
+	)
+
+	dirs, err := ReplayDirsWithOptionsFS(fsys, "/game", ReplayOptions{
+		LaunchOptions: []string{
+			`+demo_path "D:\My Demos" +demo_dir pov`,
+			`+demo_dir notadir +demo_other missing/dir +demo_recordcommands 1`,
+		},
+		ExtraDirs: []string{"/archive", "replays"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/game/csgo/replays",
+		"/game/csgo",
+		"D:/My Demos",
+		"/game/csgo/pov",
+		"/archive",
+	}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("expected %v, got %v", expected, dirs)
+	}
+
+	dirs, err = ReplayDirsFS(fsys, "/game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := expected[:2]; !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("expected %v, got %v", expected, dirs)
+	}
+}
+
+func TestFindDemos(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Fsteam/steamapps/libraryfolders.vdf\n"+`"libraryfolders" { "0" { "path" "/steam" } "1" { "path" "/games" } }`,
+		"Fsteam/config/loginusers.vdf\n"+`"users" { "76561197960287930" { "AccountName" "one" } }`,
+		"Fsteam/userdata/22202/config/localconfig.vdf\n"+`"UserLocalConfigStore"
+{
+	"Software" { "Valve" { "Steam" { "apps" { "730" { "LaunchOptions" "-novid +demo_dir pov" } } } } }
+}`,
+		"Fgames/steamapps/appmanifest_730.acf\n"+`"AppState" { "appid" "730" "installdir" "csgo" "StateFlags" "4" }`,
+		"Fgames/steamapps/common/csgo/csgo/replays/match730_1.dem",
+		"Fgames/steamapps/common/csgo/csgo/replays/match730_1.dem.info",
+		"Fgames/steamapps/common/csgo/csgo/scrim.dem",
+		"Fgames/steamapps/common/csgo/csgo/pov/pov1.dem",
+		"Fgames/steamapps/common/csgo/csgo/pov/old/pov0.dem",
+		"Farchive/old.dem",
+	)
+	now := time.Now()
+	for _, f := range fsys {
+		f.ModTime = now
+	}
+
+	demos, err := FindDemosFS(fsys, "/steam", time.Time{}, []string{"/archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/archive/old.dem",
+		"/games/steamapps/common/csgo/csgo/pov/pov1.dem",
+		"/games/steamapps/common/csgo/csgo/replays/match730_1.dem",
+		"/games/steamapps/common/csgo/csgo/scrim.dem",
+	}
+	if !reflect.DeepEqual(demos, expected) {
+		t.Errorf("expected %v, got %v", expected, demos)
+	}
+}
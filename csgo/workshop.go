@@ -0,0 +1,143 @@
+package csgo
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ajmadsen/replayanalyzer/steam"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+var (
+	workshopMapMatcher = regexp.MustCompile(`^workshop/(\d+)/([^/]+)$`)
+)
+
+// WorkshopMap is a map installed from the Steam Workshop.
+type WorkshopMap struct {
+	steam.WorkshopItem
+
+	// Name is the map name, the file name of the .bsp without extension.
+	Name string
+	// BSP is the path of the map file.
+	BSP string
+	// Overviews are the radar overview files shipped with the map: the
+	// overview description <map>.txt and the <map>_radar*.dds images.
+	Overviews []string
+}
+
+// GetWorkshopMaps returns the workshop maps installed in the given Steam
+// libraries. Items without a .bsp are skipped, and an item with several maps
+// is returned once per map. Libraries and item directories that cannot be
+// read are reported in a steam.ScanErrors returned together with the maps
+// found elsewhere.
+func GetWorkshopMaps(libraryPaths []string) ([]WorkshopMap, error) {
+	abs := make([]string, len(libraryPaths))
+	for i, l := range libraryPaths {
+		abs[i] = vfs.Abs(l)
+	}
+	return GetWorkshopMapsFS(vfs.OS(), abs)
+}
+
+// GetWorkshopMapsFS is like GetWorkshopMaps, reading from fsys.
+func GetWorkshopMapsFS(fsys fs.FS, libraryPaths []string) ([]WorkshopMap, error) {
+	var maps []WorkshopMap
+	var errs steam.ScanErrors
+	for _, l := range libraryPaths {
+		items, err := steam.GetWorkshopItemsFS(fsys, l, AppID)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs.Add("workshop", l, err)
+			continue
+		}
+
+		for _, item := range items {
+			if item.Path == "" {
+				continue
+			}
+			maps = append(maps, findWorkshopMaps(fsys, item, &errs)...)
+		}
+	}
+	return maps, errs.Err()
+}
+
+func findWorkshopMaps(fsys fs.FS, item steam.WorkshopItem, errs *steam.ScanErrors) []WorkshopMap {
+	var bsps, others []string
+	root := vfs.Name(item.Path)
+	fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		// report paths the way the caller spelled the item directory
+		p := path.Join(item.Path, strings.TrimPrefix(name, root))
+		if err != nil {
+			errs.Add("walk", p, err)
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.EqualFold(path.Ext(p), ".bsp") {
+			bsps = append(bsps, p)
+		} else {
+			others = append(others, p)
+		}
+		return nil
+	})
+
+	var maps []WorkshopMap
+	for _, bsp := range bsps {
+		base := path.Base(bsp)
+		m := WorkshopMap{
+			WorkshopItem: item,
+			Name:         base[:len(base)-len(path.Ext(base))],
+			BSP:          bsp,
+		}
+		for _, o := range others {
+			if isOverviewFile(path.Base(o), m.Name) {
+				m.Overviews = append(m.Overviews, o)
+			}
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+func isOverviewFile(file, mapName string) bool {
+	file = strings.ToLower(file)
+	mapName = strings.ToLower(mapName)
+	return file == mapName+".txt" ||
+		(strings.HasPrefix(file, mapName+"_radar") && strings.HasSuffix(file, ".dds"))
+}
+
+// ParseWorkshopMapName splits a map name as recorded in demos of workshop
+// maps, workshop/<publishedfileid>/<map>, into its item id and map name.
+func ParseWorkshopMapName(name string) (uint64, string, bool) {
+	m := workshopMapMatcher.FindStringSubmatch(filepath.ToSlash(name))
+	if m == nil {
+		return 0, "", false
+	}
+	id, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, m[2], true
+}
+
+// FindWorkshopMap returns the map of maps a demo recorded on mapName was
+// played on, or nil if mapName is not a workshop map or is not installed.
+func FindWorkshopMap(maps []WorkshopMap, mapName string) *WorkshopMap {
+	id, name, ok := ParseWorkshopMapName(mapName)
+	if !ok {
+		return nil
+	}
+	for i := range maps {
+		if maps[i].ID == id && strings.EqualFold(maps[i].Name, name) {
+			return &maps[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package csgo
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+var workshopTreePaths = []string{
+	"Dsteamapps",
+	"Dsteamapps/workshop",
+	"Fsteamapps/workshop/appworkshop_730.acf\n" + `"AppWorkshop"
+{
+	"appid"		"730"
+	"WorkshopItemsInstalled"
+	{
+		"125438255" { "size" "100" "timeupdated" "1462300000" }
+		"125499116" { "size" "200" "timeupdated" "1462300000" }
+		"999" { "size" "300" "timeupdated" "1462300000" }
+	}
+}`,
+	"Dsteamapps/workshop/content",
+	"Dsteamapps/workshop/content/730",
+	"Dsteamapps/workshop/content/730/125438255",
+	"Fsteamapps/workshop/content/730/125438255/de_cache.bsp",
+	"Fsteamapps/workshop/content/730/125438255/de_cache.txt",
+	"Fsteamapps/workshop/content/730/125438255/de_cache_radar.dds",
+	"Fsteamapps/workshop/content/730/125438255/de_cache_radar_spectate.dds",
+	"Fsteamapps/workshop/content/730/125438255/readme.txt",
+	"Dsteamapps/workshop/content/730/125499116",
+	"Fsteamapps/workshop/content/730/125499116/preview.jpg",
+}
+
+func TestGetWorkshopMaps(t *testing.T) {
+	tp := "/library"
+	fsys := vfstest.Tree(underRoot(tp, workshopTreePaths)...)
+
+	maps, err := GetWorkshopMapsFS(fsys, []string{tp, path.Join(tp, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 1 {
+		t.Fatalf("expected one workshop map, got %+v", maps)
+	}
+
+	item := path.Join(tp, "steamapps/workshop/content/730/125438255")
+	m := maps[0]
+	if m.ID != 125438255 || m.Name != "de_cache" || m.BSP != path.Join(item, "de_cache.bsp") {
+		t.Errorf("unexpected map %+v", m)
+	}
+	overviews := []string{
+		path.Join(item, "de_cache.txt"),
+		path.Join(item, "de_cache_radar.dds"),
+		path.Join(item, "de_cache_radar_spectate.dds"),
+	}
+	if !reflect.DeepEqual(m.Overviews, overviews) {
+		t.Errorf("expected overviews %v, got %v", overviews, m.Overviews)
+	}
+
+	if found := FindWorkshopMap(maps, "workshop/125438255/de_cache"); found == nil || found.BSP != m.BSP {
+		t.Errorf("demo map not matched, got %+v", found)
+	}
+	for _, name := range []string{"de_cache", "workshop/125438255/de_dust2", "workshop/1/de_cache"} {
+		if found := FindWorkshopMap(maps, name); found != nil {
+			t.Errorf("%v matched %+v", name, found)
+		}
+	}
+}
@@ -105,11 +105,11 @@ func TestCSGOTextures(t *testing.T) {
 		t.Skipf("not testing with csgo textures, could not get library paths: %v", err)
 	}
 	csgos, err := csgo.GetInstallPaths(lPaths)
-	if err != nil {
+	if len(csgos) == 0 {
 		t.Skipf("not testing with csgo textures, could not find csgo install: %v", err)
 	}
-	if len(csgos) == 0 {
-		t.Skipf("not testing with csgo textures, could not find csgo install")
+	if err != nil {
+		t.Logf("some libraries could not be scanned: %v", err)
 	}
 
 	var overviews string
@@ -0,0 +1,441 @@
+package demo
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/ajmadsen/replayanalyzer/bitstream"
+)
+
+// PropType is the type of a SendProp.
+type PropType int
+
+// SendProp types.
+const (
+	PropInt PropType = iota
+	PropFloat
+	PropVector
+	PropVectorXY
+	PropString
+	PropArray
+	PropDataTable
+	PropInt64
+)
+
+var propTypeNames = []string{
+	PropInt:       "Int",
+	PropFloat:     "Float",
+	PropVector:    "Vector",
+	PropVectorXY:  "VectorXY",
+	PropString:    "String",
+	PropArray:     "Array",
+	PropDataTable: "DataTable",
+	PropInt64:     "Int64",
+}
+
+func (t PropType) String() string {
+	if t >= 0 && int(t) < len(propTypeNames) {
+		return propTypeNames[t]
+	}
+	return fmt.Sprintf("PropType(%d)", int(t))
+}
+
+// PropFlags are the SPROP_* flags of a SendProp, which select how its value
+// is encoded.
+type PropFlags uint32
+
+// SendProp flags.
+const (
+	PropUnsigned PropFlags = 1 << iota
+	PropCoord
+	PropNoScale
+	PropRoundDown
+	PropRoundUp
+	PropNormal
+	// PropExclude props name a prop of another table that is left out of
+	// the classes using this table.
+	PropExclude
+	PropXYZE
+	// PropInsideArray props are the element type of the Array prop after
+	// them.
+	PropInsideArray
+	PropProxyAlwaysYes
+	PropIsVectorElem
+	// PropCollapsible DataTable props are flattened into their parent
+	// table.
+	PropCollapsible
+	PropCoordMP
+	PropCoordMPLowPrecision
+	PropCoordMPIntegral
+	PropCellCoord
+	PropCellCoordLowPrecision
+	PropCellCoordIntegral
+	// PropChangesOften props are sorted first in the flattened props.
+	PropChangesOften
+	PropVarInt
+)
+
+// Has reports whether all of flags are set in f.
+func (f PropFlags) Has(flags PropFlags) bool {
+	return f&flags == flags
+}
+
+// SendProp is a networked property of a SendTable.
+type SendProp struct {
+	Type  PropType
+	Name  string
+	Flags PropFlags
+	// Priority orders the props when they are flattened.
+	Priority int
+	// DTName is the table of a DataTable prop, or the table of the
+	// excluded prop of a PropExclude prop.
+	DTName      string
+	NumElements int
+	LowValue    float32
+	HighValue   float32
+	NumBits     int
+}
+
+func (p *SendProp) decodeField(d *wireDecoder) (err error) {
+	var v int32
+	switch d.field {
+	case 1:
+		v, err = d.int32()
+		p.Type = PropType(v)
+	case 2:
+		p.Name, err = d.string()
+	case 3:
+		v, err = d.int32()
+		p.Flags = PropFlags(v)
+	case 4:
+		v, err = d.int32()
+		p.Priority = int(v)
+	case 5:
+		p.DTName, err = d.string()
+	case 6:
+		v, err = d.int32()
+		p.NumElements = int(v)
+	case 7:
+		p.LowValue, err = d.float()
+	case 8:
+		p.HighValue, err = d.float()
+	case 9:
+		v, err = d.int32()
+		p.NumBits = int(v)
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// SendTable is CSVCMsg_SendTable, a table of networked properties.
+type SendTable struct {
+	Name         string
+	NeedsDecoder bool
+	Props        []SendProp
+	// IsEnd marks the empty table after the last one.
+	IsEnd bool
+}
+
+func (*SendTable) Type() MessageType { return SVCSendTable }
+
+func (m *SendTable) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.IsEnd, err = d.bool()
+	case 2:
+		m.Name, err = d.string()
+	case 3:
+		m.NeedsDecoder, err = d.bool()
+	case 4:
+		var p SendProp
+		if err = embedded(d, &p); err == nil {
+			m.Props = append(m.Props, p)
+		}
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// FlatProp is a prop of a ServerClass, with the DataTable props leading to
+// it resolved.
+type FlatProp struct {
+	// Name is the name of the prop, prefixed with the names of the
+	// DataTable props leading to it other than collapsible ones and base
+	// classes, such as "m_iHealth" or "cslocaldata.m_vecOrigin[2]".
+	Name string
+	Prop *SendProp
+	// ArrayElem is the element type of Array props.
+	ArrayElem *SendProp
+	// Table is the table the prop is defined in.
+	Table *SendTable
+}
+
+// ServerClass is an entity class of the server, with its flattened props.
+// Entity updates refer to the props by their index in Props.
+type ServerClass struct {
+	ID     int
+	Name   string
+	DTName string
+	Props  []FlatProp
+}
+
+// PropIndex returns the index of the prop name in c.Props, or -1.
+func (c *ServerClass) PropIndex(name string) int {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SendTables are the send tables and server classes of a demo, read from its
+// DataTables frame.
+type SendTables struct {
+	Tables  []*SendTable
+	Classes []*ServerClass
+
+	tables  map[string]*SendTable
+	classes map[string]*ServerClass
+}
+
+// ParseDataTables parses the data of a DataTables frame and flattens the
+// props of every server class.
+func ParseDataTables(data []byte) (*SendTables, error) {
+	r := bitstream.NewReader(data)
+	dt := &SendTables{
+		tables:  map[string]*SendTable{},
+		classes: map[string]*ServerClass{},
+	}
+	for {
+		t := MessageType(r.ReadVarInt32())
+		size := int(r.ReadVarInt32())
+		b := r.ReadBytes(size)
+		if r.Err() != nil {
+			return nil, fmt.Errorf("demo: data tables truncated after %d tables", len(dt.Tables))
+		}
+		if t != SVCSendTable {
+			return nil, fmt.Errorf("demo: unexpected %v in data tables", t)
+		}
+		st := &SendTable{}
+		if err := decodeMessage(b, st); err != nil {
+			return nil, fmt.Errorf("%v: %v", t, err)
+		}
+		if st.IsEnd {
+			break
+		}
+		dt.Tables = append(dt.Tables, st)
+		dt.tables[st.Name] = st
+	}
+
+	n := int(r.ReadBits(16))
+	for i := 0; i < n && r.Err() == nil; i++ {
+		c := &ServerClass{ID: int(r.ReadBits(16))}
+		c.Name = r.ReadStringMax(256)
+		c.DTName = r.ReadStringMax(256)
+		dt.Classes = append(dt.Classes, c)
+		dt.classes[c.Name] = c
+	}
+	if r.Err() != nil {
+		return nil, fmt.Errorf("demo: server classes truncated after %d classes", len(dt.Classes))
+	}
+
+	for _, c := range dt.Classes {
+		if err := dt.flatten(c); err != nil {
+			return nil, err
+		}
+	}
+	return dt, nil
+}
+
+// Table returns the send table name, or nil.
+func (dt *SendTables) Table(name string) *SendTable {
+	return dt.tables[name]
+}
+
+// Class returns the server class name, such as "CCSPlayer", or nil.
+func (dt *SendTables) Class(name string) *ServerClass {
+	return dt.classes[name]
+}
+
+// ClassByID returns the server class with id, or nil.
+func (dt *SendTables) ClassByID(id int) *ServerClass {
+	if id >= 0 && id < len(dt.Classes) && dt.Classes[id].ID == id {
+		return dt.Classes[id]
+	}
+	for _, c := range dt.Classes {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// ClassBits returns the size in bits of the server class ids in entity
+// updates.
+func (dt *SendTables) ClassBits() int {
+	return bits.Len(uint(len(dt.Classes)))
+}
+
+// excludedProp is a prop left out by a PropExclude prop.
+type excludedProp struct {
+	table, name string
+}
+
+// flattener flattens the props of a server class, the way the engine's
+// SendTable_BuildHierarchy does.
+type flattener struct {
+	dt       *SendTables
+	excludes map[excludedProp]bool
+	props    []FlatProp
+}
+
+func (dt *SendTables) flatten(c *ServerClass) error {
+	t := dt.tables[c.DTName]
+	if t == nil {
+		return fmt.Errorf("demo: unknown send table %q of class %s", c.DTName, c.Name)
+	}
+	f := &flattener{dt: dt, excludes: map[excludedProp]bool{}}
+	if err := f.gatherExcludes(t, map[*SendTable]bool{}); err != nil {
+		return err
+	}
+	if err := f.gatherProps(t, "", 0); err != nil {
+		return err
+	}
+	for _, p := range f.props {
+		err := checkProp(p.Prop)
+		if err == nil && p.ArrayElem != nil {
+			err = checkProp(p.ArrayElem)
+		}
+		if err != nil {
+			return fmt.Errorf("%v in class %s", err, c.Name)
+		}
+	}
+	c.Props = f.props
+	sortProps(c.Props)
+	return nil
+}
+
+// maxTableDepth bounds the nesting of send tables, so that a table that
+// includes itself is an error rather than a stack overflow.
+const maxTableDepth = 64
+
+func (f *flattener) subTable(t *SendTable, p *SendProp) (*SendTable, error) {
+	sub := f.dt.tables[p.DTName]
+	if sub == nil {
+		return nil, fmt.Errorf("demo: unknown send table %q of %s.%s", p.DTName, t.Name, p.Name)
+	}
+	return sub, nil
+}
+
+func (f *flattener) gatherExcludes(t *SendTable, seen map[*SendTable]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := range t.Props {
+		p := &t.Props[i]
+		if p.Flags.Has(PropExclude) {
+			f.excludes[excludedProp{p.DTName, p.Name}] = true
+		}
+		if p.Type == PropDataTable {
+			sub, err := f.subTable(t, p)
+			if err != nil {
+				return err
+			}
+			if err := f.gatherExcludes(sub, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gatherProps appends the props of t to f.props, after those of the tables
+// it includes that are not collapsible.
+func (f *flattener) gatherProps(t *SendTable, prefix string, depth int) error {
+	var props []FlatProp
+	if err := f.iterateProps(t, prefix, depth, &props); err != nil {
+		return err
+	}
+	f.props = append(f.props, props...)
+	return nil
+}
+
+func (f *flattener) iterateProps(t *SendTable, prefix string, depth int, props *[]FlatProp) error {
+	if depth > maxTableDepth {
+		return fmt.Errorf("demo: send table %s nested too deeply", t.Name)
+	}
+	for i := range t.Props {
+		p := &t.Props[i]
+		if p.Flags.Has(PropInsideArray) || p.Flags.Has(PropExclude) || f.excludes[excludedProp{t.Name, p.Name}] {
+			continue
+		}
+
+		switch p.Type {
+		case PropDataTable:
+			sub, err := f.subTable(t, p)
+			if err != nil {
+				return err
+			}
+			if p.Flags.Has(PropCollapsible) {
+				err = f.iterateProps(sub, prefix, depth+1, props)
+			} else {
+				subPrefix := prefix
+				if p.Name != "baseclass" {
+					subPrefix += p.Name + "."
+				}
+				err = f.gatherProps(sub, subPrefix, depth+1)
+			}
+			if err != nil {
+				return err
+			}
+		case PropArray:
+			if i == 0 {
+				return fmt.Errorf("demo: array %s.%s has no element type", t.Name, p.Name)
+			}
+			*props = append(*props, FlatProp{Name: prefix + p.Name, Prop: p, ArrayElem: &t.Props[i-1], Table: t})
+		default:
+			*props = append(*props, FlatProp{Name: prefix + p.Name, Prop: p, Table: t})
+		}
+	}
+	return nil
+}
+
+// sortProps moves the props to the order of their priority, keeping the
+// order of props of the same priority. Props that change often are sorted
+// with those of priority 64.
+func sortProps(props []FlatProp) {
+	priorities := []int{64}
+	seen := map[int]bool{64: true}
+	for _, p := range props {
+		if !seen[p.Prop.Priority] {
+			seen[p.Prop.Priority] = true
+			priorities = append(priorities, p.Prop.Priority)
+		}
+	}
+	sort.Ints(priorities)
+
+	// the engine swaps props into place rather than sorting stably, and
+	// entity updates depend on the resulting order
+	start := 0
+	for _, priority := range priorities {
+		for {
+			i := start
+			for ; i < len(props); i++ {
+				p := props[i].Prop
+				if p.Priority == priority || priority == 64 && p.Flags.Has(PropChangesOften) {
+					props[start], props[i] = props[i], props[start]
+					start++
+					break
+				}
+			}
+			if i == len(props) {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package demo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sendProp encodes a sendprop_t of CSVCMsg_SendTable.
+func sendProp(typ PropType, name string, flags PropFlags, priority int, dtName string) pb {
+	b := pb(nil).int(1, int64(typ)).string(2, name).int(3, int64(flags)).int(4, int64(priority))
+	if dtName != "" {
+		b = b.string(5, dtName)
+	}
+	return b
+}
+
+// sendTable encodes a CSVCMsg_SendTable.
+func sendTable(name string, props ...pb) pb {
+	b := pb(nil).string(2, name)
+	for _, p := range props {
+		b = b.bytes(4, p)
+	}
+	return b
+}
+
+// testClass is a server class for writeDataTables.
+type testClass struct {
+	id           int
+	name, dtName string
+}
+
+// writeDataTables encodes the data of a DataTables frame.
+func writeDataTables(tables []pb, classes []testClass) []byte {
+	var msgs []RawMessage
+	for _, t := range tables {
+		msgs = append(msgs, RawMessage{SVCSendTable, t})
+	}
+	msgs = append(msgs, RawMessage{SVCSendTable, pb(nil).int(1, 1)})
+	b := packet(msgs...)
+	b = append(b, byte(len(classes)), byte(len(classes)>>8))
+	for _, c := range classes {
+		b = append(b, byte(c.id), byte(c.id>>8))
+		b = append(b, c.name...)
+		b = append(b, 0)
+		b = append(b, c.dtName...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// testTables are send tables of a miniature player class, with base classes,
+// a nested table, a collapsible table, an exclude, an array and props of
+// every priority.
+var testTables = []pb{
+	sendTable("DT_BaseEntity",
+		sendProp(PropInt, "m_flSimulationTime", PropUnsigned|PropChangesOften, 128, ""),
+		sendProp(PropVector, "m_vecOrigin", PropNoScale, 128, ""),
+		sendProp(PropInt, "m_iTeamNum", 0, 128, ""),
+	),
+	sendTable("DT_Local",
+		sendProp(PropFloat, "m_flFallVelocity", PropNoScale, 128, ""),
+	),
+	sendTable("DT_BasePlayer",
+		sendProp(PropDataTable, "baseclass", 0, 128, "DT_BaseEntity"),
+		sendProp(PropInt, "m_iHealth", 0, 128, ""),
+		sendProp(PropDataTable, "localdata", 0, 128, "DT_Local"),
+	),
+	sendTable("DT_CSNonLocal",
+		sendProp(PropFloat, "m_angEyeAngles[0]", 0, 128, "").int(9, 10),
+	),
+	sendTable("DT_CSPlayer",
+		sendProp(PropDataTable, "baseclass", 0, 128, "DT_BasePlayer"),
+		sendProp(PropInt, "m_iTeamNum", PropExclude, 128, "DT_BaseEntity"),
+		sendProp(PropDataTable, "nonlocal", PropCollapsible, 128, "DT_CSNonLocal"),
+		sendProp(PropInt, "000", PropInsideArray, 128, ""),
+		sendProp(PropArray, "m_iAmmo", 0, 128, "").int(6, 32),
+		sendProp(PropInt, "m_nTickBase", 0, 1, ""),
+	),
+}
+
+var testClasses = []testClass{
+	{0, "CBaseEntity", "DT_BaseEntity"},
+	{1, "CBasePlayer", "DT_BasePlayer"},
+	{2, "CCSPlayer", "DT_CSPlayer"},
+}
+
+func propNames(c *ServerClass) []string {
+	var names []string
+	for _, p := range c.Props {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestParseDataTables(t *testing.T) {
+	dt, err := ParseDataTables(writeDataTables(testTables, testClasses))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dt.Tables) != 5 || len(dt.Classes) != 3 || dt.ClassBits() != 2 {
+		t.Fatalf("expected 5 tables and 3 classes of 2 bits, got %d, %d and %d", len(dt.Tables), len(dt.Classes), dt.ClassBits())
+	}
+
+	st := dt.Table("DT_CSPlayer")
+	if st == nil || len(st.Props) != 6 {
+		t.Fatalf("unexpected table %+v", st)
+	}
+	if p := st.Props[4]; p.Type != PropArray || p.NumElements != 32 || p.Priority != 128 {
+		t.Errorf("unexpected array prop %+v", p)
+	}
+
+	player := dt.Class("CCSPlayer")
+	if player == nil || player.ID != 2 || player.DTName != "DT_CSPlayer" {
+		t.Fatalf("unexpected class %+v", player)
+	}
+	// the priority 1 prop first, then the one that changes often, then the
+	// rest in the order the engine swaps them into
+	expected := []string{
+		"m_nTickBase",
+		"m_flSimulationTime",
+		"localdata.m_flFallVelocity",
+		"m_iHealth",
+		"m_angEyeAngles[0]",
+		"m_iAmmo",
+		"m_vecOrigin",
+	}
+	if names := propNames(player); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected props %v, got %v", expected, names)
+	}
+	ammo := player.Props[player.PropIndex("m_iAmmo")]
+	if ammo.ArrayElem == nil || ammo.ArrayElem.Name != "000" || ammo.Table != st {
+		t.Errorf("unexpected array %+v", ammo)
+	}
+	if player.PropIndex("m_iTeamNum") != -1 {
+		t.Errorf("expected m_iTeamNum to be excluded")
+	}
+
+	// the exclude only applies to classes using DT_CSPlayer
+	expected = []string{"m_flSimulationTime", "m_vecOrigin", "m_iTeamNum"}
+	if names := propNames(dt.Class("CBaseEntity")); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected props %v, got %v", expected, names)
+	}
+	if dt.Class("CWorld") != nil {
+		t.Errorf("expected no CWorld class")
+	}
+}
+
+func TestParseDataTablesInvalid(t *testing.T) {
+	valid := writeDataTables(testTables, testClasses)
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "truncated"},
+		{"truncated classes", valid[:len(valid)-5], "truncated"},
+		{"other message", packet(RawMessage{SVCPrint, nil}), "unexpected svc_Print"},
+		{"unknown class table", writeDataTables(testTables, []testClass{{0, "CWorld", "DT_World"}}), `unknown send table "DT_World"`},
+		{"unknown sub table", writeDataTables(testTables[2:], testClasses[1:2]), `unknown send table "DT_BaseEntity"`},
+		{"array without element", writeDataTables([]pb{
+			sendTable("DT_Array", sendProp(PropArray, "m_a", 0, 128, "")),
+		}, []testClass{{0, "CArray", "DT_Array"}}), "no element type"},
+		{"int bits", writeDataTables([]pb{
+			sendTable("DT_Bits", sendProp(PropInt, "m_i", 0, 128, "").int(9, 33)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Int prop m_i has 33 bits in class CBits"},
+		{"negative int bits", writeDataTables([]pb{
+			sendTable("DT_Bits", sendProp(PropInt, "m_i", PropUnsigned, 128, "").int(9, -1)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "has -1 bits"},
+		{"int64 without bits", writeDataTables([]pb{
+			sendTable("DT_Bits", sendProp(PropInt64, "m_l", 0, 128, "")),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Int64 prop m_l has 0 bits"},
+		{"quantized float bits", writeDataTables([]pb{
+			sendTable("DT_Bits", sendProp(PropFloat, "m_f", 0, 128, "").int(9, 40)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Float prop m_f has 40 bits"},
+		{"cell coord bits", writeDataTables([]pb{
+			sendTable("DT_Bits", sendProp(PropVector, "m_v", PropCellCoord, 128, "").int(9, 33)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Vector prop m_v has 33 bits"},
+		{"array element bits", writeDataTables([]pb{
+			sendTable("DT_Bits",
+				sendProp(PropInt, "000", PropInsideArray, 128, "").int(9, 64),
+				sendProp(PropArray, "m_a", 0, 128, "").int(6, 4)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Int prop 000 has 64 bits"},
+		{"array elements", writeDataTables([]pb{
+			sendTable("DT_Bits",
+				sendProp(PropInt, "000", PropInsideArray, 128, "").int(9, 8),
+				sendProp(PropArray, "m_a", 0, 128, "").int(6, -1)),
+		}, []testClass{{0, "CBits", "DT_Bits"}}), "Array prop m_a has -1 elements"},
+		{"recursive table", writeDataTables([]pb{
+			sendTable("DT_Loop", sendProp(PropDataTable, "loop", 0, 128, "DT_Loop")),
+		}, []testClass{{0, "CLoop", "DT_Loop"}}), "nested too deeply"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDataTables(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestPropStrings(t *testing.T) {
+	if s := PropVectorXY.String(); s != "VectorXY" {
+		t.Errorf("expected VectorXY, got %s", s)
+	}
+	if s := PropType(9).String(); s != "PropType(9)" {
+		t.Errorf("expected PropType(9), got %s", s)
+	}
+	if !(PropCoordMP | PropChangesOften).Has(PropChangesOften) || PropCoordMP.Has(PropCoordMP|PropVarInt) {
+		t.Errorf("unexpected Has results")
+	}
+	if PropVarInt != 1<<19 || PropCellCoordIntegral != 1<<17 {
+		t.Errorf("flags do not match SPROP_*")
+	}
+}
@@ -0,0 +1,482 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ajmadsen/replayanalyzer/bitstream"
+)
+
+// Entity limits of the engine.
+const (
+	// MaxEntities is the number of entity slots.
+	MaxEntities = 1 << 11
+	// serialBits is the size of the serial numbers of entities.
+	serialBits = 10
+)
+
+// Entity is a networked entity and the decoded values of its props.
+type Entity struct {
+	Index  int
+	Serial int
+	Class  *ServerClass
+	// InPVS is false when the entity left the potentially visible set of
+	// the recording client, and its props are no longer updated.
+	InPVS bool
+	// Props holds the values of Class.Props, nil for props that were never
+	// sent. See decodeProp for the types of the values.
+	Props []interface{}
+}
+
+// Prop returns the value of the prop name, or nil if the entity has no such
+// prop or it was never sent.
+func (e *Entity) Prop(name string) interface{} {
+	if i := e.Class.PropIndex(name); i >= 0 {
+		return e.Props[i]
+	}
+	return nil
+}
+
+// EntityOp is what happened to an entity in an EntityEvent.
+type EntityOp int
+
+const (
+	// EntityCreated entities are new, their props are those of the
+	// baseline and the update that created them.
+	EntityCreated EntityOp = iota
+	// EntityEntered entities entered the PVS again.
+	EntityEntered
+	// EntityLeft entities left the PVS and are no longer updated.
+	EntityLeft
+	// EntityDeleted entities are removed from the table.
+	EntityDeleted
+)
+
+var entityOpNames = []string{
+	EntityCreated: "created",
+	EntityEntered: "entered",
+	EntityLeft:    "left",
+	EntityDeleted: "deleted",
+}
+
+func (op EntityOp) String() string {
+	if op >= 0 && int(op) < len(entityOpNames) {
+		return entityOpNames[op]
+	}
+	return fmt.Sprintf("EntityOp(%d)", int(op))
+}
+
+// EntityEvent is passed to the hooks of Entities.OnEntity.
+type EntityEvent struct {
+	Tick   int
+	Op     EntityOp
+	Entity *Entity
+}
+
+// PropChange is passed to the hooks of Entities.OnProp and OnClass.
+type PropChange struct {
+	Tick   int
+	Entity *Entity
+	// Index is the index of Prop in Entity.Class.Props.
+	Index int
+	Prop  *FlatProp
+	// Old is nil for props of created entities.
+	Old, New interface{}
+}
+
+// classHooks are the hooks registered for a server class.
+type classHooks struct {
+	entity []func(EntityEvent)
+	all    []func(PropChange)
+	props  map[string][]func(PropChange)
+	// byIndex holds the hooks of props by prop index, once the class is
+	// known
+	byIndex [][]func(PropChange)
+}
+
+// Entities is the entity table of a demo, kept up to date from its
+// PacketEntities messages.
+type Entities struct {
+	tables   *SendTables
+	entities [MaxEntities]*Entity
+
+	// baselines holds the instance baselines of classes by class id, and
+	// decoded the decoded baselines
+	baselines map[int][]byte
+	decoded   map[int][]interface{}
+	// frameBaselines are the entity states the server deltas new entities
+	// from, by baseline slot and entity index
+	frameBaselines [2]map[int]*Entity
+
+	hooks map[string]*classHooks
+
+	// props is reused for the props of delta updates
+	props []interface{}
+	r     bitstream.Reader
+}
+
+// NewEntities returns an empty entity table. SetTables must be called before
+// the first update.
+func NewEntities() *Entities {
+	return &Entities{
+		baselines:      map[int][]byte{},
+		decoded:        map[int][]interface{}{},
+		frameBaselines: [2]map[int]*Entity{{}, {}},
+		hooks:          map[string]*classHooks{},
+	}
+}
+
+// SetTables sets the send tables the entities are decoded with, which come
+// from the DataTables frame of the demo.
+func (es *Entities) SetTables(st *SendTables) {
+	es.tables = st
+	es.decoded = map[int][]interface{}{}
+	for name := range es.hooks {
+		es.compileHooks(name)
+	}
+}
+
+// SetBaseline sets the instance baseline of the server class with id, the
+// encoded props new entities of the class start from. Baselines come from
+// the "instancebaseline" string table.
+func (es *Entities) SetBaseline(classID int, data []byte) {
+	es.baselines[classID] = data
+	delete(es.decoded, classID)
+}
+
+// Entity returns the entity at index, or nil.
+func (es *Entities) Entity(index int) *Entity {
+	if index < 0 || index >= MaxEntities {
+		return nil
+	}
+	return es.entities[index]
+}
+
+// ByClass returns the entities of the server class name, in index order.
+func (es *Entities) ByClass(name string) []*Entity {
+	var found []*Entity
+	for _, e := range es.entities {
+		if e != nil && e.Class.Name == name {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// OnEntity registers fn to be called when an entity of the server class
+// name is created, enters or leaves the PVS, or is deleted. The props of
+// created entities are set, and their prop hooks called, before fn.
+func (es *Entities) OnEntity(class string, fn func(EntityEvent)) {
+	h := es.classHooks(class)
+	h.entity = append(h.entity, fn)
+}
+
+// OnClass registers fn to be called for every change of a prop of an entity
+// of the server class name, such as "CCSPlayer".
+func (es *Entities) OnClass(class string, fn func(PropChange)) {
+	h := es.classHooks(class)
+	h.all = append(h.all, fn)
+}
+
+// OnProp registers fn to be called when the prop named prop, such as
+// "m_iHealth" or "m_vecOrigin", changes on an entity of the server class
+// name. Prop names are those of FlatProp.Name.
+func (es *Entities) OnProp(class, prop string, fn func(PropChange)) {
+	h := es.classHooks(class)
+	h.props[prop] = append(h.props[prop], fn)
+	es.compileHooks(class)
+}
+
+func (es *Entities) classHooks(class string) *classHooks {
+	h := es.hooks[class]
+	if h == nil {
+		h = &classHooks{props: map[string][]func(PropChange){}}
+		es.hooks[class] = h
+	}
+	return h
+}
+
+// compileHooks resolves the prop hooks of class to prop indexes.
+func (es *Entities) compileHooks(class string) {
+	h := es.hooks[class]
+	h.byIndex = nil
+	if es.tables == nil {
+		return
+	}
+	c := es.tables.Class(class)
+	if c == nil {
+		return
+	}
+	h.byIndex = make([][]func(PropChange), len(c.Props))
+	for i := range c.Props {
+		h.byIndex[i] = h.props[c.Props[i].Name]
+	}
+}
+
+// Update applies a PacketEntities message received at tick to the table,
+// calling the hooks of the entities that changed.
+func (es *Entities) Update(tick int, m *PacketEntities) error {
+	if es.tables == nil {
+		return fmt.Errorf("demo: %v before the data tables", SVCPacketEntities)
+	}
+	baseline := int(m.Baseline) & 1
+	if m.UpdateBaseline {
+		// the entities entering in this update are the new baselines of
+		// the other slot, which starts out as this one
+		other := map[int]*Entity{}
+		for i, e := range es.frameBaselines[baseline] {
+			other[i] = e
+		}
+		es.frameBaselines[1-baseline] = other
+	}
+
+	// only deltas start entities from the frame baselines, full updates
+	// start them from the instance baselines
+	var from, to map[int]*Entity
+	if m.IsDelta {
+		from = es.frameBaselines[baseline]
+	}
+	if m.UpdateBaseline {
+		to = es.frameBaselines[1-baseline]
+	}
+
+	r := &es.r
+	r.Reset(m.EntityData)
+	var updated map[int]bool
+	if !m.IsDelta {
+		updated = map[int]bool{}
+	}
+	index := -1
+	for n := 0; n < int(m.UpdatedEntries); n++ {
+		index += 1 + int(r.ReadUBitVar())
+		if index >= MaxEntities {
+			return fmt.Errorf("demo: entity index %d out of range at tick %d", index, tick)
+		}
+		if updated != nil {
+			updated[index] = true
+		}
+
+		// a leave flag, then either an enter or a delete flag
+		leave, flag := r.ReadBit(), r.ReadBit()
+		if r.Err() != nil {
+			return fmt.Errorf("demo: %v truncated at entity %d at tick %d", SVCPacketEntities, index, tick)
+		}
+		var err error
+		switch {
+		case leave:
+			es.leave(tick, index, flag)
+		case flag:
+			err = es.enter(tick, index, from, to)
+		default:
+			err = es.delta(tick, index)
+		}
+		if err != nil {
+			return err
+		}
+		if r.Err() != nil {
+			return fmt.Errorf("demo: %v truncated at entity %d at tick %d", SVCPacketEntities, index, tick)
+		}
+	}
+
+	if updated != nil {
+		// a full update only holds the entities that still exist
+		for i, e := range es.entities {
+			if e != nil && !updated[i] {
+				es.leave(tick, i, true)
+			}
+		}
+	}
+	return nil
+}
+
+// enter creates the entity at index, or makes it enter the PVS again. Either
+// way its props are read as a delta from its baseline in from, or else its
+// instance baseline. The props are stored in to, if not nil, as the new
+// frame baseline.
+func (es *Entities) enter(tick, index int, from, to map[int]*Entity) error {
+	r := &es.r
+	classID := int(r.ReadBits(es.tables.ClassBits()))
+	serial := int(r.ReadBits(serialBits))
+	c := es.tables.ClassByID(classID)
+	if c == nil {
+		return fmt.Errorf("demo: entity %d has unknown class %d at tick %d", index, classID, tick)
+	}
+
+	var props []interface{}
+	if b := from[index]; b != nil && b.Class == c {
+		props = append(props, b.Props...)
+	} else {
+		base, err := es.instanceBaseline(c)
+		if err != nil {
+			return err
+		}
+		props = append(props, base...)
+	}
+	if err := es.readProps(r, c, props); err != nil {
+		return fmt.Errorf("%v of entity %d (%s) at tick %d", err, index, c.Name, tick)
+	}
+	if to != nil {
+		to[index] = &Entity{Class: c, Props: append([]interface{}(nil), props...)}
+	}
+
+	e := es.entities[index]
+	op := EntityEntered
+	if e == nil || e.Class != c || e.Serial != serial {
+		if e != nil {
+			es.delete(tick, index)
+		}
+		e = &Entity{Index: index, Serial: serial, Class: c, Props: make([]interface{}, len(c.Props))}
+		es.entities[index] = e
+		op = EntityCreated
+	}
+	e.InPVS = true
+	es.setProps(tick, e, props)
+	es.fireEntity(EntityEvent{Tick: tick, Op: op, Entity: e})
+	return nil
+}
+
+// delta updates the props of the entity at index.
+func (es *Entities) delta(tick, index int) error {
+	e := es.entities[index]
+	if e == nil {
+		return fmt.Errorf("demo: update of missing entity %d at tick %d", index, tick)
+	}
+	props := append(es.props[:0], e.Props...)
+	if err := es.readProps(&es.r, e.Class, props); err != nil {
+		return fmt.Errorf("%v of entity %d (%s) at tick %d", err, index, e.Class.Name, tick)
+	}
+	es.props = props
+	es.setProps(tick, e, props)
+	return nil
+}
+
+// leave makes the entity at index leave the PVS, deleting it if del is set.
+func (es *Entities) leave(tick, index int, del bool) {
+	e := es.entities[index]
+	if e == nil {
+		return
+	}
+	if del {
+		es.delete(tick, index)
+		return
+	}
+	e.InPVS = false
+	es.fireEntity(EntityEvent{Tick: tick, Op: EntityLeft, Entity: e})
+}
+
+func (es *Entities) delete(tick, index int) {
+	e := es.entities[index]
+	es.entities[index] = nil
+	es.fireEntity(EntityEvent{Tick: tick, Op: EntityDeleted, Entity: e})
+}
+
+func (es *Entities) fireEntity(ev EntityEvent) {
+	if h := es.hooks[ev.Entity.Class.Name]; h != nil {
+		for _, fn := range h.entity {
+			fn(ev)
+		}
+	}
+}
+
+// setProps sets the props of e to props, calling the prop hooks for each
+// value that changed.
+func (es *Entities) setProps(tick int, e *Entity, props []interface{}) {
+	h := es.hooks[e.Class.Name]
+	for i, v := range props {
+		old := e.Props[i]
+		if equalValues(old, v) {
+			continue
+		}
+		e.Props[i] = v
+		if h == nil {
+			continue
+		}
+		ch := PropChange{Tick: tick, Entity: e, Index: i, Prop: &e.Class.Props[i], Old: old, New: v}
+		for _, fn := range h.all {
+			fn(ch)
+		}
+		if h.byIndex != nil {
+			for _, fn := range h.byIndex[i] {
+				fn(ch)
+			}
+		}
+	}
+}
+
+// readProps reads the changed props of an entity of class c into props.
+func (es *Entities) readProps(r *bitstream.Reader, c *ServerClass, props []interface{}) error {
+	indices, err := readFieldIndices(r, len(c.Props))
+	if err != nil {
+		return err
+	}
+	for _, i := range indices {
+		props[i] = decodeProp(r, &c.Props[i])
+	}
+	return nil
+}
+
+// readFieldIndices reads the indices of the props in an entity update,
+// which are in increasing order.
+func readFieldIndices(r *bitstream.Reader, numProps int) ([]int, error) {
+	newWay := r.ReadBit()
+	var indices []int
+	index := -1
+	for {
+		index = readFieldIndex(r, index, newWay)
+		if r.Err() != nil {
+			return nil, errors.New("demo: prop indices truncated")
+		}
+		if index == -1 {
+			return indices, nil
+		}
+		if index >= numProps {
+			return nil, fmt.Errorf("demo: prop index %d out of range", index)
+		}
+		indices = append(indices, index)
+	}
+}
+
+// readFieldIndex reads the index of the next prop after last, or -1 at the
+// end of the props.
+func readFieldIndex(r *bitstream.Reader, last int, newWay bool) int {
+	if newWay && r.ReadBit() {
+		return last + 1
+	}
+	var v uint32
+	if newWay && r.ReadBit() {
+		v = r.ReadBits(3)
+	} else {
+		v = r.ReadBits(7)
+		switch v & (32 | 64) {
+		case 32:
+			v = v&^96 | r.ReadBits(2)<<5
+		case 64:
+			v = v&^96 | r.ReadBits(4)<<5
+		case 96:
+			v = v&^96 | r.ReadBits(7)<<5
+		}
+	}
+	if v == 0xfff {
+		return -1
+	}
+	return last + 1 + int(v)
+}
+
+// instanceBaseline returns the decoded instance baseline of c.
+func (es *Entities) instanceBaseline(c *ServerClass) ([]interface{}, error) {
+	if props, ok := es.decoded[c.ID]; ok {
+		return props, nil
+	}
+	props := make([]interface{}, len(c.Props))
+	if data, ok := es.baselines[c.ID]; ok {
+		r := bitstream.NewReader(data)
+		if err := es.readProps(r, c, props); err != nil {
+			return nil, fmt.Errorf("%v in baseline of %s", err, c.Name)
+		}
+		if r.Err() != nil {
+			return nil, fmt.Errorf("demo: baseline of %s truncated", c.Name)
+		}
+	}
+	es.decoded[c.ID] = props
+	return props, nil
+}
@@ -0,0 +1,392 @@
+package demo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// entityTables are send tables of a player and a ball, with props of every
+// type.
+var entityTables = []pb{
+	sendTable("DT_Player",
+		sendProp(PropInt, "m_iHealth", PropUnsigned, 128, "").int(9, 8),
+		sendProp(PropVector, "m_vecOrigin", PropCoordMP, 128, ""),
+		sendProp(PropString, "m_szName", 0, 128, ""),
+		sendProp(PropInt, "000", PropInsideArray|PropUnsigned, 128, "").int(9, 4),
+		sendProp(PropArray, "m_iAmmo", 0, 128, "").int(6, 4),
+		sendProp(PropFloat, "m_flSpeed", PropNoScale, 128, ""),
+		sendProp(PropInt, "m_iScore", PropVarInt, 128, "").int(9, 32),
+		sendProp(PropInt64, "m_xuid", PropUnsigned, 128, "").int(9, 64),
+	),
+	sendTable("DT_Ball",
+		sendProp(PropInt, "m_iBounce", PropUnsigned, 128, "").int(9, 4),
+	),
+}
+
+// Prop indexes of the flattened classes.
+const (
+	propHealth = iota
+	propOrigin
+	propName
+	propAmmo
+	propSpeed
+	propScore
+	propXUID
+
+	propBounce = 0
+)
+
+func entityTestTables(t *testing.T) *SendTables {
+	st, err := ParseDataTables(writeDataTables(entityTables, []testClass{
+		{0, "CCSPlayer", "DT_Player"},
+		{1, "CBall", "DT_Ball"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// propWrite is a prop of an entity update and the encoding of its value.
+type propWrite struct {
+	index int
+	write func(w *bitWriter)
+}
+
+func bitsOf(v uint64, n int) func(w *bitWriter) {
+	return func(w *bitWriter) { w.bits(v, n) }
+}
+
+// writeFieldIndex encodes the index of a prop after last.
+func writeFieldIndex(w *bitWriter, last, index int, newWay bool) {
+	d := uint64(index - last - 1)
+	if newWay {
+		if d == 0 {
+			w.bit(true)
+			return
+		}
+		w.bit(false)
+		if d < 8 {
+			w.bit(true).bits(d, 3)
+			return
+		}
+		w.bit(false)
+	}
+	switch {
+	case d < 32:
+		w.bits(d, 7)
+	case d < 128:
+		w.bits(d&31|32, 7).bits(d>>5, 2)
+	case d < 512:
+		w.bits(d&31|64, 7).bits(d>>5, 4)
+	default:
+		w.bits(d&31|96, 7).bits(d>>5, 7)
+	}
+}
+
+// writeProps encodes the props of an entity update or baseline.
+func writeProps(w *bitWriter, props []propWrite, newWay bool) {
+	w.bit(newWay)
+	last := -1
+	for _, p := range props {
+		writeFieldIndex(w, last, p.index, newWay)
+		last = p.index
+	}
+	// the end marker is an index 0xfff after the last
+	writeFieldIndex(w, last, last+1+0xfff, newWay)
+	for _, p := range props {
+		p.write(w)
+	}
+}
+
+// entityUpdate is an entity of a PacketEntities message.
+type entityUpdate struct {
+	index int
+	// op is "enter", "delta", "leave" or "delete"
+	op            string
+	class, serial int
+	props         []propWrite
+}
+
+func packetEntities(delta bool, newWay bool, updates ...entityUpdate) *PacketEntities {
+	w := new(bitWriter)
+	last := -1
+	for _, u := range updates {
+		d := uint64(u.index - last - 1)
+		last = u.index
+		switch {
+		case d < 16:
+			w.bits(d, 6)
+		case d < 256:
+			w.bits(d&15|16, 6).bits(d>>4, 4)
+		default:
+			w.bits(d&15|32, 6).bits(d>>4, 8)
+		}
+		switch u.op {
+		case "enter":
+			w.bit(false).bit(true).bits(uint64(u.class), 2).bits(uint64(u.serial), serialBits)
+			writeProps(w, u.props, newWay)
+		case "delta":
+			w.bit(false).bit(false)
+			writeProps(w, u.props, newWay)
+		case "leave":
+			w.bit(true).bit(false)
+		case "delete":
+			w.bit(true).bit(true)
+		}
+	}
+	return &PacketEntities{
+		MaxEntries:     MaxEntities,
+		UpdatedEntries: int32(len(updates)),
+		IsDelta:        delta,
+		EntityData:     w.b,
+	}
+}
+
+// hookLog records the calls of entity hooks as strings.
+type hookLog struct {
+	calls []string
+}
+
+func (l *hookLog) entity(ev EntityEvent) {
+	l.add("%d %s %s #%d", ev.Tick, ev.Entity.Class.Name, ev.Op, ev.Entity.Index)
+}
+
+func (l *hookLog) prop(ch PropChange) {
+	l.add("%d %s #%d %s %v -> %v", ch.Tick, ch.Entity.Class.Name, ch.Entity.Index, ch.Prop.Name, ch.Old, ch.New)
+}
+
+func (l *hookLog) add(format string, args ...interface{}) {
+	l.calls = append(l.calls, fmt.Sprintf(format, args...))
+}
+
+func (l *hookLog) take() []string {
+	calls := l.calls
+	l.calls = nil
+	return calls
+}
+
+func TestEntities(t *testing.T) {
+	for _, newWay := range []bool{false, true} {
+		es := NewEntities()
+		log := &hookLog{}
+		// hooks registered before the tables are known
+		es.OnProp("CCSPlayer", "m_iHealth", log.prop)
+		es.OnEntity("CCSPlayer", log.entity)
+		es.SetTables(entityTestTables(t))
+		es.OnClass("CBall", log.prop)
+		es.OnEntity("CBall", log.entity)
+		es.OnProp("CCSPlayer", "m_iAmmo", log.prop)
+		es.OnProp("CWorld", "m_iHealth", log.prop)
+
+		baseline := new(bitWriter)
+		writeProps(baseline, []propWrite{{propHealth, bitsOf(100, 8)}}, newWay)
+		es.SetBaseline(0, baseline.b)
+
+		update := func(tick int, m *PacketEntities, expected ...string) {
+			t.Helper()
+			if err := es.Update(tick, m); err != nil {
+				t.Fatalf("tick %d: %v", tick, err)
+			}
+			if calls := log.take(); !reflect.DeepEqual(calls, expected) {
+				t.Errorf("tick %d: expected hooks\n%s\ngot\n%s", tick, strings.Join(expected, "\n"), strings.Join(calls, "\n"))
+			}
+		}
+
+		update(10, packetEntities(false, newWay,
+			entityUpdate{index: 1, op: "enter", class: 0, serial: 5, props: []propWrite{
+				{propOrigin, func(w *bitWriter) { w.coordMP(1).coordMP(2).coordMP(0) }},
+				{propName, func(w *bitWriter) { w.bits(3, 9).bytes([]byte("bob")) }},
+				{propAmmo, func(w *bitWriter) { w.bits(2, 3).bits(3, 4).bits(7, 4) }},
+			}},
+			entityUpdate{index: 300, op: "enter", class: 1, serial: 1, props: []propWrite{
+				{propBounce, bitsOf(2, 4)},
+			}},
+		),
+			"10 CCSPlayer #1 m_iHealth <nil> -> 100",
+			"10 CCSPlayer #1 m_iAmmo <nil> -> [3 7]",
+			"10 CCSPlayer created #1",
+			"10 CBall #300 m_iBounce <nil> -> 2",
+			"10 CBall created #300",
+		)
+		player := es.Entity(1)
+		expected := []interface{}{int32(100), Vector{1, 2, 0}, "bob", []interface{}{int32(3), int32(7)}, nil, nil, nil}
+		if player == nil || player.Serial != 5 || !player.InPVS || !reflect.DeepEqual(player.Props, expected) {
+			t.Fatalf("unexpected player %+v", player)
+		}
+		if v := player.Prop("m_szName"); v != "bob" {
+			t.Errorf("expected bob, got %v", v)
+		}
+		if len(es.ByClass("CBall")) != 1 || es.Entity(300).Class.Name != "CBall" {
+			t.Errorf("expected a ball at 300")
+		}
+
+		update(11, packetEntities(true, newWay,
+			entityUpdate{index: 1, op: "delta", props: []propWrite{
+				{propHealth, bitsOf(75, 8)},
+				{propSpeed, func(w *bitWriter) { w.float(250.5) }},
+				{propScore, func(w *bitWriter) { w.varint(5) }},
+				{propXUID, bitsOf(76561198000000001, 64)},
+			}},
+			// an update without changes does not call hooks
+			entityUpdate{index: 300, op: "delta", props: []propWrite{
+				{propBounce, bitsOf(2, 4)},
+			}},
+		),
+			"11 CCSPlayer #1 m_iHealth 100 -> 75",
+		)
+		expected = []interface{}{int32(75), Vector{1, 2, 0}, "bob", []interface{}{int32(3), int32(7)}, float32(250.5), int32(-3), int64(76561198000000001)}
+		if !reflect.DeepEqual(player.Props, expected) {
+			t.Errorf("expected props %v, got %v", expected, player.Props)
+		}
+
+		update(12, packetEntities(true, newWay,
+			entityUpdate{index: 1, op: "leave"},
+			entityUpdate{index: 300, op: "delete"},
+		),
+			"12 CCSPlayer left #1",
+			"12 CBall deleted #300",
+		)
+		if player.InPVS || es.Entity(1) != player || es.Entity(300) != nil {
+			t.Errorf("expected the player to leave and the ball to be deleted")
+		}
+
+		// entering again starts from the baseline
+		update(13, packetEntities(true, newWay,
+			entityUpdate{index: 1, op: "enter", class: 0, serial: 5, props: []propWrite{
+				{propAmmo, func(w *bitWriter) { w.bits(1, 3).bits(3, 4) }},
+			}},
+		),
+			"13 CCSPlayer #1 m_iHealth 75 -> 100",
+			"13 CCSPlayer #1 m_iAmmo [3 7] -> [3]",
+			"13 CCSPlayer entered #1",
+		)
+		if es.Entity(1) != player || !player.InPVS || player.Prop("m_szName") != nil {
+			t.Errorf("unexpected player %+v", player)
+		}
+
+		// a new serial is a new entity
+		update(14, packetEntities(true, newWay,
+			entityUpdate{index: 1, op: "enter", class: 0, serial: 6},
+		),
+			"14 CCSPlayer deleted #1",
+			"14 CCSPlayer #1 m_iHealth <nil> -> 100",
+			"14 CCSPlayer created #1",
+		)
+
+		// a full update deletes the entities it does not hold
+		update(15, packetEntities(false, newWay), "15 CCSPlayer deleted #1")
+		if es.Entity(1) != nil {
+			t.Errorf("expected no entities")
+		}
+	}
+}
+
+func TestEntitiesFrameBaseline(t *testing.T) {
+	es := NewEntities()
+	es.SetTables(entityTestTables(t))
+
+	m := packetEntities(true, false, entityUpdate{index: 5, op: "enter", class: 1, serial: 1, props: []propWrite{
+		{propBounce, bitsOf(9, 4)},
+	}})
+	m.UpdateBaseline = true
+	if err := es.Update(1, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Update(2, packetEntities(true, false, entityUpdate{index: 5, op: "delete"})); err != nil {
+		t.Fatal(err)
+	}
+
+	// entities entering in baseline 1 start from the update above
+	m = packetEntities(true, false, entityUpdate{index: 5, op: "enter", class: 1, serial: 2})
+	m.Baseline = 1
+	if err := es.Update(3, m); err != nil {
+		t.Fatal(err)
+	}
+	if v := es.Entity(5).Prop("m_iBounce"); v != int32(9) {
+		t.Errorf("expected the frame baseline 9, got %v", v)
+	}
+
+	// those in baseline 0 from the instance baseline, of which there is none
+	if err := es.Update(4, packetEntities(true, false, entityUpdate{index: 6, op: "enter", class: 1, serial: 1})); err != nil {
+		t.Fatal(err)
+	}
+	if v := es.Entity(6).Prop("m_iBounce"); v != nil {
+		t.Errorf("expected no value, got %v", v)
+	}
+
+	// full updates ignore the frame baselines
+	m = packetEntities(false, false, entityUpdate{index: 5, op: "enter", class: 1, serial: 3})
+	m.Baseline = 1
+	if err := es.Update(5, m); err != nil {
+		t.Fatal(err)
+	}
+	if v := es.Entity(5).Prop("m_iBounce"); v != nil {
+		t.Errorf("expected the instance baseline, got %v", v)
+	}
+}
+
+func TestEntitiesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *PacketEntities
+		err  string
+	}{
+		{"unknown class", packetEntities(true, false, entityUpdate{index: 1, op: "enter", class: 3}), "unknown class 3"},
+		{"missing entity", packetEntities(true, false, entityUpdate{index: 2, op: "delta"}), "update of missing entity 2"},
+		{"prop out of range", packetEntities(true, false, entityUpdate{index: 1, op: "enter", class: 1, props: []propWrite{
+			{5, bitsOf(0, 4)},
+		}}), "prop index 5 out of range"},
+		{"index out of range", packetEntities(true, false, entityUpdate{index: MaxEntities, op: "leave"}), "entity index 2048 out of range"},
+		{"truncated", &PacketEntities{UpdatedEntries: 1, IsDelta: true, EntityData: []byte{0x10}}, "truncated"},
+		{"truncated props", &PacketEntities{UpdatedEntries: 1, IsDelta: true, EntityData: packetEntities(true, false, entityUpdate{index: 1, op: "enter", class: 1, props: []propWrite{
+			{propBounce, bitsOf(0, 4)},
+		}}).EntityData[:3]}, "truncated"},
+	}
+	for _, tt := range tests {
+		es := NewEntities()
+		es.SetTables(entityTestTables(t))
+		err := es.Update(1, tt.m)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	if err := NewEntities().Update(1, &PacketEntities{}); err == nil {
+		t.Errorf("expected an error before the data tables")
+	}
+}
+
+func BenchmarkEntitiesUpdate(b *testing.B) {
+	st, err := ParseDataTables(writeDataTables(entityTables, []testClass{{0, "CCSPlayer", "DT_Player"}, {1, "CBall", "DT_Ball"}}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	es := NewEntities()
+	es.SetTables(st)
+	var updates []entityUpdate
+	for i := 0; i < 10; i++ {
+		updates = append(updates, entityUpdate{index: i, op: "enter", class: 0, serial: 1})
+	}
+	if err := es.Update(0, packetEntities(false, true, updates...)); err != nil {
+		b.Fatal(err)
+	}
+	for i := range updates {
+		updates[i].op = "delta"
+		updates[i].props = []propWrite{
+			{propHealth, bitsOf(uint64(i), 8)},
+			{propOrigin, func(w *bitWriter) { w.coordMP(i).coordMP(-i).coordMP(64) }},
+			{propSpeed, func(w *bitWriter) { w.float(250) }},
+		}
+	}
+	m := packetEntities(true, true, updates...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := es.Update(i, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
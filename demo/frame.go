@@ -0,0 +1,238 @@
+package demo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Command is the type of a demo frame.
+type Command uint8
+
+// Demo commands.
+const (
+	// Signon frames hold the network messages that set up the game, such
+	// as the server info and string tables.
+	Signon Command = iota + 1
+	// Packet frames hold the network messages received in a tick.
+	Packet
+	// SyncTick marks the end of the signon data.
+	SyncTick
+	// ConsoleCmd frames hold a console command run by the recording client.
+	ConsoleCmd
+	// UserCmd frames hold a user command sent by the recording client.
+	UserCmd
+	// DataTables frames hold the send tables and server classes.
+	DataTables
+	// Stop ends the demo.
+	Stop
+	// CustomData frames hold data for a client side callback.
+	CustomData
+	// StringTables frames hold a snapshot of the string tables.
+	StringTables
+)
+
+var commandNames = []string{
+	Signon:       "dem_signon",
+	Packet:       "dem_packet",
+	SyncTick:     "dem_synctick",
+	ConsoleCmd:   "dem_consolecmd",
+	UserCmd:      "dem_usercmd",
+	DataTables:   "dem_datatables",
+	Stop:         "dem_stop",
+	CustomData:   "dem_customdata",
+	StringTables: "dem_stringtables",
+}
+
+func (c Command) String() string {
+	if c > 0 && int(c) < len(commandNames) {
+		return commandNames[c]
+	}
+	return fmt.Sprintf("Command(%d)", uint8(c))
+}
+
+// Vector is a position or a set of angles.
+type Vector struct {
+	X, Y, Z float32
+}
+
+// SplitCmdInfo is the view of one split screen player when a packet was
+// recorded.
+type SplitCmdInfo struct {
+	Flags            int32
+	ViewOrigin       Vector
+	ViewAngles       Vector
+	LocalViewAngles  Vector
+	ViewOrigin2      Vector
+	ViewAngles2      Vector
+	LocalViewAngles2 Vector
+}
+
+// CmdInfo is the view information stored with Signon and Packet frames, one
+// entry per split screen slot.
+type CmdInfo [2]SplitCmdInfo
+
+// cmdInfoSize is the size of CmdInfo in a demo file.
+const cmdInfoSize = 2 * (4 + 6*12)
+
+// maxFrameData bounds the payload of a frame, so that a corrupt length does
+// not allocate the whole memory.
+const maxFrameData = 64 << 20
+
+// Frame is one frame of a demo.
+type Frame struct {
+	Command    Command
+	Tick       int
+	PlayerSlot int
+
+	// CmdInfo, SeqIn and SeqOut are set for Signon and Packet frames.
+	CmdInfo CmdInfo
+	SeqIn   int
+	SeqOut  int
+	// OutgoingSequence is set for UserCmd frames.
+	OutgoingSequence int
+	// CallbackIndex is set for CustomData frames.
+	CallbackIndex int
+
+	// Data is the payload of the frame: the network messages of Signon and
+	// Packet frames, the command of ConsoleCmd frames, and the raw data of
+	// UserCmd, DataTables, CustomData and StringTables frames.
+	Data []byte
+}
+
+// Reader reads the frames of a demo one at a time.
+type Reader struct {
+	r      *bufio.Reader
+	header *Header
+	done   bool
+	buf    [cmdInfoSize]byte
+}
+
+// NewReader reads the header of the demo in r. Frames are read from r as
+// they are requested, so the demo does not need to fit in memory.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	h, err := ReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: br, header: h}, nil
+}
+
+// Header returns the header of the demo.
+func (dr *Reader) Header() *Header {
+	return dr.header
+}
+
+// Next reads the next frame. It returns io.EOF after the Stop frame, or at
+// the end of a demo that is still being recorded. A demo cut off within a
+// frame is an io.ErrUnexpectedEOF.
+func (dr *Reader) Next() (*Frame, error) {
+	if dr.done {
+		return nil, io.EOF
+	}
+
+	var hdr [6]byte
+	if _, err := io.ReadFull(dr.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			dr.done = true
+		}
+		return nil, err
+	}
+	f := &Frame{
+		Command:    Command(hdr[0]),
+		Tick:       int(int32(binary.LittleEndian.Uint32(hdr[1:]))),
+		PlayerSlot: int(hdr[5]),
+	}
+
+	var err error
+	switch f.Command {
+	case Signon, Packet:
+		if err = dr.readCmdInfo(&f.CmdInfo); err != nil {
+			break
+		}
+		if f.SeqIn, err = dr.readInt32(); err != nil {
+			break
+		}
+		if f.SeqOut, err = dr.readInt32(); err != nil {
+			break
+		}
+		f.Data, err = dr.readData()
+	case SyncTick:
+	case ConsoleCmd, DataTables, StringTables:
+		f.Data, err = dr.readData()
+	case UserCmd:
+		if f.OutgoingSequence, err = dr.readInt32(); err != nil {
+			break
+		}
+		f.Data, err = dr.readData()
+	case CustomData:
+		if f.CallbackIndex, err = dr.readInt32(); err != nil {
+			break
+		}
+		f.Data, err = dr.readData()
+	case Stop:
+		dr.done = true
+	default:
+		return nil, fmt.Errorf("demo: unknown command %d at tick %d", uint8(f.Command), f.Tick)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (dr *Reader) readInt32() (int, error) {
+	b := dr.buf[:4]
+	if _, err := io.ReadFull(dr.r, b); err != nil {
+		return 0, err
+	}
+	return int(int32(binary.LittleEndian.Uint32(b))), nil
+}
+
+func (dr *Reader) readData() ([]byte, error) {
+	n, err := dr.readInt32()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > maxFrameData {
+		return nil, fmt.Errorf("demo: invalid frame length %d", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(dr.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (dr *Reader) readCmdInfo(ci *CmdInfo) error {
+	b := dr.buf[:cmdInfoSize]
+	if _, err := io.ReadFull(dr.r, b); err != nil {
+		return err
+	}
+	f32 := func() float32 {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		b = b[4:]
+		return v
+	}
+	vec := func() Vector {
+		return Vector{f32(), f32(), f32()}
+	}
+	for i := range ci {
+		s := &ci[i]
+		s.Flags = int32(binary.LittleEndian.Uint32(b))
+		b = b[4:]
+		s.ViewOrigin = vec()
+		s.ViewAngles = vec()
+		s.LocalViewAngles = vec()
+		s.ViewOrigin2 = vec()
+		s.ViewAngles2 = vec()
+		s.LocalViewAngles2 = vec()
+	}
+	return nil
+}
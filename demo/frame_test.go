@@ -0,0 +1,152 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFrame encodes f the way the game writes demo frames.
+func writeFrame(w *bytes.Buffer, f *Frame) {
+	put := func(v interface{}) {
+		binary.Write(w, binary.LittleEndian, v)
+	}
+	w.WriteByte(byte(f.Command))
+	put(int32(f.Tick))
+	w.WriteByte(byte(f.PlayerSlot))
+
+	switch f.Command {
+	case Signon, Packet:
+		put(&f.CmdInfo)
+		put(int32(f.SeqIn))
+		put(int32(f.SeqOut))
+	case UserCmd:
+		put(int32(f.OutgoingSequence))
+	case CustomData:
+		put(int32(f.CallbackIndex))
+	}
+	switch f.Command {
+	case SyncTick, Stop:
+	default:
+		put(int32(len(f.Data)))
+		w.Write(f.Data)
+	}
+}
+
+func testDemo(t *testing.T, frames []*Frame) *bytes.Buffer {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, &testHeader); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		writeFrame(&buf, f)
+	}
+	return &buf
+}
+
+var testFrames = []*Frame{
+	{Command: Signon, Tick: 0, CmdInfo: CmdInfo{{Flags: 1, ViewOrigin: Vector{1, 2, 3}}}, SeqIn: 1, SeqOut: 2, Data: []byte("signon")},
+	{Command: DataTables, Tick: 0, Data: []byte("tables")},
+	{Command: StringTables, Tick: 0, Data: []byte("strings")},
+	{Command: SyncTick, Tick: 0},
+	{Command: ConsoleCmd, Tick: 1, Data: []byte("+attack\x00")},
+	{Command: Packet, Tick: 2, PlayerSlot: 1, CmdInfo: CmdInfo{{ViewAngles: Vector{10, 20, 0}}, {LocalViewAngles2: Vector{-1, -2, -3}}}, SeqIn: 3, SeqOut: 4, Data: []byte("packet")},
+	{Command: UserCmd, Tick: 3, OutgoingSequence: 99, Data: []byte("usercmd")},
+	{Command: CustomData, Tick: 4, CallbackIndex: 7, Data: []byte("custom")},
+	{Command: Packet, Tick: 5, Data: []byte{}},
+	{Command: Stop, Tick: 6},
+}
+
+func TestReader(t *testing.T) {
+	buf := testDemo(t, testFrames)
+	buf.WriteString("trailing garbage after dem_stop")
+
+	dr, err := NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dr.Header().MapName != "de_dust2" {
+		t.Errorf("unexpected header %+v", dr.Header())
+	}
+
+	for i, expected := range testFrames {
+		f, err := dr.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(f, expected) {
+			t.Errorf("frame %d: expected %+v, got %+v", i, expected, f)
+		}
+	}
+	if _, err := dr.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after dem_stop, got %v", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	// a demo that is still being recorded ends at a frame boundary
+	buf := testDemo(t, testFrames[:5])
+	dr, err := NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		_, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("expected 5 frames, got %d", n)
+	}
+
+	// one cut off within a frame is corrupt
+	buf = testDemo(t, testFrames[:1])
+	buf.Truncate(buf.Len() - 3)
+	dr, err = NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dr.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	buf := testDemo(t, nil)
+	buf.Write([]byte{42, 0, 0, 0, 0, 0})
+	dr, err := NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dr.Next(); err == nil || !strings.Contains(err.Error(), "unknown command 42") {
+		t.Errorf("expected an unknown command error, got %v", err)
+	}
+
+	buf = testDemo(t, nil)
+	buf.Write([]byte{byte(ConsoleCmd), 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})
+	dr, err = NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dr.Next(); err == nil || !strings.Contains(err.Error(), "invalid frame length -1") {
+		t.Errorf("expected an invalid length error, got %v", err)
+	}
+}
+
+func TestCommandString(t *testing.T) {
+	if s := Packet.String(); s != "dem_packet" {
+		t.Errorf("expected dem_packet, got %v", s)
+	}
+	if s := Command(0).String(); s != "Command(0)" {
+		t.Errorf("expected Command(0), got %v", s)
+	}
+}
@@ -0,0 +1,163 @@
+// Package demo reads Source engine demo files (.dem) as recorded by CS:GO.
+package demo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// HeaderSize is the size of the header at the start of a demo file.
+const HeaderSize = 1072
+
+// Stamp is the magic string a demo file starts with.
+const Stamp = "HL2DEMO\x00"
+
+// DemoProtocol is the demo protocol version written by CS:GO.
+const DemoProtocol = 4
+
+// ErrNotDemo is returned for files that do not start with Stamp.
+var ErrNotDemo = errors.New("demo: not a demo file")
+
+// Header is the header of a demo file.
+type Header struct {
+	// DemoProtocol is the version of the demo file format.
+	DemoProtocol int
+	// NetworkProtocol is the version of the network protocol of the game
+	// build that recorded the demo.
+	NetworkProtocol int
+	// ServerName is the server the demo was recorded on, or its address.
+	ServerName string
+	// ClientName is the player that recorded the demo, or "GOTV Demo" for
+	// server side recordings.
+	ClientName    string
+	MapName       string
+	GameDirectory string
+
+	// PlaybackTime, PlaybackTicks and PlaybackFrames are the length of the
+	// demo. They are zero in demos that are still being recorded.
+	PlaybackTime   time.Duration
+	PlaybackTicks  int
+	PlaybackFrames int
+	// SignonLength is the size in bytes of the signon data following the
+	// header.
+	SignonLength int
+}
+
+// rawHeader is the layout of a header in a demo file.
+type rawHeader struct {
+	Stamp           [8]byte
+	DemoProtocol    int32
+	NetworkProtocol int32
+	ServerName      [260]byte
+	ClientName      [260]byte
+	MapName         [260]byte
+	GameDirectory   [260]byte
+	PlaybackTime    float32
+	PlaybackTicks   int32
+	PlaybackFrames  int32
+	SignonLength    int32
+}
+
+// ReadHeader reads and validates the header of a demo from r, leaving r at
+// the first frame of the demo.
+func ReadHeader(r io.Reader) (*Header, error) {
+	var raw rawHeader
+	if err := binary.Read(r, binary.LittleEndian, &raw); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("demo: short header: %v", err)
+		}
+		return nil, err
+	}
+	if string(raw.Stamp[:]) != Stamp {
+		return nil, ErrNotDemo
+	}
+
+	h := &Header{
+		DemoProtocol:    int(raw.DemoProtocol),
+		NetworkProtocol: int(raw.NetworkProtocol),
+		ServerName:      cString(raw.ServerName[:]),
+		ClientName:      cString(raw.ClientName[:]),
+		MapName:         cString(raw.MapName[:]),
+		GameDirectory:   cString(raw.GameDirectory[:]),
+		PlaybackTime:    time.Duration(float64(raw.PlaybackTime) * float64(time.Second)),
+		PlaybackTicks:   int(raw.PlaybackTicks),
+		PlaybackFrames:  int(raw.PlaybackFrames),
+		SignonLength:    int(raw.SignonLength),
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WriteHeader writes h to w in the layout of a demo file. Strings longer
+// than the 259 bytes a header has room for are an error.
+func WriteHeader(w io.Writer, h *Header) error {
+	raw := rawHeader{
+		DemoProtocol:    int32(h.DemoProtocol),
+		NetworkProtocol: int32(h.NetworkProtocol),
+		PlaybackTime:    float32(h.PlaybackTime.Seconds()),
+		PlaybackTicks:   int32(h.PlaybackTicks),
+		PlaybackFrames:  int32(h.PlaybackFrames),
+		SignonLength:    int32(h.SignonLength),
+	}
+	copy(raw.Stamp[:], Stamp)
+	fields := []struct {
+		dst []byte
+		s   string
+	}{
+		{raw.ServerName[:], h.ServerName},
+		{raw.ClientName[:], h.ClientName},
+		{raw.MapName[:], h.MapName},
+		{raw.GameDirectory[:], h.GameDirectory},
+	}
+	for _, f := range fields {
+		if len(f.s) >= len(f.dst) {
+			return fmt.Errorf("demo: header string too long: %q", f.s)
+		}
+		copy(f.dst, f.s)
+	}
+	return binary.Write(w, binary.LittleEndian, &raw)
+}
+
+func (h *Header) validate() error {
+	switch {
+	case h.DemoProtocol != DemoProtocol:
+		return fmt.Errorf("demo: unsupported demo protocol %d", h.DemoProtocol)
+	case h.PlaybackTime < 0 || h.PlaybackTicks < 0 || h.PlaybackFrames < 0:
+		return fmt.Errorf("demo: invalid playback length %v, %d ticks, %d frames", h.PlaybackTime, h.PlaybackTicks, h.PlaybackFrames)
+	case h.SignonLength < 0:
+		return fmt.Errorf("demo: invalid signon length %d", h.SignonLength)
+	}
+	return nil
+}
+
+// TickRate returns the ticks per second the demo was recorded at, or zero if
+// the demo has no length yet.
+func (h *Header) TickRate() float64 {
+	if h.PlaybackTime <= 0 {
+		return 0
+	}
+	return float64(h.PlaybackTicks) / h.PlaybackTime.Seconds()
+}
+
+// TickInterval returns the time between two ticks of the demo, or zero if
+// the demo has no length yet.
+func (h *Header) TickInterval() time.Duration {
+	if h.PlaybackTicks <= 0 {
+		return 0
+	}
+	return h.PlaybackTime / time.Duration(h.PlaybackTicks)
+}
+
+// cString returns the NUL terminated string at the start of b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
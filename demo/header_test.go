@@ -0,0 +1,96 @@
+package demo
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHeader = Header{
+	DemoProtocol:    4,
+	NetworkProtocol: 13753,
+	ServerName:      "Valve CS:GO EU West Server (srcds129-fra1.Frankfurt)",
+	ClientName:      "GOTV Demo",
+	MapName:         "de_dust2",
+	GameDirectory:   "csgo",
+	PlaybackTime:    2400 * time.Second,
+	PlaybackTicks:   153600,
+	PlaybackFrames:  76790,
+	SignonLength:    497421,
+}
+
+func TestReadHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, &testHeader); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != HeaderSize {
+		t.Fatalf("expected a %d byte header, got %d", HeaderSize, buf.Len())
+	}
+	buf.WriteString("first frame")
+
+	h, err := ReadHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*h, testHeader) {
+		t.Errorf("expected %+v, got %+v", testHeader, *h)
+	}
+	if buf.String() != "first frame" {
+		t.Errorf("header read past its end, %q left", buf.String())
+	}
+	if r := h.TickRate(); r != 64 {
+		t.Errorf("expected a tick rate of 64, got %v", r)
+	}
+	if i := h.TickInterval(); i != 15625*time.Microsecond {
+		t.Errorf("expected a tick interval of 15.625ms, got %v", i)
+	}
+}
+
+func TestReadHeaderInvalid(t *testing.T) {
+	write := func(edit func(h *Header)) []byte {
+		h := testHeader
+		edit(&h)
+		var buf bytes.Buffer
+		if err := WriteHeader(&buf, &h); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	notDemo := write(func(*Header) {})
+	copy(notDemo, "HL2DEMX")
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  string
+	}{
+		{"stamp", notDemo, ErrNotDemo.Error()},
+		{"short", write(func(*Header) {})[:100], "short header"},
+		{"protocol", write(func(h *Header) { h.DemoProtocol = 3 }), "unsupported demo protocol 3"},
+		{"ticks", write(func(h *Header) { h.PlaybackTicks = -1 }), "invalid playback length"},
+		{"signon", write(func(h *Header) { h.SignonLength = -5 }), "invalid signon length"},
+	}
+	for _, tt := range tests {
+		_, err := ReadHeader(bytes.NewReader(tt.b))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	h := testHeader
+	h.MapName = strings.Repeat("x", 260)
+	if err := WriteHeader(&bytes.Buffer{}, &h); err == nil {
+		t.Error("expected an error for an overlong map name")
+	}
+}
+
+func TestTickRateRecording(t *testing.T) {
+	h := Header{DemoProtocol: 4}
+	if h.TickRate() != 0 || h.TickInterval() != 0 {
+		t.Errorf("expected no tick rate for a demo without length, got %v %v", h.TickRate(), h.TickInterval())
+	}
+}
@@ -0,0 +1,716 @@
+package demo
+
+import (
+	"fmt"
+)
+
+// MessageType is the id of a network message in the payload of a Signon or
+// Packet frame.
+type MessageType int
+
+// Network message types, the NET_Messages and SVC_Messages of CS:GO.
+const (
+	NetNOP               MessageType = 0
+	NetDisconnect        MessageType = 1
+	NetFile              MessageType = 2
+	NetSplitScreenUser   MessageType = 3
+	NetTick              MessageType = 4
+	NetStringCmd         MessageType = 5
+	NetSetConVar         MessageType = 6
+	NetSignonState       MessageType = 7
+	SVCServerInfo        MessageType = 8
+	SVCSendTable         MessageType = 9
+	SVCClassInfo         MessageType = 10
+	SVCSetPause          MessageType = 11
+	SVCCreateStringTable MessageType = 12
+	SVCUpdateStringTable MessageType = 13
+	SVCVoiceInit         MessageType = 14
+	SVCVoiceData         MessageType = 15
+	SVCPrint             MessageType = 16
+	SVCSounds            MessageType = 17
+	SVCSetView           MessageType = 18
+	SVCFixAngle          MessageType = 19
+	SVCCrosshairAngle    MessageType = 20
+	SVCBSPDecal          MessageType = 21
+	SVCSplitScreen       MessageType = 22
+	SVCUserMessage       MessageType = 23
+	SVCEntityMessage     MessageType = 24
+	SVCGameEvent         MessageType = 25
+	SVCPacketEntities    MessageType = 26
+	SVCTempEntities      MessageType = 27
+	SVCPrefetch          MessageType = 28
+	SVCMenu              MessageType = 29
+	SVCGameEventList     MessageType = 30
+	SVCGetCvarValue      MessageType = 31
+	SVCPaintmapData      MessageType = 33
+	SVCCmdKeyValues      MessageType = 34
+	SVCEncryptedData     MessageType = 35
+	SVCHltvReplay        MessageType = 36
+	SVCBroadcastCommand  MessageType = 38
+	NetPlayerAvatarData  MessageType = 100
+)
+
+var messageTypeNames = map[MessageType]string{
+	NetNOP:               "net_NOP",
+	NetDisconnect:        "net_Disconnect",
+	NetFile:              "net_File",
+	NetSplitScreenUser:   "net_SplitScreenUser",
+	NetTick:              "net_Tick",
+	NetStringCmd:         "net_StringCmd",
+	NetSetConVar:         "net_SetConVar",
+	NetSignonState:       "net_SignonState",
+	SVCServerInfo:        "svc_ServerInfo",
+	SVCSendTable:         "svc_SendTable",
+	SVCClassInfo:         "svc_ClassInfo",
+	SVCSetPause:          "svc_SetPause",
+	SVCCreateStringTable: "svc_CreateStringTable",
+	SVCUpdateStringTable: "svc_UpdateStringTable",
+	SVCVoiceInit:         "svc_VoiceInit",
+	SVCVoiceData:         "svc_VoiceData",
+	SVCPrint:             "svc_Print",
+	SVCSounds:            "svc_Sounds",
+	SVCSetView:           "svc_SetView",
+	SVCFixAngle:          "svc_FixAngle",
+	SVCCrosshairAngle:    "svc_CrosshairAngle",
+	SVCBSPDecal:          "svc_BSPDecal",
+	SVCSplitScreen:       "svc_SplitScreen",
+	SVCUserMessage:       "svc_UserMessage",
+	SVCEntityMessage:     "svc_EntityMessage",
+	SVCGameEvent:         "svc_GameEvent",
+	SVCPacketEntities:    "svc_PacketEntities",
+	SVCTempEntities:      "svc_TempEntities",
+	SVCPrefetch:          "svc_Prefetch",
+	SVCMenu:              "svc_Menu",
+	SVCGameEventList:     "svc_GameEventList",
+	SVCGetCvarValue:      "svc_GetCvarValue",
+	SVCPaintmapData:      "svc_PaintmapData",
+	SVCCmdKeyValues:      "svc_CmdKeyValues",
+	SVCEncryptedData:     "svc_EncryptedData",
+	SVCHltvReplay:        "svc_HltvReplay",
+	SVCBroadcastCommand:  "svc_Broadcast_Command",
+	NetPlayerAvatarData:  "net_PlayerAvatarData",
+}
+
+func (t MessageType) String() string {
+	if name, ok := messageTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MessageType(%d)", int(t))
+}
+
+// Message is a decoded network message. Its dynamic type is one of the
+// message types of this package, or *RawMessage for messages that are not
+// decoded.
+type Message interface {
+	Type() MessageType
+}
+
+// RawMessage is a network message that is not decoded.
+type RawMessage struct {
+	MsgType MessageType
+	Data    []byte
+}
+
+func (m *RawMessage) Type() MessageType { return m.MsgType }
+
+// fieldDecoder is implemented by the decoded messages and their embedded
+// messages. decodeField decodes the current field of d.
+type fieldDecoder interface {
+	decodeField(d *wireDecoder) error
+}
+
+func newMessage(t MessageType) interface {
+	Message
+	fieldDecoder
+} {
+	switch t {
+	case NetSetConVar:
+		return &SetConVar{}
+	case SVCServerInfo:
+		return &ServerInfo{}
+	case SVCSendTable:
+		return &SendTable{}
+	case SVCCreateStringTable:
+		return &CreateStringTable{}
+	case SVCUpdateStringTable:
+		return &UpdateStringTable{}
+	case SVCVoiceData:
+		return &VoiceData{}
+	case SVCUserMessage:
+		return &UserMessage{}
+	case SVCGameEvent:
+		return &GameEvent{}
+	case SVCPacketEntities:
+		return &PacketEntities{}
+	case SVCGameEventList:
+		return &GameEventList{}
+	}
+	return nil
+}
+
+// SplitMessages splits the payload of a Signon or Packet frame into its
+// messages, without decoding them. The data of the messages aliases data.
+func SplitMessages(data []byte) ([]RawMessage, error) {
+	var msgs []RawMessage
+	for len(data) > 0 {
+		t, n, err := readVarint(data)
+		if err != nil {
+			return msgs, err
+		}
+		data = data[n:]
+		size, n, err := readVarint(data)
+		if err != nil {
+			return msgs, err
+		}
+		data = data[n:]
+		if size > uint64(len(data)) {
+			return msgs, fmt.Errorf("demo: %v of %d bytes truncated to %d", MessageType(t), size, len(data))
+		}
+		msgs = append(msgs, RawMessage{MsgType: MessageType(t), Data: data[:size:size]})
+		data = data[size:]
+	}
+	return msgs, nil
+}
+
+// DecodeMessage decodes m. Messages of types this package does not decode
+// are returned as m itself. []byte fields of the result alias m.Data;
+// string fields are copies.
+func DecodeMessage(m *RawMessage) (Message, error) {
+	msg := newMessage(m.MsgType)
+	if msg == nil {
+		return m, nil
+	}
+	if err := decodeMessage(m.Data, msg); err != nil {
+		return nil, fmt.Errorf("%v: %v", m.MsgType, err)
+	}
+	return msg, nil
+}
+
+// ReadMessages splits and decodes the payload of a Signon or Packet frame.
+func ReadMessages(data []byte) ([]Message, error) {
+	raw, err := SplitMessages(data)
+	msgs := make([]Message, 0, len(raw))
+	for i := range raw {
+		m, err := DecodeMessage(&raw[i])
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, err
+}
+
+func decodeMessage(b []byte, m fieldDecoder) error {
+	d := &wireDecoder{b: b}
+	for {
+		ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+		if err := m.decodeField(d); err != nil {
+			return err
+		}
+	}
+}
+
+// embedded decodes a message field of d into m.
+func embedded(d *wireDecoder, m fieldDecoder) error {
+	b, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return decodeMessage(b, m)
+}
+
+// ConVar is a console variable set by SetConVar.
+type ConVar struct {
+	Name           string
+	Value          string
+	DictionaryName uint32
+}
+
+func (c *ConVar) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		c.Name, err = d.string()
+	case 2:
+		c.Value, err = d.string()
+	case 3:
+		c.DictionaryName, err = d.uint32()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// convars decodes CMsg_CVars.
+type convars struct {
+	vars *[]ConVar
+}
+
+func (c convars) decodeField(d *wireDecoder) error {
+	if d.field != 1 {
+		return d.skip()
+	}
+	var v ConVar
+	if err := embedded(d, &v); err != nil {
+		return err
+	}
+	*c.vars = append(*c.vars, v)
+	return nil
+}
+
+// SetConVar is CNETMsg_SetConVar, which sets console variables on the
+// client.
+type SetConVar struct {
+	ConVars []ConVar
+}
+
+func (*SetConVar) Type() MessageType { return NetSetConVar }
+
+func (m *SetConVar) decodeField(d *wireDecoder) error {
+	if d.field != 1 {
+		return d.skip()
+	}
+	return embedded(d, convars{&m.ConVars})
+}
+
+// ServerInfo is CSVCMsg_ServerInfo, sent once when the client connects.
+type ServerInfo struct {
+	Protocol                  int32
+	ServerCount               int32
+	IsDedicated               bool
+	IsOfficialValveServer     bool
+	IsHLTV                    bool
+	IsReplay                  bool
+	IsRedirectingToProxyRelay bool
+	OS                        int32
+	MapCRC                    uint32
+	ClientCRC                 uint32
+	StringTableCRC            uint32
+	MaxClients                int32
+	MaxClasses                int32
+	PlayerSlot                int32
+	// TickInterval is the time between two ticks in seconds.
+	TickInterval float32
+	GameDir      string
+	MapName      string
+	MapGroupName string
+	SkyName      string
+	HostName     string
+	PublicIP     uint32
+	UGCMapID     uint64
+}
+
+func (*ServerInfo) Type() MessageType { return SVCServerInfo }
+
+func (m *ServerInfo) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.Protocol, err = d.int32()
+	case 2:
+		m.ServerCount, err = d.int32()
+	case 3:
+		m.IsDedicated, err = d.bool()
+	case 4:
+		m.IsOfficialValveServer, err = d.bool()
+	case 5:
+		m.IsHLTV, err = d.bool()
+	case 6:
+		m.IsReplay, err = d.bool()
+	case 7:
+		m.OS, err = d.int32()
+	case 8:
+		m.MapCRC, err = d.fixed32()
+	case 9:
+		m.ClientCRC, err = d.fixed32()
+	case 10:
+		m.StringTableCRC, err = d.fixed32()
+	case 11:
+		m.MaxClients, err = d.int32()
+	case 12:
+		m.MaxClasses, err = d.int32()
+	case 13:
+		m.PlayerSlot, err = d.int32()
+	case 14:
+		m.TickInterval, err = d.float()
+	case 15:
+		m.GameDir, err = d.string()
+	case 16:
+		m.MapName, err = d.string()
+	case 17:
+		m.MapGroupName, err = d.string()
+	case 18:
+		m.SkyName, err = d.string()
+	case 19:
+		m.HostName, err = d.string()
+	case 20:
+		m.PublicIP, err = d.uint32()
+	case 21:
+		m.IsRedirectingToProxyRelay, err = d.bool()
+	case 22:
+		m.UGCMapID, err = d.uint64()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// CreateStringTable is CSVCMsg_CreateStringTable, which creates a string
+// table with its initial entries.
+type CreateStringTable struct {
+	Name              string
+	MaxEntries        int32
+	NumEntries        int32
+	UserDataFixedSize bool
+	UserDataSize      int32
+	UserDataSizeBits  int32
+	Flags             int32
+	// StringData holds the bit packed entries.
+	StringData []byte
+}
+
+func (*CreateStringTable) Type() MessageType { return SVCCreateStringTable }
+
+func (m *CreateStringTable) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.Name, err = d.string()
+	case 2:
+		m.MaxEntries, err = d.int32()
+	case 3:
+		m.NumEntries, err = d.int32()
+	case 4:
+		m.UserDataFixedSize, err = d.bool()
+	case 5:
+		m.UserDataSize, err = d.int32()
+	case 6:
+		m.UserDataSizeBits, err = d.int32()
+	case 7:
+		m.Flags, err = d.int32()
+	case 8:
+		m.StringData, err = d.bytes()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// UpdateStringTable is CSVCMsg_UpdateStringTable, which changes entries of a
+// string table.
+type UpdateStringTable struct {
+	TableID           int32
+	NumChangedEntries int32
+	// StringData holds the bit packed entries.
+	StringData []byte
+}
+
+func (*UpdateStringTable) Type() MessageType { return SVCUpdateStringTable }
+
+func (m *UpdateStringTable) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.TableID, err = d.int32()
+	case 2:
+		m.NumChangedEntries, err = d.int32()
+	case 3:
+		m.StringData, err = d.bytes()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// VoiceData is CSVCMsg_VoiceData, voice chat of a player.
+type VoiceData struct {
+	Client                   int32
+	Proximity                bool
+	XUID                     uint64
+	AudibleMask              int32
+	VoiceData                []byte
+	Caster                   bool
+	Format                   int32
+	SequenceBytes            int32
+	SectionNumber            uint32
+	UncompressedSampleOffset uint32
+}
+
+func (*VoiceData) Type() MessageType { return SVCVoiceData }
+
+func (m *VoiceData) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.Client, err = d.int32()
+	case 2:
+		m.Proximity, err = d.bool()
+	case 3:
+		m.XUID, err = d.fixed64()
+	case 4:
+		m.AudibleMask, err = d.int32()
+	case 5:
+		m.VoiceData, err = d.bytes()
+	case 6:
+		m.Caster, err = d.bool()
+	case 7:
+		m.Format, err = d.int32()
+	case 8:
+		m.SequenceBytes, err = d.int32()
+	case 9:
+		m.SectionNumber, err = d.uint32()
+	case 10:
+		m.UncompressedSampleOffset, err = d.uint32()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// UserMessage is CSVCMsg_UserMessage, a game specific message such as a
+// chat line or a say text. Data is the encoded CCSUsrMsg_* of MsgType.
+type UserMessage struct {
+	MsgType     int32
+	Data        []byte
+	Passthrough int32
+}
+
+func (*UserMessage) Type() MessageType { return SVCUserMessage }
+
+func (m *UserMessage) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.MsgType, err = d.int32()
+	case 2:
+		m.Data, err = d.bytes()
+	case 3:
+		m.Passthrough, err = d.int32()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// PacketEntities is CSVCMsg_PacketEntities, the entity updates of a tick.
+type PacketEntities struct {
+	MaxEntries     int32
+	UpdatedEntries int32
+	IsDelta        bool
+	UpdateBaseline bool
+	Baseline       int32
+	DeltaFrom      int32
+	// EntityData holds the bit packed updates.
+	EntityData []byte
+}
+
+func (*PacketEntities) Type() MessageType { return SVCPacketEntities }
+
+func (m *PacketEntities) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.MaxEntries, err = d.int32()
+	case 2:
+		m.UpdatedEntries, err = d.int32()
+	case 3:
+		m.IsDelta, err = d.bool()
+	case 4:
+		m.UpdateBaseline, err = d.bool()
+	case 5:
+		m.Baseline, err = d.int32()
+	case 6:
+		m.DeltaFrom, err = d.int32()
+	case 7:
+		m.EntityData, err = d.bytes()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// Game event key types.
+const (
+	EventKeyString  = 1
+	EventKeyFloat   = 2
+	EventKeyLong    = 3
+	EventKeyShort   = 4
+	EventKeyByte    = 5
+	EventKeyBool    = 6
+	EventKeyUint64  = 7
+	EventKeyWString = 8
+)
+
+// GameEventKey is a value of a GameEvent. Only the field matching Type is
+// set.
+type GameEventKey struct {
+	Type    int32
+	String  string
+	Float   float32
+	Long    int32
+	Short   int32
+	Byte    int32
+	Bool    bool
+	Uint64  uint64
+	WString []byte
+}
+
+func (k *GameEventKey) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		k.Type, err = d.int32()
+	case 2:
+		k.String, err = d.string()
+	case 3:
+		k.Float, err = d.float()
+	case 4:
+		k.Long, err = d.int32()
+	case 5:
+		k.Short, err = d.int32()
+	case 6:
+		k.Byte, err = d.int32()
+	case 7:
+		k.Bool, err = d.bool()
+	case 8:
+		k.Uint64, err = d.uint64()
+	case 9:
+		k.WString, err = d.bytes()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// Value returns the value of k according to its type.
+func (k *GameEventKey) Value() interface{} {
+	switch k.Type {
+	case EventKeyString:
+		return k.String
+	case EventKeyFloat:
+		return k.Float
+	case EventKeyLong:
+		return k.Long
+	case EventKeyShort:
+		return k.Short
+	case EventKeyByte:
+		return k.Byte
+	case EventKeyBool:
+		return k.Bool
+	case EventKeyUint64:
+		return k.Uint64
+	case EventKeyWString:
+		return k.WString
+	}
+	return nil
+}
+
+// GameEvent is CSVCMsg_GameEvent, a game event such as a kill or the end of a
+// round. Its keys are in the order of the descriptor of EventID in the
+// GameEventList.
+type GameEvent struct {
+	EventName   string
+	EventID     int32
+	Keys        []GameEventKey
+	Passthrough int32
+}
+
+func (*GameEvent) Type() MessageType { return SVCGameEvent }
+
+func (m *GameEvent) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		m.EventName, err = d.string()
+	case 2:
+		m.EventID, err = d.int32()
+	case 3:
+		var k GameEventKey
+		if err = embedded(d, &k); err == nil {
+			m.Keys = append(m.Keys, k)
+		}
+	case 4:
+		m.Passthrough, err = d.int32()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// GameEventDescriptorKey names a key of a game event.
+type GameEventDescriptorKey struct {
+	Type int32
+	Name string
+}
+
+func (k *GameEventDescriptorKey) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		k.Type, err = d.int32()
+	case 2:
+		k.Name, err = d.string()
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// GameEventDescriptor describes the game events with an id.
+type GameEventDescriptor struct {
+	EventID int32
+	Name    string
+	Keys    []GameEventDescriptorKey
+}
+
+func (e *GameEventDescriptor) decodeField(d *wireDecoder) (err error) {
+	switch d.field {
+	case 1:
+		e.EventID, err = d.int32()
+	case 2:
+		e.Name, err = d.string()
+	case 3:
+		var k GameEventDescriptorKey
+		if err = embedded(d, &k); err == nil {
+			e.Keys = append(e.Keys, k)
+		}
+	default:
+		err = d.skip()
+	}
+	return err
+}
+
+// Values maps the key names of e to the values of the keys of ev, which must
+// be an event described by e.
+func (e *GameEventDescriptor) Values(ev *GameEvent) map[string]interface{} {
+	values := make(map[string]interface{}, len(ev.Keys))
+	for i := range ev.Keys {
+		if i < len(e.Keys) {
+			values[e.Keys[i].Name] = ev.Keys[i].Value()
+		}
+	}
+	return values
+}
+
+// GameEventList is CSVCMsg_GameEventList, the descriptors of the game events
+// of the server, sent once when the client connects.
+type GameEventList struct {
+	Descriptors []GameEventDescriptor
+}
+
+func (*GameEventList) Type() MessageType { return SVCGameEventList }
+
+func (m *GameEventList) decodeField(d *wireDecoder) error {
+	if d.field != 1 {
+		return d.skip()
+	}
+	var e GameEventDescriptor
+	if err := embedded(d, &e); err != nil {
+		return err
+	}
+	m.Descriptors = append(m.Descriptors, e)
+	return nil
+}
+
+// Descriptor returns the descriptor of the game events with id, or nil.
+func (m *GameEventList) Descriptor(id int32) *GameEventDescriptor {
+	for i := range m.Descriptors {
+		if m.Descriptors[i].EventID == id {
+			return &m.Descriptors[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package demo
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// pb encodes protocol buffer messages for the tests.
+type pb []byte
+
+func (b pb) varint(v uint64) pb {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func (b pb) key(field, wireType int) pb {
+	return b.varint(uint64(field<<3 | wireType))
+}
+
+func (b pb) int(field int, v int64) pb {
+	return b.key(field, wireVarint).varint(uint64(v))
+}
+
+func (b pb) fixed32(field int, v uint32) pb {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b.key(field, wireFixed32), buf[:]...)
+}
+
+func (b pb) fixed64(field int, v uint64) pb {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b.key(field, wireFixed64), buf[:]...)
+}
+
+func (b pb) float(field int, v float32) pb {
+	return b.fixed32(field, math.Float32bits(v))
+}
+
+func (b pb) bytes(field int, v []byte) pb {
+	return append(b.key(field, wireBytes).varint(uint64(len(v))), v...)
+}
+
+func (b pb) string(field int, v string) pb {
+	return b.bytes(field, []byte(v))
+}
+
+// packet encodes messages the way they appear in a Packet frame.
+func packet(msgs ...RawMessage) []byte {
+	var b pb
+	for _, m := range msgs {
+		b = b.varint(uint64(m.MsgType)).varint(uint64(len(m.Data)))
+		b = append(b, m.Data...)
+	}
+	return b
+}
+
+func TestReadVarint(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		v   uint64
+		n   int
+		err error
+	}{
+		{[]byte{0}, 0, 1, nil},
+		{[]byte{1, 0xff}, 1, 1, nil},
+		{[]byte{0xac, 0x02}, 300, 2, nil},
+		{pb(nil).varint(math.MaxUint64), math.MaxUint64, 10, nil},
+		{[]byte{0x80}, 0, 0, errVarint},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 0, 0, errVarint},
+		{nil, 0, 0, errVarint},
+	}
+	for _, tt := range tests {
+		v, n, err := readVarint(tt.in)
+		if v != tt.v || n != tt.n || err != tt.err {
+			t.Errorf("readVarint(%x) = %d, %d, %v, expected %d, %d, %v", tt.in, v, n, err, tt.v, tt.n, tt.err)
+		}
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	serverInfo := pb(nil).
+		int(1, 13779).
+		int(3, 1).
+		fixed32(8, 0xdeadbeef).
+		int(11, 10).
+		int(13, -1).
+		float(14, 1.0/64).
+		string(15, "csgo").
+		string(16, "de_dust2").
+		string(99, "unknown field").
+		fixed64(98, 1).
+		int(22, 1<<40)
+	cvars := pb(nil).
+		bytes(1, pb(nil).string(1, "mp_maxrounds").string(2, "30")).
+		bytes(1, pb(nil).string(1, "sv_cheats").string(2, "0").int(3, 7))
+	unknown := []byte{0x0a, 0x02, 'h', 'i'}
+
+	data := packet(
+		RawMessage{SVCServerInfo, serverInfo},
+		RawMessage{NetTick, unknown},
+		RawMessage{NetSetConVar, pb(nil).bytes(1, cvars)},
+		RawMessage{SVCCreateStringTable, pb(nil).string(1, "userinfo").int(2, 256).int(4, 1).bytes(8, []byte{1, 2, 3})},
+		RawMessage{SVCUpdateStringTable, pb(nil).int(1, 3).int(2, 1).bytes(3, []byte{4})},
+		RawMessage{SVCPacketEntities, pb(nil).int(1, 2048).int(2, 5).int(3, 1).int(6, 100).bytes(7, []byte{5, 6})},
+		RawMessage{SVCUserMessage, pb(nil).int(1, 6).bytes(2, []byte("text"))},
+		RawMessage{SVCVoiceData, pb(nil).int(1, 2).fixed64(3, 76561198000000000).bytes(5, []byte{7})},
+	)
+	msgs, err := ReadMessages(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Message{
+		&ServerInfo{
+			Protocol:     13779,
+			IsDedicated:  true,
+			MapCRC:       0xdeadbeef,
+			MaxClients:   10,
+			PlayerSlot:   -1,
+			TickInterval: 1.0 / 64,
+			GameDir:      "csgo",
+			MapName:      "de_dust2",
+			UGCMapID:     1 << 40,
+		},
+		&RawMessage{NetTick, unknown},
+		&SetConVar{ConVars: []ConVar{
+			{Name: "mp_maxrounds", Value: "30"},
+			{Name: "sv_cheats", Value: "0", DictionaryName: 7},
+		}},
+		&CreateStringTable{Name: "userinfo", MaxEntries: 256, UserDataFixedSize: true, StringData: []byte{1, 2, 3}},
+		&UpdateStringTable{TableID: 3, NumChangedEntries: 1, StringData: []byte{4}},
+		&PacketEntities{MaxEntries: 2048, UpdatedEntries: 5, IsDelta: true, DeltaFrom: 100, EntityData: []byte{5, 6}},
+		&UserMessage{MsgType: 6, Data: []byte("text")},
+		&VoiceData{Client: 2, XUID: 76561198000000000, VoiceData: []byte{7}},
+	}
+	if len(msgs) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(msgs))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(msgs[i], expected[i]) {
+			t.Errorf("message %d: expected %+v, got %+v", i, expected[i], msgs[i])
+		}
+	}
+}
+
+func TestGameEvents(t *testing.T) {
+	list := pb(nil).
+		bytes(1, pb(nil).int(1, 24).string(2, "player_death").
+			bytes(3, pb(nil).int(1, EventKeyShort).string(2, "userid")).
+			bytes(3, pb(nil).int(1, EventKeyString).string(2, "weapon")).
+			bytes(3, pb(nil).int(1, EventKeyBool).string(2, "headshot"))).
+		bytes(1, pb(nil).int(1, 40).string(2, "round_end"))
+	event := pb(nil).
+		int(2, 24).
+		bytes(3, pb(nil).int(1, EventKeyShort).int(5, 12)).
+		bytes(3, pb(nil).int(1, EventKeyString).string(2, "ak47")).
+		bytes(3, pb(nil).int(1, EventKeyBool).int(7, 1))
+
+	msgs, err := ReadMessages(packet(
+		RawMessage{SVCGameEventList, list},
+		RawMessage{SVCGameEvent, event},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := msgs[0].(*GameEventList)
+	if !ok {
+		t.Fatalf("expected *GameEventList, got %T", msgs[0])
+	}
+	ev, ok := msgs[1].(*GameEvent)
+	if !ok {
+		t.Fatalf("expected *GameEvent, got %T", msgs[1])
+	}
+
+	if len(l.Descriptors) != 2 || l.Descriptor(40).Name != "round_end" || l.Descriptor(1) != nil {
+		t.Errorf("unexpected descriptors %+v", l.Descriptors)
+	}
+	desc := l.Descriptor(ev.EventID)
+	if desc == nil || desc.Name != "player_death" {
+		t.Fatalf("unexpected descriptor %+v for event %d", desc, ev.EventID)
+	}
+	expected := map[string]interface{}{
+		"userid":   int32(12),
+		"weapon":   "ak47",
+		"headshot": true,
+	}
+	if v := desc.Values(ev); !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected values %v, got %v", expected, v)
+	}
+}
+
+func TestReadMessagesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated message", []byte{byte(SVCServerInfo), 10, 1, 2}},
+		{"truncated size", []byte{byte(SVCServerInfo), 0x80}},
+		{"truncated field", packet(RawMessage{SVCServerInfo, pb(nil).key(15, wireBytes).varint(5)})},
+		{"truncated fixed32", packet(RawMessage{SVCServerInfo, pb(nil).key(8, wireFixed32)})},
+		{"wrong wire type", packet(RawMessage{SVCServerInfo, pb(nil).string(1, "13779")})},
+		{"field zero", packet(RawMessage{SVCServerInfo, pb(nil).int(0, 1)})},
+		{"group", packet(RawMessage{SVCServerInfo, pb(nil).key(99, 3)})},
+		{"bad embedded message", packet(RawMessage{SVCGameEvent, pb(nil).bytes(3, []byte{0x08})})},
+	}
+	for _, tt := range tests {
+		if _, err := ReadMessages(tt.data); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestSplitMessagesAlias(t *testing.T) {
+	data := packet(RawMessage{NetNOP, nil}, RawMessage{SVCPrint, []byte("hello")})
+	msgs, err := SplitMessages(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].MsgType != NetNOP || len(msgs[0].Data) != 0 || string(msgs[1].Data) != "hello" {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	// appending to a message must not overwrite the data after it
+	if cap(msgs[1].Data) != len(msgs[1].Data) {
+		t.Errorf("message data has spare capacity %d", cap(msgs[1].Data))
+	}
+}
+
+func TestMessageTypeString(t *testing.T) {
+	for typ, expected := range map[MessageType]string{
+		SVCPacketEntities:   "svc_PacketEntities",
+		NetSetConVar:        "net_SetConVar",
+		SVCBroadcastCommand: "svc_Broadcast_Command",
+		32:                  "MessageType(32)",
+	} {
+		if s := typ.String(); s != expected {
+			t.Errorf("expected %q, got %q", expected, s)
+		}
+	}
+}
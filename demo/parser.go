@@ -0,0 +1,143 @@
+package demo
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Parser reads a demo frame by frame, keeping track of its state: the send
+// tables, the string tables and the entity table.
+type Parser struct {
+	r        *Reader
+	tables   *SendTables
+	strings  *StringTableStore
+	entities *Entities
+	tick     int
+}
+
+// NewParser reads the header of the demo in r.
+func NewParser(r io.Reader) (*Parser, error) {
+	dr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parser{r: dr, strings: NewStringTableStore(), entities: NewEntities()}
+	p.strings.OnEntry(p.baselineChanged)
+	return p, nil
+}
+
+// Header returns the header of the demo.
+func (p *Parser) Header() *Header {
+	return p.r.Header()
+}
+
+// Tick returns the tick of the last frame read.
+func (p *Parser) Tick() int {
+	return p.tick
+}
+
+// SendTables returns the send tables of the demo, or nil before its
+// DataTables frame.
+func (p *Parser) SendTables() *SendTables {
+	return p.tables
+}
+
+// StringTables returns the string tables of the demo. Hooks registered on
+// it before the first frame see every entry.
+func (p *Parser) StringTables() *StringTableStore {
+	return p.strings
+}
+
+// Entities returns the entity table. Hooks registered on it before the
+// first frame see every entity of the demo.
+func (p *Parser) Entities() *Entities {
+	return p.entities
+}
+
+// Next reads the next frame and applies it to the state of the demo. It
+// returns io.EOF at the end of the demo, like Reader.Next.
+func (p *Parser) Next() (*Frame, error) {
+	f, err := p.r.Next()
+	if err != nil {
+		return nil, err
+	}
+	p.tick = f.Tick
+
+	switch f.Command {
+	case DataTables:
+		st, err := ParseDataTables(f.Data)
+		if err != nil {
+			return nil, err
+		}
+		p.tables = st
+		p.entities.SetTables(st)
+	case StringTables:
+		if err := p.strings.ReadSnapshot(f.Data); err != nil {
+			return nil, fmt.Errorf("%v at tick %d", err, p.tick)
+		}
+	case Signon, Packet:
+		if err := p.handleMessages(f.Data); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Run reads the rest of the demo.
+func (p *Parser) Run() error {
+	for {
+		_, err := p.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Parser) handleMessages(data []byte) error {
+	raw, err := SplitMessages(data)
+	if err != nil {
+		return fmt.Errorf("%v at tick %d", err, p.tick)
+	}
+	for i := range raw {
+		switch raw[i].MsgType {
+		case SVCPacketEntities, SVCCreateStringTable, SVCUpdateStringTable:
+		default:
+			continue
+		}
+		m, err := DecodeMessage(&raw[i])
+		if err != nil {
+			return fmt.Errorf("%v at tick %d", err, p.tick)
+		}
+		switch m := m.(type) {
+		case *PacketEntities:
+			if err := p.entities.Update(p.tick, m); err != nil {
+				return err
+			}
+		case *CreateStringTable:
+			if _, err := p.strings.Create(m); err != nil {
+				return fmt.Errorf("%v at tick %d", err, p.tick)
+			}
+		case *UpdateStringTable:
+			if _, err := p.strings.Update(m); err != nil {
+				return fmt.Errorf("%v at tick %d", err, p.tick)
+			}
+		}
+	}
+	return nil
+}
+
+// baselineChanged passes the entries of the instancebaseline table, whose
+// keys are class ids, on to the entity table.
+func (p *Parser) baselineChanged(t *StringTable, index int) {
+	if t.Name != TableInstanceBaseline {
+		return
+	}
+	e := t.Entries[index]
+	if id, err := strconv.Atoi(e.Key); err == nil {
+		p.entities.SetBaseline(id, e.Data)
+	}
+}
@@ -0,0 +1,118 @@
+package demo
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodePacketEntities encodes m as a svc_PacketEntities message.
+func encodePacketEntities(m *PacketEntities) RawMessage {
+	b := pb(nil).int(1, int64(m.MaxEntries)).int(2, int64(m.UpdatedEntries))
+	if m.IsDelta {
+		b = b.int(3, 1)
+	}
+	return RawMessage{SVCPacketEntities, b.bytes(7, m.EntityData)}
+}
+
+// encodeCreateStringTable encodes a svc_CreateStringTable message of a table
+// with variable size user data.
+func encodeCreateStringTable(name string, maxEntries int, entries ...stringEntry) RawMessage {
+	b := pb(nil).string(1, name).int(2, int64(maxEntries)).int(3, int64(len(entries)))
+	return RawMessage{SVCCreateStringTable, b.bytes(8, writeStringEntries(maxEntries, 0, entries...))}
+}
+
+func TestParser(t *testing.T) {
+	tables := writeDataTables(entityTables, []testClass{{0, "CCSPlayer", "DT_Player"}, {1, "CBall", "DT_Ball"}})
+	baseline := new(bitWriter)
+	writeProps(baseline, []propWrite{{propBounce, bitsOf(7, 4)}}, true)
+	enter := packetEntities(false, false, entityUpdate{index: 1, op: "enter", class: 0, serial: 1, props: []propWrite{
+		{propHealth, bitsOf(100, 8)},
+	}}, entityUpdate{index: 70, op: "enter", class: 1, serial: 1})
+	hurt := packetEntities(true, true, entityUpdate{index: 1, op: "delta", props: []propWrite{
+		{propHealth, bitsOf(42, 8)},
+	}})
+	buf := testDemo(t, []*Frame{
+		{Command: DataTables, Data: tables},
+		{Command: Signon, Data: packet(
+			RawMessage{NetTick, []byte{0x08, 1}},
+			encodeCreateStringTable(TableInstanceBaseline, 1024, stringEntry{key: "1", data: baseline.b}),
+			encodePacketEntities(enter),
+		)},
+		{Command: SyncTick},
+		{Command: StringTables, Data: stringTableSnapshot([]*StringTable{
+			{Name: TableUserInfo, Entries: []StringEntry{{"76561197960287930", playerInfo(testPlayers[0])}}},
+		})},
+		{Command: Packet, Tick: 64, Data: packet(encodePacketEntities(hurt), RawMessage{SVCPrint, []byte("\x0a\x02hi")})},
+		{Command: Stop, Tick: 65},
+	})
+
+	p, err := NewParser(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []PropChange
+	p.Entities().OnProp("CCSPlayer", "m_iHealth", func(ch PropChange) {
+		changes = append(changes, ch)
+	})
+
+	f, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Command != DataTables || p.SendTables() == nil || p.SendTables().Class("CBall") == nil {
+		t.Fatalf("expected the data tables, got %v", f.Command)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after Run, got %v", err)
+	}
+
+	if len(changes) != 2 || changes[0].New != int32(100) || changes[1].Old != int32(100) || changes[1].New != int32(42) || changes[1].Tick != 64 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	if e := p.Entities().Entity(70); e == nil || e.Prop("m_iBounce") != int32(7) {
+		t.Errorf("expected the ball to start from its instance baseline, got %+v", e)
+	}
+	if pl := p.StringTables().Player(2); pl == nil || pl.Name != "gaben" {
+		t.Errorf("expected gaben, got %+v", pl)
+	}
+	if p.Tick() != 65 || p.Header().MapName != testHeader.MapName {
+		t.Errorf("unexpected tick %d and header %+v", p.Tick(), p.Header())
+	}
+}
+
+func TestParserInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []*Frame
+		err    string
+	}{
+		{"entities before tables", []*Frame{
+			{Command: Signon, Data: packet(encodePacketEntities(&PacketEntities{}))},
+		}, "before the data tables"},
+		{"bad tables", []*Frame{
+			{Command: DataTables, Data: []byte{1}},
+		}, "truncated"},
+		{"bad string table", []*Frame{
+			{Command: Signon, Tick: 2, Data: packet(RawMessage{SVCCreateStringTable, pb(nil).bytes(8, []byte{1})})},
+		}, "dictionary encoding at tick 2"},
+		{"bad snapshot", []*Frame{
+			{Command: StringTables, Tick: 4, Data: []byte{1}},
+		}, "at tick 4"},
+		{"bad messages", []*Frame{
+			{Command: Packet, Tick: 3, Data: []byte{byte(SVCPacketEntities), 10}},
+		}, "at tick 3"},
+	}
+	for _, tt := range tests {
+		p, err := NewParser(testDemo(t, tt.frames))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Run(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ajmadsen/replayanalyzer/bitstream"
+)
+
+// maxStringBits is the size of the length of String props.
+const maxStringBits = 9
+
+// maxArrayElements is the largest number of elements of Array props.
+const maxArrayElements = 2048
+
+// checkProp returns an error if the NumBits or NumElements of p are out of
+// the range its decoder reads, so that corrupt send tables fail to parse
+// rather than decode entities wrong.
+func checkProp(p *SendProp) error {
+	bits := p.NumBits
+	switch p.Type {
+	case PropInt:
+		if !p.Flags.Has(PropVarInt) && (bits < 0 || bits > 32) {
+			return fmt.Errorf("demo: %v prop %s has %d bits", p.Type, p.Name, bits)
+		}
+	case PropInt64:
+		if !p.Flags.Has(PropVarInt) && (bits < 1 || bits > 64) {
+			return fmt.Errorf("demo: %v prop %s has %d bits", p.Type, p.Name, bits)
+		}
+	case PropFloat, PropVector, PropVectorXY:
+		// only quantized floats and cell coordinates have a size, and
+		// quantized floats need at least a bit to divide the range by
+		const unsized = PropCoord | PropCoordMP | PropCoordMPLowPrecision | PropCoordMPIntegral | PropNoScale | PropNormal
+		const cell = PropCellCoord | PropCellCoordLowPrecision | PropCellCoordIntegral
+		min := 1
+		if p.Flags&cell != 0 {
+			min = 0
+		}
+		if p.Flags&unsized == 0 && (bits < min || bits > 32) {
+			return fmt.Errorf("demo: %v prop %s has %d bits", p.Type, p.Name, bits)
+		}
+	case PropArray:
+		if p.NumElements < 0 || p.NumElements > maxArrayElements {
+			return fmt.Errorf("demo: %v prop %s has %d elements", p.Type, p.Name, p.NumElements)
+		}
+	}
+	return nil
+}
+
+// decodeProp decodes a value of p. Values are int32 for Int props, int64 for
+// Int64 props, float32 for Float props, Vector for Vector and VectorXY props,
+// string for String props, and []interface{} of the element values for Array
+// props.
+func decodeProp(r *bitstream.Reader, p *FlatProp) interface{} {
+	if p.Prop.Type == PropArray {
+		return decodeArray(r, p.Prop, p.ArrayElem)
+	}
+	return decodeValue(r, p.Prop)
+}
+
+func decodeValue(r *bitstream.Reader, p *SendProp) interface{} {
+	switch p.Type {
+	case PropInt:
+		return decodeInt(r, p)
+	case PropFloat:
+		return decodeFloat(r, p)
+	case PropVector:
+		v := Vector{X: decodeFloat(r, p), Y: decodeFloat(r, p)}
+		if p.Flags.Has(PropNormal) {
+			negative := r.ReadBit()
+			if xy := v.X*v.X + v.Y*v.Y; xy < 1 {
+				v.Z = float32(math.Sqrt(float64(1 - xy)))
+			}
+			if negative {
+				v.Z = -v.Z
+			}
+		} else {
+			v.Z = decodeFloat(r, p)
+		}
+		return v
+	case PropVectorXY:
+		return Vector{X: decodeFloat(r, p), Y: decodeFloat(r, p)}
+	case PropString:
+		n := int(r.ReadBits(maxStringBits))
+		return string(r.ReadBytes(n))
+	case PropInt64:
+		return decodeInt64(r, p)
+	}
+	return nil
+}
+
+func decodeInt(r *bitstream.Reader, p *SendProp) int32 {
+	switch {
+	case p.Flags.Has(PropVarInt | PropUnsigned):
+		return int32(r.ReadVarInt32())
+	case p.Flags.Has(PropVarInt):
+		return r.ReadSignedVarInt32()
+	case p.Flags.Has(PropUnsigned):
+		return int32(r.ReadBits(p.NumBits))
+	}
+	return r.ReadSignedBits(p.NumBits)
+}
+
+func decodeInt64(r *bitstream.Reader, p *SendProp) int64 {
+	switch {
+	case p.Flags.Has(PropVarInt | PropUnsigned):
+		return int64(r.ReadVarInt64())
+	case p.Flags.Has(PropVarInt):
+		return r.ReadSignedVarInt64()
+	case p.Flags.Has(PropUnsigned):
+		return int64(r.ReadBits64(p.NumBits))
+	}
+	// a sign and the magnitude, rather than two's complement
+	negative := r.ReadBit()
+	v := int64(r.ReadBits64(p.NumBits - 1))
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+func decodeFloat(r *bitstream.Reader, p *SendProp) float32 {
+	switch f := p.Flags; {
+	case f.Has(PropCoord):
+		return r.ReadBitCoord()
+	case f.Has(PropCoordMP):
+		return r.ReadBitCoordMP(bitstream.CoordNormal)
+	case f.Has(PropCoordMPLowPrecision):
+		return r.ReadBitCoordMP(bitstream.CoordLowPrecision)
+	case f.Has(PropCoordMPIntegral):
+		return r.ReadBitCoordMP(bitstream.CoordIntegral)
+	case f.Has(PropNoScale):
+		return r.ReadFloat()
+	case f.Has(PropNormal):
+		return r.ReadBitNormal()
+	case f.Has(PropCellCoord):
+		return r.ReadBitCellCoord(p.NumBits, bitstream.CoordNormal)
+	case f.Has(PropCellCoordLowPrecision):
+		return r.ReadBitCellCoord(p.NumBits, bitstream.CoordLowPrecision)
+	case f.Has(PropCellCoordIntegral):
+		return r.ReadBitCellCoord(p.NumBits, bitstream.CoordIntegral)
+	}
+	return r.ReadQuantizedFloat(p.NumBits, p.LowValue, p.HighValue)
+}
+
+func decodeArray(r *bitstream.Reader, p, elem *SendProp) []interface{} {
+	// the count has the bits of NumElements, so that it can hold the maximum
+	n := int(r.ReadBits(arrayCountBits(p.NumElements)))
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = decodeValue(r, elem)
+	}
+	return values
+}
+
+func arrayCountBits(max int) int {
+	n := 1
+	for max >>= 1; max != 0; max >>= 1 {
+		n++
+	}
+	return n
+}
+
+// equalValues reports whether the prop values a and b are equal.
+func equalValues(a, b interface{}) bool {
+	as, ok := a.([]interface{})
+	if !ok {
+		return a == b
+	}
+	bs, ok := b.([]interface{})
+	if !ok || len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
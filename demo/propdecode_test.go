@@ -0,0 +1,170 @@
+package demo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/bitstream"
+)
+
+// bitWriter encodes bit packed data, least significant bit first.
+type bitWriter struct {
+	b []byte
+	n int
+}
+
+func (w *bitWriter) bits(v uint64, n int) *bitWriter {
+	for i := 0; i < n; i++ {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v>>uint(i)&1 != 0 {
+			w.b[w.n/8] |= 1 << uint(w.n%8)
+		}
+		w.n++
+	}
+	return w
+}
+
+func (w *bitWriter) bit(b bool) *bitWriter {
+	if b {
+		return w.bits(1, 1)
+	}
+	return w.bits(0, 1)
+}
+
+func (w *bitWriter) bytes(b []byte) *bitWriter {
+	for _, c := range b {
+		w.bits(uint64(c), 8)
+	}
+	return w
+}
+
+func (w *bitWriter) varint(v uint64) *bitWriter {
+	for v >= 0x80 {
+		w.bits(v&0x7f|0x80, 8)
+		v >>= 7
+	}
+	return w.bits(v, 8)
+}
+
+func (w *bitWriter) float(v float32) *bitWriter {
+	return w.bits(uint64(math.Float32bits(v)), 32)
+}
+
+// coordMP encodes an integral value as an in bounds SPROP_COORD_MP float.
+func (w *bitWriter) coordMP(v int) *bitWriter {
+	w.bit(true).bit(v != 0).bit(v < 0)
+	if v < 0 {
+		v = -v
+	}
+	if v != 0 {
+		w.bits(uint64(v-1), 11)
+	}
+	return w.bits(0, 5)
+}
+
+func prop(typ PropType, flags PropFlags, bits int) *FlatProp {
+	return &FlatProp{Prop: &SendProp{Type: typ, Flags: flags, NumBits: bits}}
+}
+
+func TestDecodeProp(t *testing.T) {
+	quantized := prop(PropFloat, 0, 10)
+	quantized.Prop.LowValue, quantized.Prop.HighValue = -10, 10
+	array := prop(PropArray, 0, 0)
+	array.Prop.NumElements = 5
+	array.ArrayElem = &SendProp{Type: PropInt, Flags: PropUnsigned, NumBits: 6}
+
+	tests := []struct {
+		name     string
+		prop     *FlatProp
+		data     *bitWriter
+		expected interface{}
+	}{
+		{"unsigned int", prop(PropInt, PropUnsigned, 7), new(bitWriter).bits(100, 7), int32(100)},
+		{"signed int", prop(PropInt, 0, 7), new(bitWriter).bits(0x7f, 7), int32(-1)},
+		{"unsigned varint", prop(PropInt, PropVarInt|PropUnsigned, 32), new(bitWriter).varint(300), int32(300)},
+		{"signed varint", prop(PropInt, PropVarInt, 32), new(bitWriter).varint(5), int32(-3)},
+		{"unsigned int64", prop(PropInt64, PropUnsigned, 64), new(bitWriter).bits(76561198000000001, 64), int64(76561198000000001)},
+		{"signed int64", prop(PropInt64, 0, 40), new(bitWriter).bit(true).bits(1<<35, 39), int64(-1 << 35)},
+		{"varint int64", prop(PropInt64, PropVarInt, 64), new(bitWriter).varint(3), int64(-2)},
+		{"noscale float", prop(PropFloat, PropNoScale, 32), new(bitWriter).float(250.5), float32(250.5)},
+		{"quantized float", quantized, new(bitWriter).bits(1023, 10), float32(10)},
+		{"coord float", prop(PropFloat, PropCoord, 0), new(bitWriter).bit(true).bit(false).bit(true).bits(9, 14), float32(-10)},
+		{"coord mp float", prop(PropFloat, PropCoordMP, 0), new(bitWriter).coordMP(-42), float32(-42)},
+		{"cell coord", prop(PropFloat, PropCellCoordIntegral, 12), new(bitWriter).bits(1000, 12), float32(1000)},
+		{"vector", prop(PropVector, PropCoordMP, 0), new(bitWriter).coordMP(1).coordMP(-2).coordMP(3), Vector{1, -2, 3}},
+		{"normal vector", prop(PropVector, PropNormal, 0), new(bitWriter).bit(false).bits(0, 11).bit(true).bits(2047, 11).bit(false), Vector{0, -1, 0}},
+		{"vectorxy", prop(PropVectorXY, PropNoScale, 0), new(bitWriter).float(1.5).float(-2.5), Vector{1.5, -2.5, 0}},
+		{"string", prop(PropString, 0, 0), new(bitWriter).bits(5, 9).bytes([]byte("de_nu")), "de_nu"},
+		{"array", array, new(bitWriter).bits(3, 3).bits(1, 6).bits(2, 6).bits(63, 6), []interface{}{int32(1), int32(2), int32(63)}},
+	}
+	for _, tt := range tests {
+		r := bitstream.NewReader(tt.data.b)
+		v := decodeProp(r, tt.prop)
+		if !reflect.DeepEqual(v, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.name, tt.expected, v)
+		}
+		if r.Pos() != tt.data.n || r.Err() != nil {
+			t.Errorf("%s: expected to read %d bits, read %d with error %v", tt.name, tt.data.n, r.Pos(), r.Err())
+		}
+	}
+}
+
+func TestCheckProp(t *testing.T) {
+	elements := func(n int) *SendProp {
+		return &SendProp{Type: PropArray, NumElements: n}
+	}
+	tests := []struct {
+		prop  *SendProp
+		valid bool
+	}{
+		{prop(PropInt, 0, 0).Prop, true},
+		{prop(PropInt, PropUnsigned, 32).Prop, true},
+		{prop(PropInt, 0, 33).Prop, false},
+		{prop(PropInt, PropVarInt, 64).Prop, true},
+		{prop(PropInt64, 0, 64).Prop, true},
+		{prop(PropInt64, PropUnsigned, 0).Prop, false},
+		{prop(PropInt64, 0, 65).Prop, false},
+		{prop(PropFloat, 0, 10).Prop, true},
+		{prop(PropFloat, 0, 0).Prop, false},
+		{prop(PropVector, 0, 0).Prop, false},
+		{prop(PropFloat, 0, 33).Prop, false},
+		{prop(PropFloat, PropCoordMP, 0).Prop, true},
+		{prop(PropFloat, PropNoScale, 0).Prop, true},
+		{prop(PropFloat, PropCellCoordIntegral, 0).Prop, true},
+		{prop(PropFloat, PropCellCoord, 33).Prop, false},
+		{elements(maxArrayElements), true},
+		{elements(maxArrayElements + 1), false},
+		{elements(-1), false},
+	}
+	for _, tt := range tests {
+		if err := checkProp(tt.prop); (err == nil) != tt.valid {
+			t.Errorf("%v prop with flags %x, %d bits and %d elements: expected valid %v, got %v",
+				tt.prop.Type, tt.prop.Flags, tt.prop.NumBits, tt.prop.NumElements, tt.valid, err)
+		}
+	}
+}
+
+func TestEqualValues(t *testing.T) {
+	tests := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{int32(1), int32(1), true},
+		{int32(1), int64(1), false},
+		{nil, int32(0), false},
+		{Vector{1, 2, 3}, Vector{1, 2, 3}, true},
+		{[]interface{}{int32(1)}, []interface{}{int32(1)}, true},
+		{[]interface{}{int32(1)}, []interface{}{int32(2)}, false},
+		{[]interface{}{int32(1)}, []interface{}{int32(1), int32(1)}, false},
+		{[]interface{}{}, nil, false},
+		{nil, []interface{}{}, false},
+	}
+	for _, tt := range tests {
+		if eq := equalValues(tt.a, tt.b); eq != tt.equal {
+			t.Errorf("equalValues(%#v, %#v): expected %v", tt.a, tt.b, tt.equal)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/ajmadsen/replayanalyzer/bitstream"
+)
+
+// Names of the string tables of CS:GO that demo analysis relies on.
+const (
+	// TableUserInfo holds the PlayerInfo of each player slot.
+	TableUserInfo = "userinfo"
+	// TableModelPrecache holds the names of the models, by model index.
+	TableModelPrecache = "modelprecache"
+	// TableInstanceBaseline holds the baseline props of server classes,
+	// keyed by class id.
+	TableInstanceBaseline = "instancebaseline"
+)
+
+// Encoding limits of string tables.
+const (
+	// historySize is the number of keys an entry can take a prefix from.
+	historySize   = 32
+	substringBits = 5
+	// maxUserDataBits is the size of the length of variable size user
+	// data.
+	maxUserDataBits = 14
+	maxKeyLength    = 1024
+)
+
+// StringEntry is an entry of a string table: a string and optional user
+// data.
+type StringEntry struct {
+	Key  string
+	Data []byte
+}
+
+// StringTable is a networked table of strings, such as the names of the
+// precached models.
+type StringTable struct {
+	ID                int
+	Name              string
+	MaxEntries        int
+	UserDataFixedSize bool
+	UserDataSize      int
+	UserDataSizeBits  int
+	Flags             int
+	// Entries are the entries of the table by index.
+	Entries []StringEntry
+	// ClientEntries are the entries the recording client added itself,
+	// which only snapshots of the tables hold.
+	ClientEntries []StringEntry
+}
+
+// Lookup returns the index of the entry with key, or -1.
+func (t *StringTable) Lookup(key string) int {
+	for i := range t.Entries {
+		if t.Entries[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// StringTableStore holds the string tables of a demo, kept up to date from
+// its CreateStringTable and UpdateStringTable messages and StringTables
+// frames.
+type StringTableStore struct {
+	tables []*StringTable
+	byName map[string]*StringTable
+	hooks  []func(t *StringTable, index int)
+}
+
+// NewStringTableStore returns an empty store.
+func NewStringTableStore() *StringTableStore {
+	return &StringTableStore{byName: map[string]*StringTable{}}
+}
+
+// Tables returns the tables in the order they were created, which is the
+// order of their ids.
+func (s *StringTableStore) Tables() []*StringTable {
+	return s.tables
+}
+
+// Table returns the table name, or nil.
+func (s *StringTableStore) Table(name string) *StringTable {
+	return s.byName[name]
+}
+
+// OnEntry registers fn to be called when the entry at index of t is added
+// or changed.
+func (s *StringTableStore) OnEntry(fn func(t *StringTable, index int)) {
+	s.hooks = append(s.hooks, fn)
+}
+
+// Create adds the table created by m, with its initial entries.
+func (s *StringTableStore) Create(m *CreateStringTable) (*StringTable, error) {
+	t := &StringTable{
+		ID:                len(s.tables),
+		Name:              m.Name,
+		MaxEntries:        int(m.MaxEntries),
+		UserDataFixedSize: m.UserDataFixedSize,
+		UserDataSize:      int(m.UserDataSize),
+		UserDataSizeBits:  int(m.UserDataSizeBits),
+		Flags:             int(m.Flags),
+	}
+	s.tables = append(s.tables, t)
+	s.byName[t.Name] = t
+	return t, s.parseEntries(t, int(m.NumEntries), m.StringData)
+}
+
+// Update applies the changes of m to its table.
+func (s *StringTableStore) Update(m *UpdateStringTable) (*StringTable, error) {
+	if m.TableID < 0 || int(m.TableID) >= len(s.tables) {
+		return nil, fmt.Errorf("demo: update of unknown string table %d", m.TableID)
+	}
+	t := s.tables[m.TableID]
+	return t, s.parseEntries(t, int(m.NumChangedEntries), m.StringData)
+}
+
+// parseEntries reads n changed entries of t from data. Keys can start with a
+// prefix of one of the 32 keys before them, and entries that already exist
+// keep their key.
+func (s *StringTableStore) parseEntries(t *StringTable, n int, data []byte) error {
+	r := bitstream.NewReader(data)
+	if r.ReadBit() {
+		return fmt.Errorf("demo: string table %s uses dictionary encoding", t.Name)
+	}
+	indexBits := bits.Len(uint(t.MaxEntries)) - 1
+	if indexBits < 0 {
+		indexBits = 0
+	}
+
+	var history []string
+	index := -1
+	for i := 0; i < n; i++ {
+		if r.ReadBit() {
+			index++
+		} else {
+			index = int(r.ReadBits(indexBits))
+		}
+		if index < 0 || index >= t.MaxEntries {
+			return fmt.Errorf("demo: string table %s index %d out of range", t.Name, index)
+		}
+
+		var key string
+		hasKey := r.ReadBit()
+		if hasKey {
+			if r.ReadBit() {
+				h := int(r.ReadBits(substringBits))
+				if h >= len(history) {
+					return fmt.Errorf("demo: string table %s key refers to history entry %d of %d", t.Name, h, len(history))
+				}
+				prefix := history[h]
+				if n := int(r.ReadBits(substringBits)); n < len(prefix) {
+					prefix = prefix[:n]
+				}
+				key = prefix + r.ReadStringMax(maxKeyLength)
+			} else {
+				key = r.ReadStringMax(maxKeyLength)
+			}
+		}
+
+		var userData []byte
+		hasData := r.ReadBit()
+		if hasData {
+			if t.UserDataFixedSize {
+				userData = readBitsToBytes(r, t.UserDataSizeBits)
+			} else {
+				userData = r.ReadBytes(int(r.ReadBits(maxUserDataBits)))
+			}
+		}
+		if r.Err() != nil {
+			return fmt.Errorf("demo: string table %s truncated at entry %d", t.Name, index)
+		}
+
+		if index < len(t.Entries) {
+			key = t.Entries[index].Key
+			if hasData {
+				t.Entries[index].Data = userData
+			}
+		} else {
+			for len(t.Entries) <= index {
+				t.Entries = append(t.Entries, StringEntry{})
+			}
+			t.Entries[index] = StringEntry{Key: key, Data: userData}
+		}
+		if len(history) == historySize {
+			history = history[1:]
+		}
+		history = append(history, key)
+		s.fire(t, index)
+	}
+	return nil
+}
+
+// readBitsToBytes reads n bits into bytes, the last one holding the bits
+// left over.
+func readBitsToBytes(r *bitstream.Reader, n int) []byte {
+	b := make([]byte, (n+7)/8)
+	for i := range b {
+		bits := 8
+		if rest := n - 8*i; rest < 8 {
+			bits = rest
+		}
+		b[i] = byte(r.ReadBits(bits))
+	}
+	return b
+}
+
+func (s *StringTableStore) fire(t *StringTable, index int) {
+	for _, fn := range s.hooks {
+		fn(t, index)
+	}
+}
+
+// ReadSnapshot reads the data of a StringTables frame, which holds the full
+// contents of the tables, replacing their entries.
+func (s *StringTableStore) ReadSnapshot(data []byte) error {
+	r := bitstream.NewReader(data)
+	n := int(r.ReadBits(8))
+	for i := 0; i < n; i++ {
+		name := r.ReadStringMax(256)
+		t := s.byName[name]
+		if t == nil {
+			t = &StringTable{ID: len(s.tables), Name: name}
+			s.tables = append(s.tables, t)
+			s.byName[name] = t
+		}
+		t.Entries = readSnapshotEntries(r)
+		if r.ReadBit() {
+			t.ClientEntries = readSnapshotEntries(r)
+		} else {
+			t.ClientEntries = nil
+		}
+		if r.Err() != nil {
+			return fmt.Errorf("demo: string table snapshot truncated in table %s", name)
+		}
+		if t.MaxEntries < len(t.Entries) {
+			t.MaxEntries = len(t.Entries)
+		}
+		for j := range t.Entries {
+			s.fire(t, j)
+		}
+	}
+	return r.Err()
+}
+
+func readSnapshotEntries(r *bitstream.Reader) []StringEntry {
+	entries := make([]StringEntry, r.ReadBits(16))
+	for i := range entries {
+		entries[i].Key = r.ReadStringMax(4096)
+		if r.ReadBit() {
+			entries[i].Data = r.ReadBytes(int(r.ReadBits(16)))
+		}
+		if r.Err() != nil {
+			return entries[:i]
+		}
+	}
+	return entries
+}
+
+// PlayerInfo is the player_info_t of a player, the user data of the
+// userinfo table.
+type PlayerInfo struct {
+	// Slot is the index of the entry in the userinfo table. The entity
+	// index of the player is Slot+1.
+	Slot int
+
+	Version         uint64
+	XUID            uint64
+	Name            string
+	UserID          int
+	GUID            string
+	FriendsID       uint32
+	FriendsName     string
+	FakePlayer      bool
+	HLTV            bool
+	CustomFiles     [4]uint32
+	FilesDownloaded uint8
+}
+
+// playerInfoSize is the size of player_info_t without its trailing padding.
+const playerInfoSize = 337
+
+var errShortPlayerInfo = errors.New("demo: player info truncated")
+
+// ParsePlayerInfo parses the user data of an entry of the userinfo table.
+func ParsePlayerInfo(data []byte) (*PlayerInfo, error) {
+	if len(data) < playerInfoSize {
+		return nil, errShortPlayerInfo
+	}
+	be := func(b []byte) uint64 {
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v
+	}
+	le32 := func(b []byte) uint32 {
+		return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+	}
+
+	p := &PlayerInfo{
+		// the integers are big endian, except for the CRCs of the custom
+		// files
+		Version:         be(data[0:8]),
+		XUID:            be(data[8:16]),
+		Name:            cString(data[16:144]),
+		UserID:          int(int32(be(data[144:148]))),
+		GUID:            cString(data[148:181]),
+		FriendsID:       uint32(be(data[184:188])),
+		FriendsName:     cString(data[188:316]),
+		FakePlayer:      data[316] != 0,
+		HLTV:            data[317] != 0,
+		FilesDownloaded: data[336],
+	}
+	for i := range p.CustomFiles {
+		p.CustomFiles[i] = le32(data[320+4*i:])
+	}
+	return p, nil
+}
+
+// Players returns the players of the userinfo table, in slot order. Slots
+// without user data are left out.
+func (s *StringTableStore) Players() ([]*PlayerInfo, error) {
+	t := s.byName[TableUserInfo]
+	if t == nil {
+		return nil, nil
+	}
+	var players []*PlayerInfo
+	for i, e := range t.Entries {
+		if len(e.Data) == 0 {
+			continue
+		}
+		p, err := ParsePlayerInfo(e.Data)
+		if err != nil {
+			return players, fmt.Errorf("%v in slot %d", err, i)
+		}
+		p.Slot = i
+		players = append(players, p)
+	}
+	return players, nil
+}
+
+// Player returns the player with userID, or nil.
+func (s *StringTableStore) Player(userID int) *PlayerInfo {
+	players, _ := s.Players()
+	for _, p := range players {
+		if p.UserID == userID {
+			return p
+		}
+	}
+	return nil
+}
+
+// Models returns the names of the precached models by model index.
+func (s *StringTableStore) Models() []string {
+	t := s.byName[TableModelPrecache]
+	if t == nil {
+		return nil
+	}
+	models := make([]string, len(t.Entries))
+	for i, e := range t.Entries {
+		models[i] = e.Key
+	}
+	return models
+}
+
+// Model returns the name of the model with index, or "".
+func (s *StringTableStore) Model(index int) string {
+	t := s.byName[TableModelPrecache]
+	if t == nil || index < 0 || index >= len(t.Entries) {
+		return ""
+	}
+	return t.Entries[index].Key
+}
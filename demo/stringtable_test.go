@@ -0,0 +1,295 @@
+package demo
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stringEntry is an entry of a string table update. An index of -1 is the
+// entry after the last, and a prefix of length n of history entry h is
+// written for n > 0.
+type stringEntry struct {
+	index  int
+	key    string
+	noKey  bool
+	h, n   int
+	data   []byte
+	noData bool
+}
+
+// writeStringEntries encodes entries the way the server writes the entries
+// of a table with maxEntries entries. Fixed size user data of dataBits bits
+// is written for dataBits > 0.
+func writeStringEntries(maxEntries, dataBits int, entries ...stringEntry) []byte {
+	indexBits := 0
+	for m := maxEntries; m > 1; m >>= 1 {
+		indexBits++
+	}
+	w := new(bitWriter).bit(false)
+	last := -1
+	for _, e := range entries {
+		if e.index < 0 || e.index == last+1 {
+			w.bit(true)
+			last++
+		} else {
+			w.bit(false).bits(uint64(e.index), indexBits)
+			last = e.index
+		}
+		w.bit(!e.noKey)
+		if !e.noKey {
+			w.bit(e.n > 0)
+			if e.n > 0 {
+				w.bits(uint64(e.h), 5).bits(uint64(e.n), 5)
+			}
+			w.bytes([]byte(e.key)).bits(0, 8)
+		}
+		w.bit(!e.noData)
+		if !e.noData {
+			if dataBits > 0 {
+				for i := 0; i < dataBits; i += 8 {
+					n := dataBits - i
+					if n > 8 {
+						n = 8
+					}
+					w.bits(uint64(e.data[i/8]), n)
+				}
+			} else {
+				w.bits(uint64(len(e.data)), 14).bytes(e.data)
+			}
+		}
+	}
+	return w.b
+}
+
+// playerInfo encodes p as the game writes player_info_t.
+func playerInfo(p *PlayerInfo) []byte {
+	b := make([]byte, 344)
+	binary.BigEndian.PutUint64(b, p.Version)
+	binary.BigEndian.PutUint64(b[8:], p.XUID)
+	copy(b[16:143], p.Name)
+	binary.BigEndian.PutUint32(b[144:], uint32(p.UserID))
+	copy(b[148:180], p.GUID)
+	binary.BigEndian.PutUint32(b[184:], p.FriendsID)
+	copy(b[188:315], p.FriendsName)
+	if p.FakePlayer {
+		b[316] = 1
+	}
+	if p.HLTV {
+		b[317] = 1
+	}
+	for i, crc := range p.CustomFiles {
+		binary.LittleEndian.PutUint32(b[320+4*i:], crc)
+	}
+	b[336] = p.FilesDownloaded
+	return b
+}
+
+var testPlayers = []*PlayerInfo{
+	{Slot: 0, Version: 0x0102030405060708, XUID: 76561197960287930, Name: "gaben", UserID: 2, GUID: "STEAM_1:0:11101", FriendsID: 22202, CustomFiles: [4]uint32{1, 2, 3, 4}, FilesDownloaded: 5},
+	{Slot: 2, Name: "GOTV", UserID: 3, GUID: "BOT", FakePlayer: true, HLTV: true},
+}
+
+func TestParsePlayerInfo(t *testing.T) {
+	for _, expected := range testPlayers {
+		p, err := ParsePlayerInfo(playerInfo(expected))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Slot = expected.Slot
+		if !reflect.DeepEqual(p, expected) {
+			t.Errorf("expected %+v, got %+v", expected, p)
+		}
+	}
+	if _, err := ParsePlayerInfo(make([]byte, 336)); err != errShortPlayerInfo {
+		t.Errorf("expected errShortPlayerInfo, got %v", err)
+	}
+}
+
+func TestStringTableStore(t *testing.T) {
+	s := NewStringTableStore()
+	var changed []string
+	s.OnEntry(func(t *StringTable, index int) {
+		changed = append(changed, t.Name+":"+t.Entries[index].Key)
+	})
+
+	models, err := s.Create(&CreateStringTable{
+		Name:       TableModelPrecache,
+		MaxEntries: 1024,
+		NumEntries: 4,
+		StringData: writeStringEntries(1024, 0,
+			stringEntry{index: -1, key: "", noData: true},
+			stringEntry{index: -1, key: "maps/de_dust2.bsp", noData: true},
+			stringEntry{index: -1, key: "models/player/ctm_sas.mdl", noData: true},
+			stringEntry{index: 5, key: "tm_phoenix.mdl", h: 2, n: 14, noData: true},
+		),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := s.Create(&CreateStringTable{
+		Name:       TableUserInfo,
+		MaxEntries: 256,
+		NumEntries: 2,
+		StringData: writeStringEntries(256, 0,
+			stringEntry{index: 0, key: "76561197960287930", data: playerInfo(testPlayers[0])},
+			stringEntry{index: 2, key: "BOT", data: playerInfo(testPlayers[1])},
+		),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models.ID != 0 || users.ID != 1 || s.Table(TableUserInfo) != users || len(s.Tables()) != 2 {
+		t.Fatalf("unexpected tables %+v", s.Tables())
+	}
+
+	expectedModels := []string{"", "maps/de_dust2.bsp", "models/player/ctm_sas.mdl", "", "", "models/player/tm_phoenix.mdl"}
+	if m := s.Models(); !reflect.DeepEqual(m, expectedModels) {
+		t.Errorf("expected models %q, got %q", expectedModels, m)
+	}
+	if m := s.Model(2); m != expectedModels[2] {
+		t.Errorf("expected model %q, got %q", expectedModels[2], m)
+	}
+	if m := s.Model(6); m != "" {
+		t.Errorf("expected no model 6, got %q", m)
+	}
+	if i := models.Lookup("maps/de_dust2.bsp"); i != 1 {
+		t.Errorf("expected index 1, got %d", i)
+	}
+
+	players, err := s.Players()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(players, testPlayers) {
+		t.Errorf("expected players %+v, got %+v", testPlayers, players)
+	}
+
+	// updates of existing entries keep their key and can leave out the data
+	renamed := *testPlayers[1]
+	renamed.Name = "SourceTV"
+	changed = nil
+	_, err = s.Update(&UpdateStringTable{
+		TableID:           1,
+		NumChangedEntries: 2,
+		StringData: writeStringEntries(256, 0,
+			stringEntry{index: 0, noKey: true, noData: true},
+			stringEntry{index: 2, key: "ignored", data: playerInfo(&renamed)},
+		),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedChanged := []string{"userinfo:76561197960287930", "userinfo:BOT"}
+	if !reflect.DeepEqual(changed, expectedChanged) {
+		t.Errorf("expected changes %q, got %q", expectedChanged, changed)
+	}
+	if p := s.Player(3); p == nil || p.Name != "SourceTV" || p.Slot != 2 {
+		t.Errorf("expected SourceTV in slot 2, got %+v", p)
+	}
+	if p := s.Player(2); p == nil || p.Name != "gaben" {
+		t.Errorf("expected gaben, got %+v", p)
+	}
+	if p := s.Player(4); p != nil {
+		t.Errorf("expected no player 4, got %+v", p)
+	}
+}
+
+func TestStringTableFixedSize(t *testing.T) {
+	s := NewStringTableStore()
+	tbl, err := s.Create(&CreateStringTable{
+		Name:              "lightstyles",
+		MaxEntries:        64,
+		NumEntries:        2,
+		UserDataFixedSize: true,
+		UserDataSize:      2,
+		UserDataSizeBits:  12,
+		StringData: writeStringEntries(64, 12,
+			stringEntry{index: -1, key: "0", data: []byte{0xab, 0x0c}},
+			stringEntry{index: -1, key: "1", noData: true},
+		),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []StringEntry{{"0", []byte{0xab, 0x0c}}, {"1", nil}}
+	if !reflect.DeepEqual(tbl.Entries, expected) {
+		t.Errorf("expected %v, got %v", expected, tbl.Entries)
+	}
+}
+
+func TestStringTablesInvalid(t *testing.T) {
+	create := func(n int32, data []byte) func(*StringTableStore) error {
+		return func(s *StringTableStore) error {
+			_, err := s.Create(&CreateStringTable{Name: "t", MaxEntries: 8, NumEntries: n, StringData: data})
+			return err
+		}
+	}
+	tests := []struct {
+		name  string
+		apply func(*StringTableStore) error
+		err   string
+	}{
+		{"dictionary", create(1, []byte{1}), "dictionary encoding"},
+		{"out of range", create(2, writeStringEntries(8, 0, stringEntry{index: 7, key: "x"}, stringEntry{index: -1, key: "y"})), "index 8 out of range"},
+		{"bad history", create(1, writeStringEntries(8, 0, stringEntry{key: "x", h: 0, n: 1})), "history entry 0 of 0"},
+		{"truncated", create(2, writeStringEntries(8, 0, stringEntry{key: "x"})), "truncated at entry"},
+		{"unknown table", func(s *StringTableStore) error {
+			_, err := s.Update(&UpdateStringTable{TableID: 3})
+			return err
+		}, "unknown string table 3"},
+		{"truncated snapshot", func(s *StringTableStore) error {
+			return s.ReadSnapshot([]byte{1, 'x', 0, 5})
+		}, "snapshot truncated in table x"},
+	}
+	for _, tt := range tests {
+		err := tt.apply(NewStringTableStore())
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+// stringTableSnapshot encodes tables as the data of a StringTables frame.
+func stringTableSnapshot(tables []*StringTable) []byte {
+	w := new(bitWriter).bits(uint64(len(tables)), 8)
+	entries := func(entries []StringEntry) {
+		w.bits(uint64(len(entries)), 16)
+		for _, e := range entries {
+			w.bytes([]byte(e.Key)).bits(0, 8).bit(e.Data != nil)
+			if e.Data != nil {
+				w.bits(uint64(len(e.Data)), 16).bytes(e.Data)
+			}
+		}
+	}
+	for _, t := range tables {
+		w.bytes([]byte(t.Name)).bits(0, 8)
+		entries(t.Entries)
+		w.bit(t.ClientEntries != nil)
+		if t.ClientEntries != nil {
+			entries(t.ClientEntries)
+		}
+	}
+	return w.b
+}
+
+func TestReadSnapshot(t *testing.T) {
+	s := NewStringTableStore()
+	if _, err := s.Create(&CreateStringTable{Name: "downloadables", MaxEntries: 8, StringData: []byte{0}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*StringTable{
+		{ID: 0, Name: "downloadables", MaxEntries: 8, Entries: []StringEntry{{"sound/a.wav", nil}}},
+		{ID: 1, Name: TableUserInfo, MaxEntries: 3, Entries: []StringEntry{{"1", playerInfo(testPlayers[0])}, {"", nil}, {"BOT", nil}}, ClientEntries: []StringEntry{{"local", []byte{1}}}},
+	}
+	if err := s.ReadSnapshot(stringTableSnapshot(expected)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Tables(), expected) {
+		t.Errorf("expected %+v, got %+v", expected, s.Tables())
+	}
+	if players, err := s.Players(); err != nil || len(players) != 1 || players[0].Name != "gaben" {
+		t.Errorf("expected gaben, got %+v and %v", players, err)
+	}
+}
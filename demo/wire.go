@@ -0,0 +1,172 @@
+package demo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errVarint = errors.New("demo: invalid varint")
+
+// readVarint decodes the varint at the start of b, returning its value and
+// length.
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errVarint
+}
+
+// wireDecoder decodes the fields of a protocol buffer message. Byte fields
+// alias the decoded data, string fields are copies.
+type wireDecoder struct {
+	b []byte
+	// field and wireType are those of the current field
+	field    int
+	wireType int
+}
+
+// next moves to the next field, returning false at the end of the message.
+func (d *wireDecoder) next() (bool, error) {
+	if len(d.b) == 0 {
+		return false, nil
+	}
+	key, err := d.varint()
+	if err != nil {
+		return false, err
+	}
+	d.field, d.wireType = int(key>>3), int(key&7)
+	if d.field == 0 {
+		return false, fmt.Errorf("demo: invalid field number 0")
+	}
+	return true, nil
+}
+
+func (d *wireDecoder) varint() (uint64, error) {
+	v, n, err := readVarint(d.b)
+	if err != nil {
+		return 0, err
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *wireDecoder) expect(wireType int) error {
+	if d.wireType != wireType {
+		return fmt.Errorf("demo: field %d has wire type %d, expected %d", d.field, d.wireType, wireType)
+	}
+	return nil
+}
+
+func (d *wireDecoder) uint64() (uint64, error) {
+	if err := d.expect(wireVarint); err != nil {
+		return 0, err
+	}
+	return d.varint()
+}
+
+// int32 decodes an int32 field. Negative values are sign extended to 64
+// bits on the wire.
+func (d *wireDecoder) int32() (int32, error) {
+	v, err := d.uint64()
+	return int32(v), err
+}
+
+func (d *wireDecoder) uint32() (uint32, error) {
+	v, err := d.uint64()
+	return uint32(v), err
+}
+
+func (d *wireDecoder) bool() (bool, error) {
+	v, err := d.uint64()
+	return v != 0, err
+}
+
+func (d *wireDecoder) fixed32() (uint32, error) {
+	if err := d.expect(wireFixed32); err != nil {
+		return 0, err
+	}
+	if len(d.b) < 4 {
+		return 0, errShortMessage
+	}
+	v := binary.LittleEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v, nil
+}
+
+func (d *wireDecoder) fixed64() (uint64, error) {
+	if err := d.expect(wireFixed64); err != nil {
+		return 0, err
+	}
+	if len(d.b) < 8 {
+		return 0, errShortMessage
+	}
+	v := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v, nil
+}
+
+func (d *wireDecoder) float() (float32, error) {
+	v, err := d.fixed32()
+	return math.Float32frombits(v), err
+}
+
+func (d *wireDecoder) bytes() ([]byte, error) {
+	if err := d.expect(wireBytes); err != nil {
+		return nil, err
+	}
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errShortMessage
+	}
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *wireDecoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+// skip skips the current field.
+func (d *wireDecoder) skip() error {
+	var n int
+	switch d.wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	default:
+		return fmt.Errorf("demo: field %d has unsupported wire type %d", d.field, d.wireType)
+	}
+	if len(d.b) < n {
+		return errShortMessage
+	}
+	d.b = d.b[n:]
+	return nil
+}
+
+var errShortMessage = errors.New("demo: message truncated")
@@ -0,0 +1,145 @@
+package keyvalues
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Conditions is the set of symbols that are true when evaluating
+// conditionals like [$WIN32] or [!$X360 && !$PS3]. Symbols are matched
+// ignoring case; undefined symbols are false.
+type Conditions map[string]bool
+
+// PlatformConditions returns the symbols the engine defines on goos.
+func PlatformConditions(goos string) Conditions {
+	switch goos {
+	case "windows":
+		return Conditions{"$WIN32": true, "$WINDOWS": true}
+	case "linux":
+		return Conditions{"$LINUX": true, "$POSIX": true}
+	case "darwin":
+		return Conditions{"$OSX": true, "$POSIX": true}
+	}
+	return Conditions{}
+}
+
+// Eval evaluates a conditional expression. Expressions combine symbols with
+// !, && and || and may use parentheses. An empty expression is true.
+func (c Conditions) Eval(expr string) (bool, error) {
+	e := &condExpr{c: c, s: expr}
+	e.skipSpace()
+	if e.pos == len(e.s) {
+		return true, nil
+	}
+	v := e.or()
+	e.skipSpace()
+	if e.err == nil && e.pos < len(e.s) {
+		e.fail("unexpected %q", e.s[e.pos:])
+	}
+	if e.err != nil {
+		return false, e.err
+	}
+	return v, nil
+}
+
+type condExpr struct {
+	c   Conditions
+	s   string
+	pos int
+	err error
+}
+
+// fail records the first error in the expression.
+func (e *condExpr) fail(format string, args ...interface{}) {
+	if e.err == nil {
+		e.err = fmt.Errorf("conditional [%s]: %s", e.s, fmt.Sprintf(format, args...))
+	}
+}
+
+func (e *condExpr) skipSpace() {
+	for e.pos < len(e.s) && (e.s[e.pos] == ' ' || e.s[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+func (e *condExpr) accept(op string) bool {
+	e.skipSpace()
+	if strings.HasPrefix(e.s[e.pos:], op) {
+		e.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (e *condExpr) or() bool {
+	v := e.and()
+	for e.accept("||") {
+		// evaluate both sides to advance past the right hand side
+		r := e.and()
+		v = v || r
+	}
+	return v
+}
+
+func (e *condExpr) and() bool {
+	v := e.unary()
+	for e.accept("&&") {
+		r := e.unary()
+		v = v && r
+	}
+	return v
+}
+
+func (e *condExpr) unary() bool {
+	if e.accept("!") {
+		return !e.unary()
+	}
+	if e.accept("(") {
+		v := e.or()
+		if !e.accept(")") {
+			e.fail("missing )")
+		}
+		return v
+	}
+
+	e.skipSpace()
+	start := e.pos
+	for e.pos < len(e.s) && !strings.ContainsRune(" \t!&|()", rune(e.s[e.pos])) {
+		e.pos++
+	}
+	sym := e.s[start:e.pos]
+	if sym == "" {
+		e.fail("missing symbol")
+		return false
+	}
+	for k, v := range e.c {
+		if strings.EqualFold(k, sym) {
+			return v
+		}
+	}
+	return false
+}
+
+// ApplyConditions removes every node below n whose conditional is false
+// under c. It stops at the first conditional that is not a valid expression,
+// leaving n partly filtered.
+func (n *Node) ApplyConditions(c Conditions) error {
+	kept := n.Children[:0]
+	for _, child := range n.Children {
+		if child.Condition != "" {
+			v, err := c.Eval(child.Condition)
+			if err != nil {
+				return err
+			}
+			if !v {
+				continue
+			}
+		}
+		if err := child.ApplyConditions(c); err != nil {
+			return err
+		}
+		kept = append(kept, child)
+	}
+	n.Children = kept
+	return nil
+}
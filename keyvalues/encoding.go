@@ -0,0 +1,41 @@
+package keyvalues
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"unicode/utf16"
+)
+
+// decode returns a reader of the UTF-8 text of r. Files starting with a
+// UTF-16 byte order mark, like the game's localization files, are converted;
+// anything else is assumed to be UTF-8 already.
+func decode(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(2)
+	if len(bom) < 2 {
+		return br, nil
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case bom[0] == 0xff && bom[1] == 0xfe:
+		order = binary.LittleEndian
+	case bom[0] == 0xfe && bom[1] == 0xff:
+		order = binary.BigEndian
+	default:
+		return br, nil
+	}
+
+	b, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 2; i+1 < len(b); i += 2 {
+		u = append(u, order.Uint16(b[i:]))
+	}
+	return bufio.NewReader(bytes.NewReader([]byte(string(utf16.Decode(u))))), nil
+}
@@ -0,0 +1,128 @@
+// Package keyvalues reads and writes Valve's text KeyValues format, used by
+// Steam's .vdf and .acf files and by many Source engine game files.
+//
+// Unlike a map, the tree returned by Parse keeps key order, duplicate keys,
+// comments and conditionals, so a file can be edited and written back
+// without losing anything Steam or a person put in it. Load additionally
+// resolves #base and #include directives and evaluates conditionals, as the
+// engine does when reading game files.
+package keyvalues
+
+import (
+	"strings"
+)
+
+// Node is a key with either a string value or, if Section is set, a list of
+// child nodes.
+type Node struct {
+	Key      string
+	Value    string
+	Section  bool
+	Children []*Node
+
+	// Condition is the platform conditional following the node, without the
+	// brackets, e.g. "$WIN32" or "!$X360".
+	Condition string
+
+	// Comments are the comment lines preceding the node, including the
+	// leading "//".
+	Comments []string
+	// TrailingComment is a comment on the same line as the value or the
+	// closing brace.
+	TrailingComment string
+	// EndComments are the comment lines before the closing brace of a
+	// section, or at the end of the file for the root.
+	EndComments []string
+
+	// Line is the line the key was read from, or 0 for created nodes.
+	Line int
+}
+
+// NewRoot returns an empty document.
+func NewRoot() *Node {
+	return &Node{Section: true}
+}
+
+// Child returns the first child named key, ignoring case like the engine
+// does, or nil.
+func (n *Node) Child(key string) *Node {
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// All returns every child named key. Files such as items_game.txt repeat
+// keys.
+func (n *Node) All(key string) []*Node {
+	var all []*Node
+	for _, c := range n.Children {
+		if strings.EqualFold(c.Key, key) {
+			all = append(all, c)
+		}
+	}
+	return all
+}
+
+// Find follows a path of keys from n and returns the node at its end, or nil.
+func (n *Node) Find(path ...string) *Node {
+	for _, k := range path {
+		if n = n.Child(k); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Get returns the value at the end of path. The boolean is false if there is
+// no such node or it is a section.
+func (n *Node) Get(path ...string) (string, bool) {
+	c := n.Find(path...)
+	if c == nil || c.Section {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// Set sets the value of the first child named key, adding the child if it
+// does not exist, and returns it.
+func (n *Node) Set(key, value string) *Node {
+	c := n.Child(key)
+	if c == nil {
+		c = &Node{Key: key}
+		n.Children = append(n.Children, c)
+	}
+	c.Value = value
+	c.Section = false
+	c.Children = nil
+	return c
+}
+
+// Ensure returns the section at the end of path, adding missing sections on
+// the way.
+func (n *Node) Ensure(path ...string) *Node {
+	for _, k := range path {
+		c := n.Child(k)
+		if c == nil {
+			c = &Node{Key: k, Section: true}
+			n.Children = append(n.Children, c)
+		}
+		n = c
+	}
+	return n
+}
+
+// Remove deletes every child named key and returns how many were removed.
+func (n *Node) Remove(key string) int {
+	kept := n.Children[:0]
+	for _, c := range n.Children {
+		if !strings.EqualFold(c.Key, key) {
+			kept = append(kept, c)
+		}
+	}
+	removed := len(n.Children) - len(kept)
+	n.Children = kept
+	return removed
+}
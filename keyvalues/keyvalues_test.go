@@ -0,0 +1,181 @@
+package keyvalues
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// steamFormatted is laid out exactly as Write lays it out, so it must round
+// trip byte for byte.
+const steamFormatted = `// written by hand
+"UserLocalConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"apps"
+				{
+					"730"
+					{
+						"LastPlayed"		"1462300000"
+						"LaunchOptions"		"-novid +exec \"autoexec.cfg\""
+					}
+					// dota
+					"570"
+					{
+						"LastPlayed"		"1461000000"
+					}
+				}
+				"path"		"C:\\Program Files (x86)\\Steam"
+			}
+		}
+	}
+	"overlay"		"1" [$WIN32] // windows only
+	"overlay"		"0" [!$WIN32]
+	"empty"
+	{
+		// nothing here
+	} // done
+}
+// end of file
+`
+
+func TestRoundTrip(t *testing.T) {
+	root, err := Parse(strings.NewReader(steamFormatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != steamFormatted {
+		t.Errorf("round trip changed the document:\n%s", out)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteError(t *testing.T) {
+	root, err := Parse(strings.NewReader(steamFormatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(errWriter{}, root); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	root, err := Parse(strings.NewReader(steamFormatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := root.Get("UserLocalConfigStore", "software", "valve", "steam", "apps", "730", "LaunchOptions"); !ok || v != `-novid +exec "autoexec.cfg"` {
+		t.Errorf("unexpected launch options %q", v)
+	}
+	if v, _ := root.Get("UserLocalConfigStore", "Software", "Valve", "Steam", "path"); v != `C:\Program Files (x86)\Steam` {
+		t.Errorf("unexpected path %q", v)
+	}
+
+	overlays := root.Child("UserLocalConfigStore").All("overlay")
+	if len(overlays) != 2 || overlays[0].Condition != "$WIN32" || overlays[1].Condition != "!$WIN32" {
+		t.Errorf("duplicate conditional keys not kept: %+v", overlays)
+	}
+	if overlays[0].TrailingComment != "// windows only" || overlays[0].Line != 27 {
+		t.Errorf("unexpected trailing comment %q on line %d", overlays[0].TrailingComment, overlays[0].Line)
+	}
+
+	apps := root.Find("UserLocalConfigStore", "Software", "Valve", "Steam", "apps")
+	if dota := apps.Child("570"); dota == nil || len(dota.Comments) != 1 || dota.Comments[0] != "// dota" {
+		t.Errorf("comment before key not kept: %+v", dota)
+	}
+}
+
+func TestParseLoose(t *testing.T) {
+	// unquoted tokens, odd spacing and unknown escapes as in hand written
+	// game files
+	src := "\ufeffGameInfo{game \"Counter-Strike\"// name\n" +
+		"FileSystem { SearchPaths { Game csgo } }\n" +
+		"icon \"materials\\overviews\\icon\"\n}"
+	root, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := root.Get("GameInfo", "game"); v != "Counter-Strike" {
+		t.Errorf("unexpected game %q", v)
+	}
+	if n := root.Find("GameInfo", "game"); n.TrailingComment != "// name" {
+		t.Errorf("unexpected trailing comment %q", n.TrailingComment)
+	}
+	if v, _ := root.Get("GameInfo", "FileSystem", "SearchPaths", "Game"); v != "csgo" {
+		t.Errorf("unexpected search path %q", v)
+	}
+	if v, _ := root.Get("GameInfo", "icon"); v != `materials\overviews\icon` {
+		t.Errorf("unexpected icon %q", v)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]int{
+		"\"a\"\n{\n\"b\" \"c\"\n":    4,
+		"\"a\" \"b\"\n}":             2,
+		"\"a\"\n\"b\n":               2,
+		"\"a\" {\n\"b\" }\n":         2,
+		"\"a\" [$WIN32\n\"b\" \"c\"": 1,
+	}
+	for src, line := range tests {
+		_, err := ParseNamed(strings.NewReader(src), "test.vdf")
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected a syntax error for %q, got %v", src, err)
+			continue
+		}
+		if serr.Line != line || serr.Name != "test.vdf" {
+			t.Errorf("expected error on line %d for %q, got %v", line, src, err)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	root, err := Parse(strings.NewReader(steamFormatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps := root.Find("UserLocalConfigStore", "Software", "Valve", "Steam", "apps")
+	apps.Ensure("730").Set("LaunchOptions", "-insecure")
+	apps.Ensure("440").Set("LaunchOptions", "-novid")
+	if n := apps.Remove("570"); n != 1 {
+		t.Errorf("expected to remove 1 node, removed %d", n)
+	}
+
+	out, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(steamFormatted, `"-novid +exec \"autoexec.cfg\""`, `"-insecure"`, 1)
+	expected = strings.Replace(expected, `					// dota
+					"570"
+					{
+						"LastPlayed"		"1461000000"
+					}
+`, `					"440"
+					{
+						"LaunchOptions"		"-novid"
+					}
+`, 1)
+	if !bytes.Equal(out, []byte(expected)) {
+		t.Errorf("unexpected edit result:\n%s", out)
+	}
+}
@@ -0,0 +1,126 @@
+package keyvalues
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// maxIncludeDepth bounds how deeply #base and #include directives can nest.
+const maxIncludeDepth = 16
+
+// Load reads the KeyValues file name and resolves its #base and #include
+// directives, which name files relative to the directory of the file
+// containing them. Keys from an #include are appended to the section the
+// directive is in. Keys from a #base are merged in where the section does not
+// already have them, so the including file overrides its base. Included
+// files are resolved the same way.
+//
+// If c is not nil, nodes whose conditional is false under c are dropped.
+func Load(name string, c Conditions) (*Node, error) {
+	return LoadFS(vfs.OS(), vfs.Abs(name), c)
+}
+
+// LoadFS is like Load, reading from fsys. name is a slash separated path, see
+// vfs.Name.
+func LoadFS(fsys fs.FS, name string, c Conditions) (*Node, error) {
+	root, err := load(fsys, path.Clean(name), map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		if err := root.ApplyConditions(c); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// load reads name and resolves its directives. loading holds the files
+// being loaded, which include name.
+func load(fsys fs.FS, name string, loading map[string]bool) (*Node, error) {
+	if loading[name] {
+		return nil, fmt.Errorf("%s: recursive #include or #base", name)
+	}
+	if len(loading) >= maxIncludeDepth {
+		return nil, fmt.Errorf("%s: #include or #base nested more than %d deep", name, maxIncludeDepth)
+	}
+	loading[name] = true
+	defer delete(loading, name)
+
+	f, err := fsys.Open(vfs.Name(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	root, err := ParseNamed(f, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolve(fsys, root, name, loading); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// resolve replaces the directives among the children of n and of its
+// sections, read from the file name, with the keys they refer to.
+func resolve(fsys fs.FS, n *Node, name string, loading map[string]bool) error {
+	var includes, bases []*Node
+	kept := n.Children[:0]
+	for _, c := range n.Children {
+		switch {
+		case strings.EqualFold(c.Key, "#include") && !c.Section:
+			includes = append(includes, c)
+		case strings.EqualFold(c.Key, "#base") && !c.Section:
+			bases = append(bases, c)
+		default:
+			kept = append(kept, c)
+		}
+	}
+	n.Children = kept
+
+	for _, c := range n.Children {
+		if c.Section {
+			if err := resolve(fsys, c, name, loading); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the engine accepts either separator
+	dir := path.Dir(name)
+	for _, c := range includes {
+		inc, err := load(fsys, path.Join(dir, strings.ReplaceAll(c.Value, `\`, "/")), loading)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, c.Line, err)
+		}
+		n.Children = append(n.Children, inc.Children...)
+	}
+	for _, c := range bases {
+		base, err := load(fsys, path.Join(dir, strings.ReplaceAll(c.Value, `\`, "/")), loading)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, c.Line, err)
+		}
+		mergeBase(n, base)
+	}
+	return nil
+}
+
+// mergeBase adds the children of base that dst does not have, descending
+// into sections present in both.
+func mergeBase(dst, base *Node) {
+	for _, b := range base.Children {
+		d := dst.Child(b.Key)
+		switch {
+		case d == nil:
+			dst.Children = append(dst.Children, b)
+		case d.Section && b.Section:
+			mergeBase(d, b)
+		}
+	}
+}
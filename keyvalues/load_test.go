@@ -0,0 +1,222 @@
+package keyvalues
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func TestConditions(t *testing.T) {
+	c := Conditions{"$WIN32": true, "$WINDOWS": true}
+	tests := map[string]bool{
+		"":                   true,
+		"$WIN32":             true,
+		"$win32":             true,
+		"!$WIN32":            false,
+		"$X360":              false,
+		"!$X360":             true,
+		"$X360 || $WIN32":    true,
+		"$WIN32 && $X360":    false,
+		"!$X360 && !$PS3":    true,
+		"!($X360 || $WIN32)": false,
+		"$OSX || $LINUX":     false,
+	}
+	for expr, expected := range tests {
+		v, err := c.Eval(expr)
+		if err != nil {
+			t.Errorf("%q: %v", expr, err)
+		} else if v != expected {
+			t.Errorf("%q: expected %v, got %v", expr, expected, v)
+		}
+	}
+
+	for _, expr := range []string{"$WIN32 garbage", "$WIN32 &&", "!", "($WIN32", "$WIN32)", "&& $WIN32"} {
+		if _, err := c.Eval(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
+
+func TestParseInvalidCondition(t *testing.T) {
+	_, err := ParseNamed(strings.NewReader("\"k\"\t\"v\"\n\"x\"\t\"y\" [$WIN32 garbage]\n"), "bad.txt")
+	if err == nil || !strings.Contains(err.Error(), "bad.txt:2") || !strings.Contains(err.Error(), "garbage") {
+		t.Errorf("expected an invalid conditional error on line 2, got %v", err)
+	}
+}
+
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	dir, err := ioutil.TempDir("", "keyvalues")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{
+		"resource/overviews/de_dust2.txt": []byte(`#base "base/overview_base.txt"
+#include "extra.txt"
+"de_dust2"
+{
+	"material"		"overviews/de_dust2"
+	"pos_x"		"-2476" [$WIN32]
+	"pos_x"		"-2400" [!$WIN32]
+	"scale"		"4.4"
+}
+`),
+		"resource/overviews/base/overview_base.txt": []byte(`"de_dust2"
+{
+	"scale"		"1.0"
+	"rotate"		"0"
+	"verticalsections"
+	{
+		"default"		"-10000 10000"
+	}
+}
+`),
+		"resource/overviews/extra.txt": []byte(`"extra" { "k" "v" }`),
+	})
+	defer os.RemoveAll(dir)
+
+	root, err := Load(filepath.Join(dir, "resource/overviews/de_dust2.txt"), Conditions{"$WIN32": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for _, c := range root.Children {
+		keys = append(keys, c.Key)
+	}
+	if strings.Join(keys, ",") != "de_dust2,extra" {
+		t.Errorf("unexpected top level keys %v", keys)
+	}
+
+	dust := root.Child("de_dust2")
+	if xs := dust.All("pos_x"); len(xs) != 1 || xs[0].Value != "-2476" {
+		t.Errorf("conditionals not applied: %+v", xs)
+	}
+	if v, _ := dust.Get("scale"); v != "4.4" {
+		t.Errorf("base overrode the file's own scale: %v", v)
+	}
+	if v, _ := dust.Get("rotate"); v != "0" {
+		t.Errorf("base value not merged: %v", v)
+	}
+	if v, _ := dust.Get("verticalsections", "default"); v != "-10000 10000" {
+		t.Errorf("base section not merged: %v", v)
+	}
+
+	// without conditions both values are kept
+	root, err = Load(filepath.Join(dir, "resource/overviews/de_dust2.txt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xs := root.Child("de_dust2").All("pos_x"); len(xs) != 2 {
+		t.Errorf("expected both conditional values, got %+v", xs)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{
+		"a.txt":      []byte("#include \"b.txt\"\n"),
+		"b.txt":      []byte("#base \"a.txt\"\n"),
+		"broken.txt": []byte("\"x\"\n{\n#include \"missing.txt\"\n\"y\" {\n}\n"),
+		"inc.txt":    []byte("\n#include \"broken.txt\"\n"),
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := Load(filepath.Join(dir, "a.txt"), nil); err == nil || !strings.Contains(err.Error(), "recursive") {
+		t.Errorf("expected a recursion error, got %v", err)
+	}
+
+	_, err := Load(filepath.Join(dir, "inc.txt"), nil)
+	if err == nil || !strings.Contains(err.Error(), "inc.txt:2:") || !strings.Contains(err.Error(), "broken.txt:6:") {
+		t.Errorf("expected error positions in both files, got %v", err)
+	}
+}
+
+func TestLoadFSNested(t *testing.T) {
+	fsys := vfstest.Tree(
+		"F/game/scripts/items.txt\n"+`"items_game"
+{
+	"prefabs"
+	{
+		#include "prefabs/weapons.txt"
+		"knife" { "name" "Knife" }
+	}
+}`,
+		"F/game/scripts/prefabs/weapons.txt\n"+`#base "..\\base.txt"
+"rifle" { "name" "Rifle" }`,
+		"F/game/scripts/base.txt\n"+`"rifle" { "name" "Base" "clip" "30" }`,
+	)
+	root, err := LoadFS(fsys, "/game/scripts/items.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefabs := root.Find("items_game", "prefabs")
+	keys := []string{}
+	for _, c := range prefabs.Children {
+		keys = append(keys, c.Key)
+	}
+	if strings.Join(keys, ",") != "knife,rifle" {
+		t.Errorf("unexpected prefabs %v", keys)
+	}
+	if v, _ := prefabs.Get("rifle", "name"); v != "Rifle" {
+		t.Errorf("base overrode the included name: %v", v)
+	}
+	if v, _ := prefabs.Get("rifle", "clip"); v != "30" {
+		t.Errorf("base of the included file not merged: %v", v)
+	}
+}
+
+func TestLoadFSDepth(t *testing.T) {
+	var files []string
+	for i := 0; i <= maxIncludeDepth; i++ {
+		files = append(files, fmt.Sprintf("F/kv/%d.txt\n#include \"%d.txt\"\n\"k%d\" \"v\"", i, i+1, i))
+	}
+	files = append(files, fmt.Sprintf("F/kv/%d.txt\n\"last\" \"v\"", maxIncludeDepth+1))
+	fsys := vfstest.Tree(files...)
+
+	if _, err := LoadFS(fsys, "/kv/0.txt", nil); err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("expected a nesting error, got %v", err)
+	}
+	root, err := LoadFS(fsys, "/kv/2.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := root.Get("last"); v != "v" {
+		t.Errorf("expected the innermost key, got %q", v)
+	}
+}
+
+func TestParseUTF16(t *testing.T) {
+	src := "\"lang\"\n{\n\t\"Language\"\t\"german\"\n\t\"Tokens\"\n\t{\n\t\t\"SFUI_Map\"\t\"Karte ä ☃\"\n\t}\n}\n"
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var b bytes.Buffer
+		binary.Write(&b, order, append([]uint16{0xfeff}, utf16.Encode([]rune(src))...))
+
+		root, err := Parse(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := root.Get("lang", "Tokens", "SFUI_Map"); v != "Karte ä ☃" {
+			t.Errorf("%v: unexpected token %q", order, v)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package keyvalues
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SyntaxError is a parse error and where it happened.
+type SyntaxError struct {
+	// Name is the name of the file being parsed, if known.
+	Name string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokString
+	tokOpen
+	tokClose
+	tokComment
+	tokCondition
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	line int
+}
+
+type scanner struct {
+	r    *bufio.Reader
+	name string
+	line int
+
+	peeked *token
+}
+
+func newScanner(r *bufio.Reader, name string) *scanner {
+	return &scanner{r: r, name: name, line: 1}
+}
+
+func (s *scanner) errorf(line int, format string, args ...interface{}) error {
+	return &SyntaxError{Name: s.name, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *scanner) unread(t token) {
+	s.peeked = &t
+}
+
+func (s *scanner) next() (token, error) {
+	if s.peeked != nil {
+		t := *s.peeked
+		s.peeked = nil
+		return t, nil
+	}
+
+	for {
+		if p, _ := s.r.Peek(2); string(p) == "//" {
+			s.r.Discard(2)
+			return s.comment()
+		}
+
+		c, _, err := s.r.ReadRune()
+		if err == io.EOF {
+			return token{typ: tokEOF, line: s.line}, nil
+		}
+		if err != nil {
+			return token{}, err
+		}
+
+		switch c {
+		case '\n':
+			s.line++
+		case ' ', '\t', '\r', '\ufeff':
+		case '{':
+			return token{typ: tokOpen, line: s.line}, nil
+		case '}':
+			return token{typ: tokClose, line: s.line}, nil
+		case '"':
+			return s.quoted()
+		case '[':
+			line := s.line
+			text, err := s.r.ReadString(']')
+			if err != nil || strings.ContainsRune(text, '\n') {
+				return token{}, s.errorf(line, "unterminated conditional")
+			}
+			text = text[:len(text)-1]
+			if _, err := Conditions(nil).Eval(text); err != nil {
+				return token{}, s.errorf(line, "%v", err)
+			}
+			return token{typ: tokCondition, text: text, line: line}, nil
+		default:
+			s.r.UnreadRune()
+			return s.unquoted()
+		}
+	}
+}
+
+func (s *scanner) comment() (token, error) {
+	line := s.line
+	text, err := s.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return token{}, err
+	}
+	if strings.HasSuffix(text, "\n") {
+		s.line++
+	}
+	text = strings.TrimRight(text, "\r\n")
+	return token{typ: tokComment, text: "//" + text, line: line}, nil
+}
+
+func (s *scanner) quoted() (token, error) {
+	line := s.line
+	var b strings.Builder
+	for {
+		c, _, err := s.r.ReadRune()
+		if err == io.EOF {
+			return token{}, s.errorf(line, "unterminated string")
+		}
+		if err != nil {
+			return token{}, err
+		}
+		switch c {
+		case '"':
+			return token{typ: tokString, text: b.String(), line: line}, nil
+		case '\n':
+			s.line++
+		case '\\':
+			n, _, err := s.r.ReadRune()
+			if err != nil {
+				return token{}, s.errorf(line, "unterminated string")
+			}
+			switch n {
+			case '\\', '"':
+				c = n
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			default:
+				// not an escape, keep paths like "materials\overviews"
+				b.WriteRune('\\')
+				s.r.UnreadRune()
+				continue
+			}
+		}
+		b.WriteRune(c)
+	}
+}
+
+func (s *scanner) unquoted() (token, error) {
+	line := s.line
+	var b []byte
+	for {
+		p, err := s.r.Peek(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return token{}, err
+		}
+		if strings.IndexByte(" \t\r\n{}\"", p[0]) >= 0 {
+			break
+		}
+		if p, _ := s.r.Peek(2); string(p) == "//" {
+			break
+		}
+		c, _ := s.r.ReadByte()
+		b = append(b, c)
+	}
+	return token{typ: tokString, text: string(b), line: line}, nil
+}
+
+// Parse reads a KeyValues document. The returned root is a section without a
+// key whose children are the top level keys of the file. Input is UTF-8, or
+// UTF-16 if it starts with a byte order mark.
+//
+// Parse keeps the document as written: #base and #include directives are
+// ordinary keys and conditionals are not evaluated. See Load.
+func Parse(r io.Reader) (*Node, error) {
+	return ParseNamed(r, "")
+}
+
+// ParseNamed is like Parse, with name used in error messages.
+func ParseNamed(r io.Reader, name string) (*Node, error) {
+	br, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	s := newScanner(br, name)
+	root := NewRoot()
+	if _, err := s.parseChildren(root, true); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// parseChildren reads the children of parent up to its closing brace, or
+// the end of input at the top level, and returns the line it ended on.
+func (s *scanner) parseChildren(parent *Node, top bool) (int, error) {
+	var comments []string
+	var last *Node
+	lastLine := -1
+
+	for {
+		t, err := s.next()
+		if err != nil {
+			return 0, err
+		}
+
+		switch t.typ {
+		case tokEOF:
+			if !top {
+				return 0, s.errorf(t.line, "unexpected end of file, missing }")
+			}
+			parent.EndComments = comments
+			return t.line, nil
+		case tokClose:
+			if top {
+				return 0, s.errorf(t.line, "unexpected }")
+			}
+			parent.EndComments = comments
+			return t.line, s.trailingComment(parent, t.line)
+		case tokComment:
+			if last != nil && t.line == lastLine && last.TrailingComment == "" {
+				last.TrailingComment = t.text
+			} else {
+				comments = append(comments, t.text)
+			}
+			continue
+		case tokString:
+		default:
+			return 0, s.errorf(t.line, "expected a key")
+		}
+
+		n := &Node{Key: t.text, Line: t.line, Comments: comments}
+		comments = nil
+		if lastLine, err = s.parseValue(n); err != nil {
+			return 0, err
+		}
+		parent.Children = append(parent.Children, n)
+		last = n
+	}
+}
+
+// parseValue reads what follows the key of n, and returns the line n ends
+// on.
+func (s *scanner) parseValue(n *Node) (int, error) {
+	for {
+		t, err := s.next()
+		if err != nil {
+			return 0, err
+		}
+
+		switch t.typ {
+		case tokComment:
+			n.Comments = append(n.Comments, t.text)
+		case tokCondition:
+			n.Condition = t.text
+		case tokString:
+			n.Value = t.text
+			return s.condition(n, t.line)
+		case tokOpen:
+			n.Section = true
+			return s.parseChildren(n, false)
+		default:
+			return 0, s.errorf(t.line, "expected a value or { after %q", n.Key)
+		}
+	}
+}
+
+// condition reads an optional conditional following a value on line.
+func (s *scanner) condition(n *Node, line int) (int, error) {
+	t, err := s.next()
+	if err != nil {
+		return 0, err
+	}
+	if t.typ == tokCondition && t.line == line {
+		n.Condition = t.text
+		return line, nil
+	}
+	s.unread(t)
+	return line, nil
+}
+
+// trailingComment attaches a conditional or comment on the line of a
+// closing brace to the section.
+func (s *scanner) trailingComment(n *Node, line int) error {
+	t, err := s.next()
+	if err != nil {
+		return err
+	}
+	if t.typ == tokCondition && t.line == line {
+		n.Condition = t.text
+		if t, err = s.next(); err != nil {
+			return err
+		}
+	}
+	if t.typ == tokComment && t.line == line {
+		n.TrailingComment = t.text
+		return nil
+	}
+	s.unread(t)
+	return nil
+}
@@ -0,0 +1,87 @@
+package keyvalues
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+// Write writes the children of root in the layout Steam uses: tab indented,
+// keys and values quoted and separated by two tabs.
+func Write(w io.Writer, root *Node) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range root.Children {
+		writeNode(bw, c, 0)
+	}
+	writeComments(bw, root.EndComments, 0)
+	return bw.Flush()
+}
+
+// Marshal returns the encoding of root, see Write.
+func Marshal(root *Node) ([]byte, error) {
+	var b bytes.Buffer
+	if err := Write(&b, root); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeNode(w *bufio.Writer, n *Node, depth int) {
+	writeComments(w, n.Comments, depth)
+
+	indent(w, depth)
+	writeQuoted(w, n.Key)
+	if !n.Section {
+		w.WriteString("\t\t")
+		writeQuoted(w, n.Value)
+		writeTrailer(w, n)
+		return
+	}
+	w.WriteByte('\n')
+
+	indent(w, depth)
+	w.WriteString("{\n")
+	for _, c := range n.Children {
+		writeNode(w, c, depth+1)
+	}
+	writeComments(w, n.EndComments, depth+1)
+	indent(w, depth)
+	w.WriteByte('}')
+	writeTrailer(w, n)
+}
+
+func writeTrailer(w *bufio.Writer, n *Node) {
+	if n.Condition != "" {
+		w.WriteString(" [")
+		w.WriteString(n.Condition)
+		w.WriteByte(']')
+	}
+	if n.TrailingComment != "" {
+		w.WriteByte(' ')
+		w.WriteString(n.TrailingComment)
+	}
+	w.WriteByte('\n')
+}
+
+func writeComments(w *bufio.Writer, comments []string, depth int) {
+	for _, c := range comments {
+		indent(w, depth)
+		w.WriteString(c)
+		w.WriteByte('\n')
+	}
+}
+
+func writeQuoted(w *bufio.Writer, s string) {
+	w.WriteByte('"')
+	escaper.WriteString(w, s)
+	w.WriteByte('"')
+}
+
+func indent(w *bufio.Writer, depth int) {
+	for i := 0; i < depth; i++ {
+		w.WriteByte('\t')
+	}
+}
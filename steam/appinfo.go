@@ -0,0 +1,340 @@
+package steam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// appinfo.vdf container versions
+const (
+	AppInfoVersion27 = 0x07564427
+	AppInfoVersion28 = 0x07564428
+	// AppInfoVersion29 stores keys in a string table at the end of the file.
+	AppInfoVersion29 = 0x07564429
+)
+
+// maxAppInfoEntry bounds the size of an app entry, so that a corrupt size
+// does not allocate the whole memory.
+const maxAppInfoEntry = 64 << 20
+
+// AppInfo is the cached product info of an app, as stored in
+// appcache/appinfo.vdf.
+type AppInfo struct {
+	AppID        int
+	InfoState    uint32
+	LastUpdated  time.Time
+	PICSToken    uint64
+	SHA1         [20]byte
+	ChangeNumber uint32
+	// BinarySHA1 is the hash of the binary data. It is only present in
+	// version 28 and later.
+	BinarySHA1 [20]byte
+
+	// Data is the appinfo section, with common, config, depots and so on as
+	// child sections. See ReadBinaryVDF for how values are formatted.
+	Data *keyvalues.Node
+}
+
+// LaunchConfig is one of the launch options offered for an app.
+type LaunchConfig struct {
+	Executable  string
+	Arguments   string
+	Description string
+	Type        string
+	OSList      string
+	OSArch      string
+	BetaKey     string
+}
+
+// DepotInfo describes one depot of an app.
+type DepotInfo struct {
+	ID      int
+	Name    string
+	OSList  string
+	MaxSize int64
+	// DepotFromApp is the app that owns a shared depot, or 0.
+	DepotFromApp int
+	// Manifests maps branch names to manifest ids.
+	Manifests map[string]string
+}
+
+// Name returns the app's name.
+func (a *AppInfo) Name() string {
+	v, _ := a.Data.Get("common", "name")
+	return v
+}
+
+// LaunchConfigs returns the app's launch options in the order Steam lists
+// them.
+func (a *AppInfo) LaunchConfigs() []LaunchConfig {
+	var configs []LaunchConfig
+	for _, n := range numericChildren(a.Data.Find("config", "launch")) {
+		if !n.Section {
+			continue
+		}
+		c := LaunchConfig{}
+		c.Executable, _ = n.Get("executable")
+		c.Arguments, _ = n.Get("arguments")
+		c.Description, _ = n.Get("description")
+		c.Type, _ = n.Get("type")
+		c.OSList, _ = n.Get("config", "oslist")
+		c.OSArch, _ = n.Get("config", "osarch")
+		c.BetaKey, _ = n.Get("config", "betakey")
+		configs = append(configs, c)
+	}
+	return configs
+}
+
+// Depots returns the app's depots ordered by id.
+func (a *AppInfo) Depots() []DepotInfo {
+	var infos []DepotInfo
+	for _, n := range numericChildren(a.Data.Child("depots")) {
+		if !n.Section {
+			continue
+		}
+		d := DepotInfo{Manifests: map[string]string{}}
+		d.ID, _ = strconv.Atoi(n.Key)
+		d.Name, _ = n.Get("name")
+		d.OSList, _ = n.Get("config", "oslist")
+		maxSize, _ := n.Get("maxsize")
+		d.MaxSize, _ = strconv.ParseInt(maxSize, 10, 64)
+		fromApp, _ := n.Get("depotfromapp")
+		d.DepotFromApp, _ = strconv.Atoi(fromApp)
+
+		if manifests := n.Child("manifests"); manifests != nil {
+			for _, m := range manifests.Children {
+				if m.Section {
+					// current format: "public" { "gid" "..." "size" "..." }
+					d.Manifests[m.Key], _ = m.Get("gid")
+				} else {
+					d.Manifests[m.Key] = m.Value
+				}
+			}
+		}
+		infos = append(infos, d)
+	}
+	return infos
+}
+
+// AppInfoReader reads the entries of an appinfo.vdf file one at a time.
+type AppInfoReader struct {
+	r       io.ReadSeeker
+	version uint32
+	strings []string
+	done    bool
+}
+
+// NewAppInfoReader reads the header of an appinfo.vdf file, and for version
+// 29 its string table.
+func NewAppInfoReader(r io.ReadSeeker) (*AppInfoReader, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+		return nil, fmt.Errorf("appinfo: reading header: %v", err)
+	}
+
+	ar := &AppInfoReader{
+		r:       r,
+		version: binary.LittleEndian.Uint32(hdr[:4]),
+	}
+	switch ar.version {
+	case AppInfoVersion27, AppInfoVersion28:
+	case AppInfoVersion29:
+		if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+			return nil, fmt.Errorf("appinfo: reading header: %v", err)
+		}
+		offset := int64(binary.LittleEndian.Uint64(hdr[8:16]))
+		if err := ar.readStringTable(offset); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("appinfo: unsupported version 0x%08x", ar.version)
+	}
+
+	return ar, nil
+}
+
+// Version returns the container version.
+func (ar *AppInfoReader) Version() uint32 {
+	return ar.version
+}
+
+func (ar *AppInfoReader) readStringTable(offset int64) error {
+	start, err := ar.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := ar.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	d := newBinaryDecoder(bufio.NewReader(ar.r), nil)
+	count, err := d.readUint32()
+	if err != nil {
+		return fmt.Errorf("appinfo: reading string table: %v", unexpectedEOF(err))
+	}
+	for i := uint32(0); i < count; i++ {
+		s, err := d.readString()
+		if err != nil {
+			return fmt.Errorf("appinfo: reading string table: %v", err)
+		}
+		ar.strings = append(ar.strings, s)
+	}
+
+	_, err = ar.r.Seek(start, io.SeekStart)
+	return err
+}
+
+// nextEntry reads the id and size of the next entry. It returns io.EOF
+// after the last entry.
+func (ar *AppInfoReader) nextEntry() (int, uint32, error) {
+	if ar.done {
+		return 0, 0, io.EOF
+	}
+	var hdr [8]byte
+	if _, err := io.ReadFull(ar.r, hdr[:4]); err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	appID := binary.LittleEndian.Uint32(hdr[:4])
+	if appID == 0 {
+		ar.done = true
+		return 0, 0, io.EOF
+	}
+	if _, err := io.ReadFull(ar.r, hdr[4:]); err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	return int(appID), binary.LittleEndian.Uint32(hdr[4:]), nil
+}
+
+// Next decodes the next app. It returns io.EOF after the last app.
+func (ar *AppInfoReader) Next() (*AppInfo, error) {
+	appID, size, err := ar.nextEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	if size > maxAppInfoEntry {
+		return nil, fmt.Errorf("appinfo: app %d: invalid entry size %d", appID, size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(ar.r, buf); err != nil {
+		return nil, fmt.Errorf("appinfo: app %d: %v", appID, unexpectedEOF(err))
+	}
+
+	a := &AppInfo{AppID: appID}
+	fixed := 40
+	if ar.version >= AppInfoVersion28 {
+		fixed += 20
+	}
+	if len(buf) < fixed {
+		return nil, fmt.Errorf("appinfo: app %d: entry too short", appID)
+	}
+	a.InfoState = binary.LittleEndian.Uint32(buf[0:])
+	a.LastUpdated = time.Unix(int64(binary.LittleEndian.Uint32(buf[4:])), 0)
+	a.PICSToken = binary.LittleEndian.Uint64(buf[8:])
+	copy(a.SHA1[:], buf[16:36])
+	a.ChangeNumber = binary.LittleEndian.Uint32(buf[36:])
+	if ar.version >= AppInfoVersion28 {
+		copy(a.BinarySHA1[:], buf[40:60])
+	}
+
+	d := newBinaryDecoder(bytes.NewReader(buf[fixed:]), ar.strings)
+	data := keyvalues.NewRoot()
+	if err := d.decodeSection(data, true); err != nil {
+		return nil, fmt.Errorf("appinfo: app %d: %v", appID, err)
+	}
+	if c := data.Child("appinfo"); c != nil && c.Section {
+		data = c
+	}
+	a.Data = data
+
+	return a, nil
+}
+
+// Find skips ahead to appID and decodes it. Only entries after the current
+// position are searched. The returned error satisfies os.IsNotExist if the
+// app is not found.
+func (ar *AppInfoReader) Find(appID int) (*AppInfo, error) {
+	for {
+		id, size, err := ar.nextEntry()
+		if err == io.EOF {
+			return nil, &os.PathError{Op: "find", Path: fmt.Sprintf("appinfo/%d", appID), Err: os.ErrNotExist}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if id == appID {
+			// rewind over the entry header so Next can read it
+			if _, err := ar.r.Seek(-8, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			return ar.Next()
+		}
+		if _, err := ar.r.Seek(int64(size), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GetAppInfo looks up appID in the appinfo cache of the Steam installation
+// at steamPath.
+func GetAppInfo(steamPath string, appID int) (*AppInfo, error) {
+	return GetAppInfoFS(vfs.OS(), vfs.Abs(steamPath), appID)
+}
+
+// GetAppInfoFS is like GetAppInfo, reading from fsys.
+func GetAppInfoFS(fsys fs.FS, steamPath string, appID int) (*AppInfo, error) {
+	f, err := fsys.Open(vfs.Name(path.Join(steamPath, "appcache", "appinfo.vdf")))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		// the reader skips over the apps before appID
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		rs = bytes.NewReader(b)
+	}
+
+	ar, err := NewAppInfoReader(rs)
+	if err != nil {
+		return nil, err
+	}
+	return ar.Find(appID)
+}
+
+// numericChildren returns the children of n whose keys are numbers, in
+// numeric order. n may be nil.
+func numericChildren(n *keyvalues.Node) []*keyvalues.Node {
+	if n == nil {
+		return nil
+	}
+	var children []*keyvalues.Node
+	ids := map[*keyvalues.Node]int{}
+	for _, c := range n.Children {
+		if id, err := strconv.Atoi(c.Key); err == nil {
+			children = append(children, c)
+			ids[c] = id
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return ids[children[i]] < ids[children[j]]
+	})
+	return children
+}
@@ -0,0 +1,193 @@
+package steam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+// csgoAppInfo writes the appinfo section of app 730 using b.
+func csgoAppInfo(b *bvdfBuilder) {
+	b.begin("appinfo").
+		int32("appid", 730).
+		begin("common").str("name", "Counter-Strike: Global Offensive").str("type", "Game").end().
+		begin("config").
+		str("installdir", "Counter-Strike Global Offensive").
+		begin("launch").
+		begin("1").str("executable", "csgo_linux64").str("arguments", "-game csgo").
+		begin("config").str("oslist", "linux").end().end().
+		begin("0").str("executable", "csgo.exe").str("arguments", "-game csgo").str("type", "default").
+		begin("config").str("oslist", "windows").end().end().
+		end().
+		end().
+		begin("depots").
+		begin("731").str("name", "Counter-Strike Global Offensive Content").str("maxsize", "15000000000").
+		begin("manifests").begin("public").str("gid", "7043469183016184477").str("size", "1").end().end().end().
+		begin("228990").str("depotfromapp", "228980").
+		begin("manifests").str("public", "1829726630299308803").end().end().
+		str("branches", "").
+		end().
+		end().end()
+}
+
+func makeAppInfo(version uint32) []byte {
+	var out bytes.Buffer
+	w := func(v interface{}) { binary.Write(&out, binary.LittleEndian, v) }
+
+	var b bvdfBuilder
+	if version == AppInfoVersion29 {
+		b.table = []string{}
+	}
+
+	entry := func(appID uint32, data func(*bvdfBuilder)) {
+		b.Reset()
+		data(&b)
+		var fixed bytes.Buffer
+		binary.Write(&fixed, binary.LittleEndian, uint32(2))          // info state
+		binary.Write(&fixed, binary.LittleEndian, uint32(1462300000)) // last updated
+		binary.Write(&fixed, binary.LittleEndian, uint64(0))          // pics token
+		fixed.Write(bytes.Repeat([]byte{0xaa}, 20))
+		binary.Write(&fixed, binary.LittleEndian, uint32(1234)) // change number
+		if version >= AppInfoVersion28 {
+			fixed.Write(bytes.Repeat([]byte{0xbb}, 20))
+		}
+		w(appID)
+		w(uint32(fixed.Len() + b.Len()))
+		out.Write(fixed.Bytes())
+		out.Write(b.Bytes())
+	}
+
+	w(version)
+	w(uint32(1))
+	if version == AppInfoVersion29 {
+		w(uint64(0)) // string table offset, patched below
+	}
+	entry(10, func(b *bvdfBuilder) {
+		b.begin("appinfo").begin("common").str("name", "Counter-Strike").end().end().end()
+	})
+	entry(730, func(b *bvdfBuilder) { csgoAppInfo(b) })
+	w(uint32(0))
+
+	if version == AppInfoVersion29 {
+		offset := out.Len()
+		w(uint32(len(b.table)))
+		for _, s := range b.table {
+			out.WriteString(s)
+			out.WriteByte(0)
+		}
+		data := out.Bytes()
+		binary.LittleEndian.PutUint64(data[8:], uint64(offset))
+	}
+	return out.Bytes()
+}
+
+func TestAppInfoReader(t *testing.T) {
+	for _, version := range []uint32{AppInfoVersion27, AppInfoVersion28, AppInfoVersion29} {
+		ar, err := NewAppInfoReader(bytes.NewReader(makeAppInfo(version)))
+		if err != nil {
+			t.Fatalf("version %x: %v", version, err)
+		}
+
+		a, err := ar.Next()
+		if err != nil {
+			t.Fatalf("version %x: %v", version, err)
+		}
+		if a.AppID != 10 || a.Name() != "Counter-Strike" || a.ChangeNumber != 1234 {
+			t.Errorf("version %x: unexpected first app %+v", version, a)
+		}
+
+		a, err = ar.Next()
+		if err != nil {
+			t.Fatalf("version %x: %v", version, err)
+		}
+		if a.Name() != "Counter-Strike: Global Offensive" {
+			t.Errorf("version %x: unexpected name %q", version, a.Name())
+		}
+		if version >= AppInfoVersion28 && a.BinarySHA1[0] != 0xbb {
+			t.Errorf("version %x: binary sha1 not read", version)
+		}
+
+		if _, err := ar.Next(); err == nil {
+			t.Errorf("version %x: expected EOF after last app", version)
+		}
+	}
+}
+
+func TestAppInfoFind(t *testing.T) {
+	ar, err := NewAppInfoReader(bytes.NewReader(makeAppInfo(AppInfoVersion29)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ar.Find(730)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	launch := []LaunchConfig{
+		{Executable: "csgo.exe", Arguments: "-game csgo", Type: "default", OSList: "windows"},
+		{Executable: "csgo_linux64", Arguments: "-game csgo", OSList: "linux"},
+	}
+	if lc := a.LaunchConfigs(); !reflect.DeepEqual(lc, launch) {
+		t.Errorf("expected launch configs %+v, got %+v", launch, lc)
+	}
+
+	depots := []DepotInfo{
+		{ID: 731, Name: "Counter-Strike Global Offensive Content", MaxSize: 15000000000,
+			Manifests: map[string]string{"public": "7043469183016184477"}},
+		{ID: 228990, DepotFromApp: 228980,
+			Manifests: map[string]string{"public": "1829726630299308803"}},
+	}
+	if d := a.Depots(); !reflect.DeepEqual(d, depots) {
+		t.Errorf("expected depots %+v, got %+v", depots, d)
+	}
+
+	ar, err = NewAppInfoReader(bytes.NewReader(makeAppInfo(AppInfoVersion28)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.Find(440); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestGetAppInfoFS(t *testing.T) {
+	fsys := vfstest.Tree("F/steam/appcache/appinfo.vdf\n" + string(makeAppInfo(AppInfoVersion29)))
+	a, err := GetAppInfoFS(fsys, "/steam", 730)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.AppID != 730 || a.Name() != "Counter-Strike: Global Offensive" {
+		t.Errorf("unexpected app %+v", a)
+	}
+	if dir, _ := a.Data.Get("config", "installdir"); dir != "Counter-Strike Global Offensive" {
+		t.Errorf("expected the install dir, got %q", dir)
+	}
+
+	if _, err := GetAppInfoFS(fsys, "/missing", 730); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestAppInfoBadVersion(t *testing.T) {
+	if _, err := NewAppInfoReader(bytes.NewReader([]byte{0x26, 0x44, 0x56, 0x07, 1, 0, 0, 0})); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+func TestAppInfoBadSize(t *testing.T) {
+	data := makeAppInfo(AppInfoVersion28)
+	// the size of the first entry follows the header and its app id
+	binary.LittleEndian.PutUint32(data[12:], 0xfffffff0)
+	ar, err := NewAppInfoReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ar.Next(); err == nil || !strings.Contains(err.Error(), "invalid entry size") {
+		t.Errorf("expected an invalid entry size error, got %v", err)
+	}
+}
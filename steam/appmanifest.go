@@ -0,0 +1,300 @@
+package steam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+var (
+	manifestMatcher = regexp.MustCompile(`^appmanifest_(\d+)\.acf$`)
+)
+
+// Depot is a depot installed as part of an app.
+type Depot struct {
+	// Manifest is the id of the installed depot manifest.
+	Manifest string
+	// Size is the installed size of the depot in bytes.
+	Size int64
+}
+
+// AppManifest is the contents of a steamapps/appmanifest_<appid>.acf file,
+// which Steam keeps for every app installed in a library.
+type AppManifest struct {
+	AppID       int
+	Name        string
+	InstallDir  string
+	StateFlags  AppStateFlags
+	BuildID     int
+	LastUpdated time.Time
+	SizeOnDisk  int64
+
+	// TargetBuildID is the build Steam is updating the app to, or zero when
+	// no update is pending.
+	TargetBuildID int
+	// BytesToDownload and BytesDownloaded track the progress of a pending
+	// update.
+	BytesToDownload int64
+	BytesDownloaded int64
+
+	// InstalledDepots maps depot ids to the installed depot.
+	InstalledDepots map[int]Depot
+	// UserConfig holds per-app user settings such as the language.
+	UserConfig map[string]string
+
+	// LibraryPath is the library the manifest was read from. It is empty for
+	// manifests read with ReadAppManifest.
+	LibraryPath string
+}
+
+// InstallPath returns the directory the app is installed to. It is only
+// meaningful for manifests read from a library.
+func (m *AppManifest) InstallPath() string {
+	return path.Join(m.LibraryPath, "steamapps", "common", filepath.ToSlash(m.InstallDir))
+}
+
+// ReadAppManifest parses an app manifest.
+func ReadAppManifest(r io.Reader) (*AppManifest, error) {
+	acf, err := keyvalues.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseAppManifest(acf)
+}
+
+func parseAppManifest(acf *keyvalues.Node) (*AppManifest, error) {
+	state := acf.Child("AppState")
+	if state == nil || !state.Section {
+		return nil, fmt.Errorf("app manifest missing AppState")
+	}
+
+	m := &AppManifest{
+		InstalledDepots: map[int]Depot{},
+		UserConfig:      map[string]string{},
+	}
+	m.Name, _ = state.Get("name")
+	m.InstallDir, _ = state.Get("installdir")
+
+	var stateFlags int
+	var lastUpdated int64
+	ints := []struct {
+		key string
+		dst interface{}
+	}{
+		{"appid", &m.AppID},
+		{"StateFlags", &stateFlags},
+		{"buildid", &m.BuildID},
+		{"LastUpdated", &lastUpdated},
+		{"SizeOnDisk", &m.SizeOnDisk},
+		{"TargetBuildID", &m.TargetBuildID},
+		{"BytesToDownload", &m.BytesToDownload},
+		{"BytesDownloaded", &m.BytesDownloaded},
+	}
+	for _, f := range ints {
+		if err := parseIntKey(state, f.key, f.dst); err != nil {
+			return nil, fmt.Errorf("app manifest: %v", err)
+		}
+	}
+	m.StateFlags = AppStateFlags(stateFlags)
+	if lastUpdated != 0 {
+		m.LastUpdated = time.Unix(lastUpdated, 0)
+	}
+
+	if depots := state.Child("InstalledDepots"); depots != nil {
+		for _, dn := range depots.Children {
+			id, err := strconv.Atoi(dn.Key)
+			if err != nil {
+				return nil, fmt.Errorf("app manifest: invalid depot id %q", dn.Key)
+			}
+			var d Depot
+			d.Manifest, _ = dn.Get("manifest")
+			if err := parseIntKey(dn, "size", &d.Size); err != nil {
+				return nil, fmt.Errorf("app manifest: depot %d: %v", id, err)
+			}
+			m.InstalledDepots[id] = d
+		}
+	}
+
+	if userConfig := state.Child("UserConfig"); userConfig != nil {
+		for _, c := range userConfig.Children {
+			if !c.Section {
+				m.UserConfig[c.Key] = c.Value
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// GetAppManifests reads every app manifest in the library at libraryPath,
+// ordered by app id.
+func GetAppManifests(libraryPath string) ([]*AppManifest, error) {
+	return GetAppManifestsFS(vfs.OS(), vfs.Abs(libraryPath))
+}
+
+// GetAppManifestsFS is like GetAppManifests, reading from fsys.
+func GetAppManifestsFS(fsys fs.FS, libraryPath string) ([]*AppManifest, error) {
+	libraryPath = path.Clean(filepath.ToSlash(libraryPath))
+	dir := path.Join(libraryPath, "steamapps")
+	entries, err := fs.ReadDir(fsys, vfs.Name(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*AppManifest
+	for _, e := range entries {
+		if e.IsDir() || !manifestMatcher.MatchString(e.Name()) {
+			continue
+		}
+		m, err := readAppManifestFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m.LibraryPath = libraryPath
+		manifests = append(manifests, m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].AppID < manifests[j].AppID
+	})
+
+	return manifests, nil
+}
+
+// GetAppManifest reads the manifest of appID from the library at libraryPath.
+// The returned error satisfies os.IsNotExist if the app is not installed
+// there.
+func GetAppManifest(libraryPath string, appID int) (*AppManifest, error) {
+	return GetAppManifestFS(vfs.OS(), vfs.Abs(libraryPath), appID)
+}
+
+// GetAppManifestFS is like GetAppManifest, reading from fsys.
+func GetAppManifestFS(fsys fs.FS, libraryPath string, appID int) (*AppManifest, error) {
+	libraryPath = path.Clean(filepath.ToSlash(libraryPath))
+	name := path.Join(libraryPath, "steamapps", fmt.Sprintf("appmanifest_%d.acf", appID))
+	m, err := readAppManifestFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	m.LibraryPath = libraryPath
+	return m, nil
+}
+
+// GetAppInstallPaths returns the install directory of appID in each of the
+// given libraries that has a manifest for it and whose install directory
+// exists. Libraries that cannot be read, such as one on an unplugged drive,
+// do not stop the scan: they are reported in a ScanErrors returned together
+// with the install directories found in the others.
+func GetAppInstallPaths(libraryPaths []string, appID int) ([]string, error) {
+	abs := make([]string, len(libraryPaths))
+	for i, l := range libraryPaths {
+		abs[i] = vfs.Abs(l)
+	}
+	return GetAppInstallPathsFS(vfs.OS(), abs, appID)
+}
+
+// GetAppInstallPathsFS is like GetAppInstallPaths, reading from fsys.
+func GetAppInstallPathsFS(fsys fs.FS, libraryPaths []string, appID int) ([]string, error) {
+	installs, err := GetAppInstallsFS(fsys, libraryPaths, appID)
+	var installPaths []string
+	for _, m := range installs {
+		installPaths = append(installPaths, m.InstallPath())
+	}
+	return installPaths, err
+}
+
+// GetAppInstalls is like GetAppInstallPaths, but returns the manifests of the
+// installs, so their InstallState can be checked.
+func GetAppInstalls(libraryPaths []string, appID int) ([]*AppManifest, error) {
+	abs := make([]string, len(libraryPaths))
+	for i, l := range libraryPaths {
+		abs[i] = vfs.Abs(l)
+	}
+	return GetAppInstallsFS(vfs.OS(), abs, appID)
+}
+
+// GetAppInstallsFS is like GetAppInstalls, reading from fsys.
+func GetAppInstallsFS(fsys fs.FS, libraryPaths []string, appID int) ([]*AppManifest, error) {
+	var installs []*AppManifest
+	var errs ScanErrors
+	for _, l := range libraryPaths {
+		m, err := findAppInstall(fsys, l, appID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if m != nil {
+			installs = append(installs, m)
+		}
+	}
+	return installs, errs.Err()
+}
+
+// GetAppInstallPathsContext is a concurrent GetAppInstallPaths. Install
+// directories are passed to found as they are found, so they come in no
+// particular order. See ScanPaths.
+func GetAppInstallPathsContext(ctx context.Context, libraryPaths []string, appID int, opts ScanOptions, found func(string)) error {
+	scan := func(ctx context.Context, fsys fs.FS, l string, found func(string)) error {
+		m, err := findAppInstall(fsys, l, appID)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			found(m.InstallPath())
+		}
+		return nil
+	}
+	return ScanPaths(ctx, libraryPaths, opts, scan, found)
+}
+
+// findAppInstall returns the manifest of appID in the library at
+// libraryPath, or nil if it is not installed there.
+func findAppInstall(fsys fs.FS, libraryPath string, appID int) (*AppManifest, *fs.PathError) {
+	l := path.Clean(filepath.ToSlash(libraryPath))
+	steamapps := path.Join(l, "steamapps")
+	if _, err := fs.Stat(fsys, vfs.Name(steamapps)); err != nil {
+		return nil, scanError("stat", steamapps, err)
+	}
+
+	m, err := GetAppManifestFS(fsys, l, appID)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		name := path.Join(steamapps, fmt.Sprintf("appmanifest_%d.acf", appID))
+		op := "parse"
+		if _, ok := err.(*fs.PathError); ok {
+			op = "open"
+		}
+		return nil, scanError(op, name, err)
+	}
+	if !isDir(fsys, m.InstallPath()) {
+		// stale manifest, the app was moved or deleted by hand
+		return nil, nil
+	}
+	return m, nil
+}
+
+func readAppManifestFile(fsys fs.FS, name string) (*AppManifest, error) {
+	acf, err := readKeyValuesFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := parseAppManifest(acf)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", name, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,151 @@
+package steam
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const csgoManifest = `"AppState"
+{
+	"appid"		"730"
+	"Universe"		"1"
+	"name"		"Counter-Strike: Global Offensive"
+	"StateFlags"		"4"
+	"installdir"		"Counter-Strike Global Offensive"
+	"LastUpdated"		"1462300000"
+	"UpdateResult"		"0"
+	"SizeOnDisk"		"15000000000"
+	"buildid"		"1104349"
+	"LastOwner"		"76561197960287930"
+	"InstalledDepots"
+	{
+		"731"
+		{
+			"manifest"		"7043469183016184477"
+			"size"		"14000000000"
+		}
+		"734"
+		{
+			"manifest"		"1432915006429431046"
+			"size"		"1000000000"
+		}
+	}
+	"UserConfig"
+	{
+		"language"		"english"
+	}
+}
+`
+
+const oldManifest = `"AppState"
+{
+	"appID"		"228980"
+	"name"		"Steamworks Common Redistributables"
+	"StateFlags"		"4"
+	"installdir"		"Steamworks Shared"
+}
+`
+
+func TestReadAppManifest(t *testing.T) {
+	m, err := ReadAppManifest(strings.NewReader(csgoManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &AppManifest{
+		AppID:       730,
+		Name:        "Counter-Strike: Global Offensive",
+		InstallDir:  "Counter-Strike Global Offensive",
+		StateFlags:  4,
+		BuildID:     1104349,
+		LastUpdated: time.Unix(1462300000, 0),
+		SizeOnDisk:  15000000000,
+		InstalledDepots: map[int]Depot{
+			731: {Manifest: "7043469183016184477", Size: 14000000000},
+			734: {Manifest: "1432915006429431046", Size: 1000000000},
+		},
+		UserConfig: map[string]string{"language": "english"},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %+v, got %+v", expected, m)
+	}
+
+	if _, err := ReadAppManifest(strings.NewReader(`"AppState" { "appid" "x" }`)); err == nil {
+		t.Error("expected an error for a non-numeric appid")
+	}
+}
+
+func TestGetAppManifests(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/appmanifest_730.acf":                                   csgoManifest,
+		"steamapps/appmanifest_228980.acf":                                oldManifest,
+		"steamapps/appmanifest_730.acf.bak":                               csgoManifest,
+		"steamapps/common/Counter-Strike Global Offensive/csgo/pak01.vpk": "",
+	})
+	defer os.RemoveAll(root)
+
+	ms, err := GetAppManifests(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].AppID != 730 || ms[1].AppID != 228980 {
+		t.Fatalf("expected manifests for 730 and 228980, got %+v", ms)
+	}
+
+	paths, err := GetAppInstallPaths([]string{path.Join(root, "missing"), root}, 730)
+	expected := []string{path.Join(root, "steamapps/common/Counter-Strike Global Offensive")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+	errs, ok := err.(ScanErrors)
+	if !ok || len(errs) != 1 || errs[0].Op != "stat" || errs[0].Path != path.Join(root, "missing", "steamapps") || !os.IsNotExist(errs[0].Err) {
+		t.Errorf("expected the missing library to be reported, got %v", err)
+	}
+
+	// the manifest exists but the install directory does not
+	paths, err = GetAppInstallPaths([]string{root}, 228980)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("expected no install paths for a stale manifest, got %v", paths)
+	}
+}
+
+func TestGetAppInstallPathsZip(t *testing.T) {
+	// a zipped snapshot of a library, as attached to bug reports
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range map[string]string{
+		"SteamLibrary/steamapps/appmanifest_730.acf":                                   csgoManifest,
+		"SteamLibrary/steamapps/common/Counter-Strike Global Offensive/csgo/pak01.vpk": "",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := GetAppInstallPathsFS(zr, []string{"/SteamLibrary"}, 730)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/SteamLibrary/steamapps/common/Counter-Strike Global Offensive"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,156 @@
+package steam
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AppStateFlags are the StateFlags of an app manifest, a bit set describing
+// what Steam is doing with the app.
+type AppStateFlags int
+
+// App state flags, as defined by Steam's EAppState.
+const (
+	AppStateUninstalled AppStateFlags = 1 << iota
+	AppStateUpdateRequired
+	AppStateFullyInstalled
+	AppStateEncrypted
+	AppStateLocked
+	AppStateFilesMissing
+	AppStateAppRunning
+	AppStateFilesCorrupt
+	AppStateUpdateRunning
+	AppStateUpdatePaused
+	AppStateUpdateStarted
+	AppStateUninstalling
+	AppStateBackupRunning
+	_
+	_
+	_
+	AppStateReconfiguring
+	AppStateValidating
+	AppStateAddingFiles
+	AppStatePreallocating
+	AppStateDownloading
+	AppStateStaging
+	AppStateCommitting
+	AppStateUpdateStopping
+)
+
+var appStateFlagNames = []string{
+	"Uninstalled",
+	"UpdateRequired",
+	"FullyInstalled",
+	"Encrypted",
+	"Locked",
+	"FilesMissing",
+	"AppRunning",
+	"FilesCorrupt",
+	"UpdateRunning",
+	"UpdatePaused",
+	"UpdateStarted",
+	"Uninstalling",
+	"BackupRunning",
+	"",
+	"",
+	"",
+	"Reconfiguring",
+	"Validating",
+	"AddingFiles",
+	"Preallocating",
+	"Downloading",
+	"Staging",
+	"Committing",
+	"UpdateStopping",
+}
+
+// appStateUpdating are the flags Steam sets while an update is in progress.
+const appStateUpdating = AppStateUpdateRunning | AppStateUpdateStarted | AppStateReconfiguring |
+	AppStateAddingFiles | AppStatePreallocating | AppStateDownloading | AppStateStaging |
+	AppStateCommitting | AppStateUpdateStopping
+
+// Has reports whether all of flag are set in f.
+func (f AppStateFlags) Has(flag AppStateFlags) bool {
+	return f&flag == flag
+}
+
+// String returns the names of the flags set in f separated by "|", such as
+// "UpdateRequired|FullyInstalled".
+func (f AppStateFlags) String() string {
+	if f == 0 {
+		return "Invalid"
+	}
+	var names []string
+	for i, name := range appStateFlagNames {
+		bit := AppStateFlags(1) << uint(i)
+		if f&bit == 0 {
+			continue
+		}
+		if name == "" {
+			name = fmt.Sprintf("0x%x", int(bit))
+		}
+		names = append(names, name)
+		f &^= bit
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("0x%x", int(f)))
+	}
+	return strings.Join(names, "|")
+}
+
+// InstallState is what an app manifest says about whether the app can be
+// used.
+type InstallState int
+
+// Install states, from the least to the most usable.
+const (
+	// Uninstalled apps have no usable files.
+	Uninstalled InstallState = iota
+	// Validating apps are having their files checked by Steam.
+	Validating
+	// Updating apps are being downloaded or patched.
+	Updating
+	// UpdateRequired apps are installed, but at an outdated build that Steam
+	// has yet to update.
+	UpdateRequired
+	// Installed apps are fully installed and up to date.
+	Installed
+)
+
+var installStateNames = []string{
+	Uninstalled:    "uninstalled",
+	Validating:     "validating",
+	Updating:       "updating",
+	UpdateRequired: "update required",
+	Installed:      "installed",
+}
+
+func (s InstallState) String() string {
+	if s >= 0 && int(s) < len(installStateNames) {
+		return installStateNames[s]
+	}
+	return fmt.Sprintf("InstallState(%d)", int(s))
+}
+
+// InstallState returns the state of the app from its StateFlags, and from
+// its target build and download progress, which Steam updates before the
+// flags.
+func (m *AppManifest) InstallState() InstallState {
+	f := m.StateFlags
+	switch {
+	case f.Has(AppStateValidating):
+		return Validating
+	case f&appStateUpdating != 0 && !f.Has(AppStateUpdatePaused):
+		// a paused update keeps UpdateStarted set
+		return Updating
+	case f.Has(AppStateUninstalled) || f.Has(AppStateUninstalling) || !f.Has(AppStateFullyInstalled):
+		return Uninstalled
+	case f&(AppStateUpdateRequired|AppStateUpdatePaused|AppStateFilesMissing|AppStateFilesCorrupt) != 0:
+		return UpdateRequired
+	case m.TargetBuildID != 0 && m.TargetBuildID != m.BuildID:
+		return UpdateRequired
+	case m.BytesToDownload > m.BytesDownloaded:
+		return UpdateRequired
+	}
+	return Installed
+}
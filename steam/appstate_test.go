@@ -0,0 +1,54 @@
+package steam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppStateFlagsString(t *testing.T) {
+	tests := map[AppStateFlags]string{
+		0:                      "Invalid",
+		AppStateFullyInstalled: "FullyInstalled",
+		AppStateUpdateRequired | AppStateFullyInstalled:     "UpdateRequired|FullyInstalled",
+		AppStateFullyInstalled | AppStateValidating | 1<<13: "FullyInstalled|0x2000|Validating",
+		1 << 30: "0x40000000",
+	}
+	for f, expected := range tests {
+		if s := f.String(); s != expected {
+			t.Errorf("%d: expected %q, got %q", int(f), expected, s)
+		}
+	}
+}
+
+func TestInstallState(t *testing.T) {
+	tests := []struct {
+		manifest string
+		state    InstallState
+	}{
+		{`"StateFlags" "4" "buildid" "100"`, Installed},
+		{`"StateFlags" "4" "buildid" "100" "TargetBuildID" "100" "BytesToDownload" "10" "BytesDownloaded" "10"`, Installed},
+		{`"StateFlags" "6" "buildid" "100"`, UpdateRequired},
+		{`"StateFlags" "4" "buildid" "100" "TargetBuildID" "101"`, UpdateRequired},
+		{`"StateFlags" "4" "buildid" "100" "BytesToDownload" "10" "BytesDownloaded" "5"`, UpdateRequired},
+		{`"StateFlags" "516"`, UpdateRequired},
+		{`"StateFlags" "1542"`, UpdateRequired},
+		{`"StateFlags" "1538"`, Uninstalled},
+		{`"StateFlags" "1026"`, Updating},
+		{`"StateFlags" "1030"`, Updating},
+		{`"StateFlags" "1048838"`, Updating},
+		{`"StateFlags" "131076"`, Validating},
+		{`"StateFlags" "1"`, Uninstalled},
+		{`"StateFlags" "2"`, Uninstalled},
+		{`"StateFlags" "2052"`, Uninstalled},
+		{``, Uninstalled},
+	}
+	for _, tt := range tests {
+		m, err := ReadAppManifest(strings.NewReader(`"AppState" { "appid" "730" ` + tt.manifest + ` }`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := m.InstallState(); s != tt.state {
+			t.Errorf("%v: expected %v, got %v", tt.manifest, tt.state, s)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package steam
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+)
+
+// binary KeyValues value types
+const (
+	bvdfNone    = 0x00
+	bvdfString  = 0x01
+	bvdfInt32   = 0x02
+	bvdfFloat   = 0x03
+	bvdfPtr     = 0x04
+	bvdfWString = 0x05
+	bvdfColor   = 0x06
+	bvdfUint64  = 0x07
+	bvdfEnd     = 0x08
+	bvdfInt64   = 0x0a
+	bvdfEndAlt  = 0x0b
+)
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// binaryDecoder decodes binary KeyValues into the same ordered tree the
+// text parser produces. Typed values are formatted the way the engine's
+// KeyValues::GetString does: integers in decimal, colors as "r g b a" and
+// wide strings as UTF-8.
+type binaryDecoder struct {
+	r byteReader
+
+	// strings is the key table of appinfo.vdf v29. When set, keys are
+	// encoded as indexes into it instead of inline strings.
+	strings []string
+
+	tmp [8]byte
+}
+
+// ReadBinaryVDF decodes binary KeyValues, as used by shortcuts.vdf and the
+// app sections of appinfo.vdf, into a root node.
+func ReadBinaryVDF(r io.Reader) (*keyvalues.Node, error) {
+	d := newBinaryDecoder(r, nil)
+	root := keyvalues.NewRoot()
+	if err := d.decodeSection(root, true); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func newBinaryDecoder(r io.Reader, strings []string) *binaryDecoder {
+	d := &binaryDecoder{strings: strings}
+	if rr, ok := r.(byteReader); ok {
+		d.r = rr
+	} else {
+		d.r = bufio.NewReader(r)
+	}
+	return d
+}
+
+// decodeSection decodes key/value pairs up to the closing end marker into
+// the children of n. At the top level the end of input also ends the
+// section.
+func (d *binaryDecoder) decodeSection(n *keyvalues.Node, top bool) error {
+	for {
+		t, err := d.r.ReadByte()
+		if err == io.EOF && top {
+			return nil
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if t == bvdfEnd || t == bvdfEndAlt {
+			return nil
+		}
+
+		key, err := d.readKey()
+		if err != nil {
+			return err
+		}
+
+		c := &keyvalues.Node{Key: key}
+		switch t {
+		case bvdfNone:
+			c.Section = true
+			err = d.decodeSection(c, false)
+		case bvdfString:
+			c.Value, err = d.readString()
+		case bvdfInt32:
+			var v uint32
+			v, err = d.readUint32()
+			c.Value = strconv.FormatInt(int64(int32(v)), 10)
+		case bvdfFloat:
+			var v uint32
+			v, err = d.readUint32()
+			c.Value = strconv.FormatFloat(float64(math.Float32frombits(v)), 'g', -1, 32)
+		case bvdfPtr:
+			var v uint32
+			v, err = d.readUint32()
+			c.Value = strconv.FormatUint(uint64(v), 10)
+		case bvdfWString:
+			c.Value, err = d.readWString()
+		case bvdfColor:
+			_, err = io.ReadFull(d.r, d.tmp[:4])
+			c.Value = fmt.Sprintf("%d %d %d %d", d.tmp[0], d.tmp[1], d.tmp[2], d.tmp[3])
+		case bvdfUint64:
+			var v uint64
+			v, err = d.readUint64()
+			c.Value = strconv.FormatUint(v, 10)
+		case bvdfInt64:
+			var v uint64
+			v, err = d.readUint64()
+			c.Value = strconv.FormatInt(int64(v), 10)
+		default:
+			return fmt.Errorf("binary vdf: unknown type 0x%02x for key %q", t, key)
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		n.Children = append(n.Children, c)
+	}
+}
+
+func (d *binaryDecoder) readKey() (string, error) {
+	if d.strings == nil {
+		return d.readString()
+	}
+	idx, err := d.readUint32()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if int(idx) >= len(d.strings) {
+		return "", fmt.Errorf("binary vdf: key index %d out of range", idx)
+	}
+	return d.strings[idx], nil
+}
+
+func (d *binaryDecoder) readString() (string, error) {
+	var b []byte
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+}
+
+func (d *binaryDecoder) readWString() (string, error) {
+	var u []uint16
+	for {
+		if _, err := io.ReadFull(d.r, d.tmp[:2]); err != nil {
+			return "", unexpectedEOF(err)
+		}
+		c := binary.LittleEndian.Uint16(d.tmp[:2])
+		if c == 0 {
+			return string(utf16.Decode(u)), nil
+		}
+		u = append(u, c)
+	}
+}
+
+func (d *binaryDecoder) readUint32() (uint32, error) {
+	if _, err := io.ReadFull(d.r, d.tmp[:4]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(d.tmp[:4]), nil
+}
+
+func (d *binaryDecoder) readUint64() (uint64, error) {
+	if _, err := io.ReadFull(d.r, d.tmp[:8]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(d.tmp[:8]), nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package steam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+)
+
+// bvdfBuilder writes binary KeyValues for tests. If table is set, keys are
+// written as indexes into it, as in appinfo.vdf v29.
+type bvdfBuilder struct {
+	bytes.Buffer
+	table []string
+}
+
+func (b *bvdfBuilder) key(t byte, k string) *bvdfBuilder {
+	b.WriteByte(t)
+	if b.table == nil {
+		b.WriteString(k)
+		b.WriteByte(0)
+		return b
+	}
+	for i, s := range b.table {
+		if s == k {
+			binary.Write(b, binary.LittleEndian, uint32(i))
+			return b
+		}
+	}
+	b.table = append(b.table, k)
+	binary.Write(b, binary.LittleEndian, uint32(len(b.table)-1))
+	return b
+}
+
+func (b *bvdfBuilder) begin(k string) *bvdfBuilder {
+	return b.key(bvdfNone, k)
+}
+
+func (b *bvdfBuilder) end() *bvdfBuilder {
+	b.WriteByte(bvdfEnd)
+	return b
+}
+
+func (b *bvdfBuilder) str(k, v string) *bvdfBuilder {
+	b.key(bvdfString, k)
+	b.WriteString(v)
+	b.WriteByte(0)
+	return b
+}
+
+func (b *bvdfBuilder) int32(k string, v int32) *bvdfBuilder {
+	b.key(bvdfInt32, k)
+	binary.Write(b, binary.LittleEndian, v)
+	return b
+}
+
+func (b *bvdfBuilder) raw(t byte, k string, v interface{}) *bvdfBuilder {
+	b.key(t, k)
+	binary.Write(b, binary.LittleEndian, v)
+	return b
+}
+
+func TestReadBinaryVDF(t *testing.T) {
+	var b bvdfBuilder
+	b.begin("root").
+		str("name", "Counter-Strike").
+		int32("count", -7).
+		raw(bvdfFloat, "scale", math.Float32bits(1.5)).
+		raw(bvdfPtr, "ptr", uint32(0xdeadbeef)).
+		raw(bvdfColor, "color", [4]uint8{1, 2, 3, 4}).
+		raw(bvdfUint64, "big", uint64(76561197960287930)).
+		raw(bvdfInt64, "neg", int64(-76561197960287930))
+	b.key(bvdfWString, "wide")
+	binary.Write(&b, binary.LittleEndian, append(utf16.Encode([]rune("grüße ☃")), 0))
+	b.begin("nested").str("k", "v").str("k", "w").end()
+	b.end().end()
+
+	root, err := ReadBinaryVDF(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &keyvalues.Node{Section: true, Children: []*keyvalues.Node{
+		{Key: "root", Section: true, Children: []*keyvalues.Node{
+			{Key: "name", Value: "Counter-Strike"},
+			{Key: "count", Value: "-7"},
+			{Key: "scale", Value: "1.5"},
+			{Key: "ptr", Value: "3735928559"},
+			{Key: "color", Value: "1 2 3 4"},
+			{Key: "big", Value: "76561197960287930"},
+			{Key: "neg", Value: "-76561197960287930"},
+			{Key: "wide", Value: "grüße ☃"},
+			// duplicate keys are kept in order
+			{Key: "nested", Section: true, Children: []*keyvalues.Node{
+				{Key: "k", Value: "v"},
+				{Key: "k", Value: "w"},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(root, expected) {
+		t.Errorf("expected %v, got %v", keyvaluesString(expected), keyvaluesString(root))
+	}
+}
+
+func keyvaluesString(n *keyvalues.Node) string {
+	var b bytes.Buffer
+	keyvalues.Write(&b, n)
+	return b.String()
+}
+
+func TestReadBinaryVDFErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated string": {bvdfString, 'k', 0, 'v'},
+		"truncated int":    {bvdfInt32, 'k', 0, 1, 2},
+		"unclosed map":     {bvdfNone, 'k', 0, bvdfString, 'a', 0, 'b', 0},
+		"unknown type":     {0x42, 'k', 0},
+	}
+	for name, data := range tests {
+		_, err := ReadBinaryVDF(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if name != "unknown type" && err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: expected unexpected EOF, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows && !linux && !darwin
+// +build !windows,!linux,!darwin
+
+package steam
+
+import "errors"
+
+func diskSpace(p string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package steam
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// diskSpace returns the free and total bytes of the file system holding p.
+func diskSpace(p string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(filepath.FromSlash(p), &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,17 @@
+package steam
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// diskSpace returns the free and total bytes of the volume holding p.
+func diskSpace(p string) (free, total uint64, err error) {
+	dir, err := windows.UTF16PtrFromString(filepath.FromSlash(p))
+	if err != nil {
+		return 0, 0, err
+	}
+	err = windows.GetDiskFreeSpaceEx(dir, &free, &total, nil)
+	return free, total, err
+}
@@ -0,0 +1,162 @@
+package steam
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// Library is a single Steam library folder, i.e. a directory containing a
+// steamapps folder.
+type Library struct {
+	// Path is the library root, cleaned and slash separated.
+	Path string
+	// Label is the user-visible name of the library, if any.
+	Label string
+	// ContentID identifies the library to the Steam client.
+	ContentID string
+	// TotalSize is the capacity reported for the library in bytes, or 0 if
+	// Steam did not record it.
+	TotalSize int64
+	// Apps maps installed app ids to their size on disk in bytes. It is nil
+	// for libraries only known from the old flat libraryfolders.vdf format
+	// or from config.vdf, which do not record installed apps.
+	Apps map[int]int64
+}
+
+// HasApp reports whether the library is known to hold appID.
+func (l *Library) HasApp(appID int) bool {
+	_, ok := l.Apps[appID]
+	return ok
+}
+
+// LibrariesWithApp returns the libraries known to hold appID.
+func LibrariesWithApp(libs []Library, appID int) []Library {
+	var found []Library
+	for _, l := range libs {
+		if l.HasApp(appID) {
+			found = append(found, l)
+		}
+	}
+	return found
+}
+
+// GetLibraries returns every library of the Steam installation at steamPath.
+// The installation itself is always the first library. Further libraries are
+// read from steamapps/libraryfolders.vdf, in either the old flat or the
+// current nested format, and from the BaseInstallFolder_N keys of
+// config/config.vdf written by older clients.
+func GetLibraries(steamPath string) ([]Library, error) {
+	return GetLibrariesFS(vfs.OS(), vfs.Abs(steamPath))
+}
+
+// GetLibrariesFS is like GetLibraries, reading from fsys.
+func GetLibrariesFS(fsys fs.FS, steamPath string) ([]Library, error) {
+	root := path.Clean(filepath.ToSlash(steamPath))
+	libs := []Library{{Path: root}}
+
+	folders, ferr := readLibraryFolders(fsys, path.Join(root, "steamapps", "libraryfolders.vdf"))
+	if ferr != nil && !os.IsNotExist(ferr) {
+		return nil, ferr
+	}
+	configPaths, cerr := readConfigLibraryPaths(fsys, path.Join(root, "config", "config.vdf"))
+	if cerr != nil && !os.IsNotExist(cerr) {
+		return nil, cerr
+	}
+	if ferr != nil && cerr != nil {
+		return nil, fmt.Errorf("no library configuration found in %v", steamPath)
+	}
+
+	for _, f := range folders {
+		if f.Path == root {
+			// the nested format lists the install itself, keep its details
+			f.Path = libs[0].Path
+			libs[0] = f
+			continue
+		}
+		libs = appendLibrary(libs, f)
+	}
+	for _, p := range configPaths {
+		libs = appendLibrary(libs, Library{Path: p})
+	}
+
+	return libs, nil
+}
+
+func appendLibrary(libs []Library, l Library) []Library {
+	for _, e := range libs {
+		if e.Path == l.Path {
+			return libs
+		}
+	}
+	return append(libs, l)
+}
+
+func readLibraryFolders(fsys fs.FS, name string) ([]Library, error) {
+	cfg, err := readKeyValuesFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	root := cfg.Child("libraryfolders")
+	if root == nil || !root.Section {
+		return nil, fmt.Errorf("libraryfolders.vdf missing libraryfolders")
+	}
+
+	var libs []Library
+	for _, n := range root.Children {
+		if _, err := strconv.Atoi(n.Key); err != nil {
+			continue
+		}
+		if !n.Section {
+			// old format: "1" "D:\\SteamLibrary"
+			libs = append(libs, Library{Path: path.Clean(filepath.ToSlash(n.Value))})
+			continue
+		}
+		l, err := parseLibraryFolder(n)
+		if err != nil {
+			return nil, fmt.Errorf("libraryfolders.vdf entry %s: %v", n.Key, err)
+		}
+		libs = append(libs, l)
+	}
+
+	return libs, nil
+}
+
+func parseLibraryFolder(n *keyvalues.Node) (Library, error) {
+	var l Library
+	p, ok := n.Get("path")
+	if !ok {
+		return l, fmt.Errorf("missing path")
+	}
+	l.Path = path.Clean(filepath.ToSlash(p))
+	l.Label, _ = n.Get("label")
+	l.ContentID, _ = n.Get("contentid")
+
+	if err := parseIntKey(n, "totalsize", &l.TotalSize); err != nil {
+		return l, err
+	}
+
+	l.Apps = map[int]int64{}
+	if apps := n.Child("apps"); apps != nil {
+		for _, a := range apps.Children {
+			id, err := strconv.Atoi(a.Key)
+			if err != nil {
+				return l, fmt.Errorf("invalid app id %q", a.Key)
+			}
+			size, err := strconv.ParseInt(a.Value, 10, 64)
+			if err != nil {
+				return l, fmt.Errorf("invalid size %q for app %d", a.Value, id)
+			}
+			l.Apps[id] = size
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,157 @@
+package steam
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const newLibraryFolders = `"libraryfolders"
+{
+	"contentstatsid"		"-1234567890"
+	"0"
+	{
+		"path"		"%ROOT%"
+		"label"		""
+		"contentid"		"111"
+		"totalsize"		"0"
+		"apps"
+		{
+			"228980"		"1024"
+		}
+	}
+	"1"
+	{
+		"path"		"/mnt/games/SteamLibrary"
+		"label"		"games"
+		"contentid"		"222"
+		"totalsize"		"2000000000000"
+		"apps"
+		{
+			"730"		"35000000000"
+		}
+	}
+}
+`
+
+const oldLibraryFolders = `"LibraryFolders"
+{
+	"TimeNextStatsReport"		"1461000000"
+	"ContentStatsID"		"-1234567890"
+	"1"		"/mnt/games/SteamLibrary"
+	"2"		"/mnt/other/SteamLibrary"
+}
+`
+
+const configVdf = `"InstallConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"BaseInstallFolder_1"		"/mnt/other/SteamLibrary"
+				"BaseInstallFolder_2"		"/mnt/legacy/SteamLibrary"
+			}
+		}
+	}
+}
+`
+
+func makeSteamRoot(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "steamroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.ToSlash(root)
+	for name, contents := range files {
+		name = filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		contents = strings.Replace(contents, "%ROOT%", root, -1)
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGetLibrariesNewFormat(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/libraryfolders.vdf": newLibraryFolders,
+	})
+	defer os.RemoveAll(root)
+
+	libs, err := GetLibraries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Library{
+		{Path: root, ContentID: "111", Apps: map[int]int64{228980: 1024}},
+		{Path: "/mnt/games/SteamLibrary", Label: "games", ContentID: "222", TotalSize: 2000000000000,
+			Apps: map[int]int64{730: 35000000000}},
+	}
+	if !reflect.DeepEqual(libs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, libs)
+	}
+
+	csgoLibs := LibrariesWithApp(libs, 730)
+	if len(csgoLibs) != 1 || csgoLibs[0].Path != "/mnt/games/SteamLibrary" {
+		t.Errorf("expected app 730 in /mnt/games/SteamLibrary, got %+v", csgoLibs)
+	}
+}
+
+func TestGetLibrariesOldFormat(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/libraryfolders.vdf": oldLibraryFolders,
+		"config/config.vdf":            configVdf,
+	})
+	defer os.RemoveAll(root)
+
+	paths, err := GetLibraryPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		root,
+		"/mnt/games/SteamLibrary",
+		"/mnt/other/SteamLibrary",
+		"/mnt/legacy/SteamLibrary",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestGetLibrariesConfigWithoutFolders(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/libraryfolders.vdf": newLibraryFolders,
+		"config/config.vdf":            "\"InstallConfigStore\"\n{\n\t\"Music\"\n\t{\n\t}\n}\n",
+	})
+	defer os.RemoveAll(root)
+
+	paths, err := GetLibraryPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{root, "/mnt/games/SteamLibrary"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestGetLibrariesMissingConfig(t *testing.T) {
+	root := makeSteamRoot(t, nil)
+	defer os.RemoveAll(root)
+
+	if _, err := GetLibraries(root); err == nil {
+		t.Error("expected an error for a steam root without library configuration")
+	}
+}
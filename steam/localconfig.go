@@ -0,0 +1,230 @@
+package steam
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+var localConfigApps = []string{"UserLocalConfigStore", "Software", "Valve", "Steam", "apps"}
+
+// localConfigPath returns the localconfig.vdf of the user whose userdata
+// directory is userDataPath.
+func localConfigPath(userDataPath string) string {
+	return path.Join(userDataPath, "config", "localconfig.vdf")
+}
+
+func readLocalConfig(userDataPath string) (*keyvalues.Node, error) {
+	return readKeyValuesFile(vfs.OS(), localConfigPath(vfs.Abs(userDataPath)))
+}
+
+// GetLaunchOptions returns the launch options the user whose userdata
+// directory is userDataPath set for appID.
+func GetLaunchOptions(userDataPath string, appID int) (string, error) {
+	return GetLaunchOptionsFS(vfs.OS(), vfs.Abs(userDataPath), appID)
+}
+
+// GetLaunchOptionsFS is like GetLaunchOptions, reading from fsys.
+func GetLaunchOptionsFS(fsys fs.FS, userDataPath string, appID int) (string, error) {
+	cfg, err := readKeyValuesFile(fsys, localConfigPath(userDataPath))
+	if err != nil {
+		return "", err
+	}
+	apps := cfg.Find(localConfigApps...)
+	if apps == nil {
+		return "", nil
+	}
+	v, _ := apps.Get(strconv.Itoa(appID), "LaunchOptions")
+	return v, nil
+}
+
+// SetLaunchOptions replaces the launch options of appID for the user whose
+// userdata directory is userDataPath. The rest of localconfig.vdf is kept as
+// is and the previous file is kept as localconfig.vdf.bak.
+//
+// Steam rewrites localconfig.vdf while it runs and on exit, so changes only
+// stick if Steam is not running.
+func SetLaunchOptions(userDataPath string, appID int, options string) error {
+	return editLaunchOptions(userDataPath, appID, func(string) string {
+		return options
+	})
+}
+
+// AddLaunchOptions adds options such as "-insecure" or "-tickrate 128" to
+// the launch options of appID, see SetLaunchOptions. An option whose flag is
+// already present replaces it together with its arguments.
+func AddLaunchOptions(userDataPath string, appID int, options ...string) error {
+	return editLaunchOptions(userDataPath, appID, func(current string) string {
+		for _, o := range options {
+			current = mergeLaunchOption(current, o)
+		}
+		return current
+	})
+}
+
+func editLaunchOptions(userDataPath string, appID int, edit func(string) string) error {
+	cfg, err := readLocalConfig(userDataPath)
+	if err != nil {
+		return err
+	}
+
+	app := cfg.Ensure(localConfigApps...).Ensure(strconv.Itoa(appID))
+	current, _ := app.Get("LaunchOptions")
+	app.Set("LaunchOptions", edit(current))
+
+	return writeFileSafe(localConfigPath(userDataPath), func(w io.Writer) error {
+		return keyvalues.Write(w, cfg)
+	})
+}
+
+// mergeLaunchOption adds option to the launch options in current. If the
+// option's flag is present, the flag and the arguments following it are
+// replaced in place.
+func mergeLaunchOption(current, option string) string {
+	add := splitLaunchArgs(option)
+	if len(add) == 0 {
+		return current
+	}
+	args := splitLaunchArgs(current)
+
+	for i, a := range args {
+		if a != add[0] {
+			continue
+		}
+		end := i + 1
+		for end < len(args) && !args[end].isFlag() {
+			end++
+		}
+		merged := append([]launchArg{}, args[:i]...)
+		merged = append(merged, add...)
+		merged = append(merged, args[end:]...)
+		return joinLaunchArgs(merged)
+	}
+
+	return joinLaunchArgs(append(args, add...))
+}
+
+// IsLaunchFlag reports whether the launch option argument s is a flag, such
+// as -novid or +demo_dir, rather than the value of one.
+func IsLaunchFlag(s string) bool {
+	return strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+")
+}
+
+// SplitLaunchOptions splits launch options into arguments the way the game
+// receives them: at whitespace, except within double quotes, which are
+// removed.
+func SplitLaunchOptions(o string) []string {
+	var args []string
+	for _, a := range splitLaunchArgs(o) {
+		args = append(args, a.s)
+	}
+	return args
+}
+
+// launchArg is an argument of launch options. Quoted arguments are values
+// even if they start with - or +.
+type launchArg struct {
+	s      string
+	quoted bool
+}
+
+func (a launchArg) isFlag() bool {
+	return !a.quoted && IsLaunchFlag(a.s)
+}
+
+func splitLaunchArgs(o string) []launchArg {
+	var args []launchArg
+	var tok []byte
+	inTok, quoted, wasQuoted := false, false, false
+	for i := 0; i < len(o); i++ {
+		ch := o[i]
+		switch {
+		case ch == '"':
+			quoted, inTok, wasQuoted = !quoted, true, true
+		case !quoted && (ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'):
+			if inTok {
+				args = append(args, launchArg{string(tok), wasQuoted})
+			}
+			tok, inTok, wasQuoted = tok[:0], false, false
+		default:
+			tok, inTok = append(tok, ch), true
+		}
+	}
+	if inTok {
+		args = append(args, launchArg{string(tok), wasQuoted})
+	}
+	return args
+}
+
+// joinLaunchArgs joins args into launch options, quoting the arguments that
+// were quoted or need to be.
+func joinLaunchArgs(args []launchArg) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if a.quoted || a.s == "" || strings.ContainsAny(a.s, " \t\r\n") {
+			parts[i] = `"` + a.s + `"`
+		} else {
+			parts[i] = a.s
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeFileSafe replaces name with what write produces. The new contents go
+// to a temporary file in the same directory which is renamed over name once
+// complete, so a failed write never leaves a truncated file. The previous
+// contents are kept in name.bak.
+func writeFileSafe(name string, write func(io.Writer) error) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	dir, base := filepath.Split(name)
+	tmp, err := ioutil.TempFile(dir, base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %v: %v", name, err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := copyFile(name, name+".bak"); err != nil {
+		return fmt.Errorf("backing up %v: %v", name, err)
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func copyFile(src, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, b, info.Mode().Perm())
+}
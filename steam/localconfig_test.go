@@ -0,0 +1,138 @@
+package steam
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const localConfigVdf = `"UserLocalConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"apps"
+				{
+					"730"
+					{
+						"LastPlayed"		"1462300000"
+						// set by hand
+						"LaunchOptions"		"-novid -tickrate 64 +exec autoexec"
+					}
+				}
+			}
+		}
+	}
+	"friends"
+	{
+		"PersonaName"		"player"
+	}
+}
+`
+
+func TestMergeLaunchOption(t *testing.T) {
+	tests := []struct {
+		current, option, expected string
+	}{
+		{"", "-insecure", "-insecure"},
+		{"-novid", "-insecure", "-novid -insecure"},
+		{"-novid -insecure", "-insecure", "-novid -insecure"},
+		{"-novid -tickrate 64 +exec autoexec", "-tickrate 128", "-novid -tickrate 128 +exec autoexec"},
+		{"-tickrate 64", "-tickrate", "-tickrate"},
+		{"-novid", "  ", "-novid"},
+		{`-novid +name "a  b" -high`, `+name "c  d"`, `-novid +name "c  d" -high`},
+		{`+name "-x  y" -high`, "-high", `+name "-x  y" -high`},
+		{`-tickrate 64 +name "-1"`, "-tickrate 128", `-tickrate 128 +name "-1"`},
+		{`+name "-1" -tickrate 64`, `+name "two words"`, `+name "two words" -tickrate 64`},
+	}
+	for _, tt := range tests {
+		if s := mergeLaunchOption(tt.current, tt.option); s != tt.expected {
+			t.Errorf("adding %q to %q: expected %q, got %q", tt.option, tt.current, tt.expected, s)
+		}
+	}
+}
+
+func TestSplitLaunchOptions(t *testing.T) {
+	tests := map[string][]string{
+		"":                                 nil,
+		"  -novid\t+exec  autoexec ":       {"-novid", "+exec", "autoexec"},
+		`+demo_dir "D:\My Demos" -high`:    {"+demo_dir", `D:\My Demos`, "-high"},
+		`+demo_dir //nas/demos; +quit`:     {"+demo_dir", "//nas/demos;", "+quit"},
+		`+name "" +demo_dir C:"/a b"/c`:    {"+name", "", "+demo_dir", "C:/a b/c"},
+		`+demo_dir "unterminated // quote`: {"+demo_dir", "unterminated // quote"},
+	}
+	for o, expected := range tests {
+		if args := SplitLaunchOptions(o); !reflect.DeepEqual(args, expected) {
+			t.Errorf("%q: expected %q, got %q", o, expected, args)
+		}
+	}
+}
+
+func TestLaunchOptions(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"userdata/22202/config/localconfig.vdf": localConfigVdf,
+	})
+	defer os.RemoveAll(root)
+	userData := path.Join(root, "userdata", "22202")
+
+	opts, err := GetLaunchOptions(userData, 730)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts != "-novid -tickrate 64 +exec autoexec" {
+		t.Errorf("unexpected launch options %q", opts)
+	}
+
+	if err := AddLaunchOptions(userData, 730, "-insecure", "-tickrate 128"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLaunchOptions(userData, 440, "-novid"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(userData, "config", "localconfig.vdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(localConfigVdf,
+		`"-novid -tickrate 64 +exec autoexec"`, `"-novid -tickrate 128 +exec autoexec -insecure"`, 1)
+	expected = strings.Replace(expected, `					}
+				}
+			}`, `					}
+					"440"
+					{
+						"LaunchOptions"		"-novid"
+					}
+				}
+			}`, 1)
+	if string(b) != expected {
+		t.Errorf("unexpected localconfig.vdf:\n%s", b)
+	}
+
+	// the backup holds the state before the last write
+	b, err = ioutil.ReadFile(path.Join(userData, "config", "localconfig.vdf.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"-novid -tickrate 128 +exec autoexec -insecure"`) || strings.Contains(string(b), `"440"`) {
+		t.Errorf("unexpected backup:\n%s", b)
+	}
+
+	files, err := ioutil.ReadDir(path.Join(userData, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected only localconfig.vdf and its backup, got %d files", len(files))
+	}
+
+	if _, err := GetLaunchOptions(path.Join(root, "userdata", "1"), 730); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
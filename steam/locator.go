@@ -0,0 +1,206 @@
+package steam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A Locator finds Steam installations. Locate returns the root of every
+// installation it found, cleaned, slash separated and with symlinks
+// resolved, and only directories that contain a steamapps folder.
+type Locator interface {
+	Locate() ([]string, error)
+}
+
+// LocatorFunc adapts a function to the Locator interface.
+type LocatorFunc func() ([]string, error)
+
+func (f LocatorFunc) Locate() ([]string, error) {
+	return f()
+}
+
+// Chain is a Locator that runs several locators in order and returns every
+// distinct installation they find. Errors of individual locators are only
+// reported if no installation is found at all.
+type Chain []Locator
+
+func (c Chain) Locate() ([]string, error) {
+	var found []string
+	var errs []string
+	seen := map[string]bool{}
+
+	for _, l := range c {
+		paths, err := l.Locate()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		for _, p := range paths {
+			if !seen[p] {
+				seen[p] = true
+				found = append(found, p)
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("no steam install found: %s", strings.Join(errs, "; "))
+		}
+		return nil, errors.New("no steam install found")
+	}
+	return found, nil
+}
+
+// PathLocator returns a Locator for explicitly configured installations. It
+// fails if a path is not a Steam installation.
+func PathLocator(paths ...string) Locator {
+	return LocatorFunc(func() ([]string, error) {
+		var found []string
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			r, ok := checkInstallPath(p)
+			if !ok {
+				return found, fmt.Errorf("%v is not a steam install", p)
+			}
+			found = append(found, r)
+		}
+		return found, nil
+	})
+}
+
+// EnvLocator returns a Locator for the installation named by the environment
+// variable name, usually STEAM_PATH. It finds nothing if the variable is
+// unset.
+func EnvLocator(name string) Locator {
+	return LocatorFunc(func() ([]string, error) {
+		p := os.Getenv(name)
+		if p == "" {
+			return nil, nil
+		}
+		paths, err := PathLocator(p).Locate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return paths, nil
+	})
+}
+
+// DirsLocator returns a Locator that checks each of dirs and returns those
+// that are Steam installations.
+func DirsLocator(dirs ...string) Locator {
+	return LocatorFunc(func() ([]string, error) {
+		var found []string
+		for _, d := range dirs {
+			if p, ok := checkInstallPath(d); ok {
+				found = append(found, p)
+			}
+		}
+		return found, nil
+	})
+}
+
+// RegistryRoot is a predefined Windows registry key.
+type RegistryRoot int
+
+const (
+	RegistryCurrentUser RegistryRoot = iota
+	RegistryLocalMachine
+)
+
+// Registry reads string values from the Windows registry.
+type Registry interface {
+	StringValue(root RegistryRoot, key, name string) (string, error)
+}
+
+// registryEntries are the registry values Steam records its install path in.
+var registryEntries = []struct {
+	root      RegistryRoot
+	key, name string
+}{
+	{RegistryCurrentUser, `Software\Valve\Steam`, "SteamPath"},
+	{RegistryLocalMachine, `SOFTWARE\WOW6432Node\Valve\Steam`, "InstallPath"},
+	{RegistryLocalMachine, `SOFTWARE\Valve\Steam`, "InstallPath"},
+}
+
+// RegistryLocator returns a Locator for the installations recorded in the
+// registry r.
+func RegistryLocator(r Registry) Locator {
+	return LocatorFunc(func() ([]string, error) {
+		var found []string
+		var err error
+		for _, e := range registryEntries {
+			p, verr := r.StringValue(e.root, e.key, e.name)
+			if verr != nil {
+				err = verr
+				continue
+			}
+			if p, ok := checkInstallPath(p); ok {
+				found = append(found, p)
+			}
+		}
+		if len(found) > 0 {
+			return found, nil
+		}
+		return nil, err
+	})
+}
+
+// ProcessLocator returns a Locator for the installations of running Steam
+// clients. It finds nothing on platforms where processes cannot be
+// inspected.
+func ProcessLocator() Locator {
+	return LocatorFunc(func() ([]string, error) {
+		roots, err := findSteamProcesses()
+		if err != nil {
+			return nil, err
+		}
+		return DirsLocator(roots...).Locate()
+	})
+}
+
+// DefaultLocator returns the locator used by GetInstallPaths. It tries, in
+// order, the STEAM_PATH environment variable, the configured paths, the
+// registry on Windows, the platform's well known install directories and
+// running Steam processes.
+func DefaultLocator(configured ...string) Chain {
+	c := Chain{EnvLocator("STEAM_PATH"), PathLocator(configured...)}
+	if r := systemRegistry(); r != nil {
+		c = append(c, RegistryLocator(r))
+	}
+	return append(c, DirsLocator(wellKnownDirs()...), ProcessLocator())
+}
+
+// GetInstallPaths returns every Steam installation found by DefaultLocator.
+func GetInstallPaths() ([]string, error) {
+	return DefaultLocator().Locate()
+}
+
+// GetInstallPath returns the preferred Steam installation, the first one
+// found by DefaultLocator.
+func GetInstallPath() (string, error) {
+	paths, err := GetInstallPaths()
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+// checkInstallPath resolves p and reports whether it looks like a Steam root,
+// that is, whether it contains a steamapps directory.
+func checkInstallPath(p string) (string, bool) {
+	p, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(filepath.Join(p, "steamapps"))
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return path.Clean(filepath.ToSlash(p)), true
+}
@@ -0,0 +1,71 @@
+package steam
+
+import (
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeRegistry map[string]string
+
+func (r fakeRegistry) StringValue(root RegistryRoot, key, name string) (string, error) {
+	prefix := "HKCU"
+	if root == RegistryLocalMachine {
+		prefix = "HKLM"
+	}
+	v, ok := r[prefix+`\`+key+`\`+name]
+	if !ok {
+		return "", errors.New("the system cannot find the file specified")
+	}
+	return v, nil
+}
+
+func TestLocatorChain(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"user/steamapps/libraryfolders.vdf":    "",
+		"machine/steamapps/libraryfolders.vdf": "",
+		"env/steamapps/libraryfolders.vdf":     "",
+		"notsteam/readme.txt":                  "",
+	})
+	defer os.RemoveAll(root)
+
+	reg := fakeRegistry{
+		`HKCU\Software\Valve\Steam\SteamPath`:               path.Join(root, "user"),
+		`HKLM\SOFTWARE\WOW6432Node\Valve\Steam\InstallPath`: path.Join(root, "machine"),
+	}
+	t.Setenv("STEAM_PATH", path.Join(root, "env"))
+
+	c := Chain{
+		EnvLocator("STEAM_PATH"),
+		PathLocator(path.Join(root, "user")),
+		RegistryLocator(reg),
+		DirsLocator(path.Join(root, "notsteam"), path.Join(root, "machine"), path.Join(root, "missing")),
+	}
+	paths, err := c.Locate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{path.Join(root, "env"), path.Join(root, "user"), path.Join(root, "machine")}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	// a bad override is reported, but does not hide other installs
+	t.Setenv("STEAM_PATH", path.Join(root, "notsteam"))
+	paths, err = c.Locate()
+	if err != nil || len(paths) != 2 {
+		t.Errorf("expected 2 installs, got %v %v", paths, err)
+	}
+
+	_, err = Chain{EnvLocator("STEAM_PATH"), RegistryLocator(fakeRegistry{})}.Locate()
+	if err == nil || !strings.Contains(err.Error(), "STEAM_PATH") || !strings.Contains(err.Error(), "cannot find") {
+		t.Errorf("expected errors of both locators, got %v", err)
+	}
+
+	if _, err := (Chain{}).Locate(); err == nil {
+		t.Error("expected an error from an empty chain")
+	}
+}
@@ -0,0 +1,101 @@
+package steam
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// LoginUser is a Steam account that has logged in on this machine, as
+// recorded in config/loginusers.vdf.
+type LoginUser struct {
+	SteamID     SteamID
+	AccountName string
+	PersonaName string
+	// MostRecent is set for the account that logged in last.
+	MostRecent bool
+	// Timestamp is the time of the account's last login.
+	Timestamp time.Time
+
+	RememberPassword       bool
+	AllowAutoLogin         bool
+	WantsOfflineMode       bool
+	SkipOfflineModeWarning bool
+
+	// UserDataPath is the account's userdata directory, or empty if the
+	// account has none.
+	UserDataPath string
+}
+
+// GetLoginUsers returns the accounts known to the Steam installation at
+// steamPath, the most recently used first.
+func GetLoginUsers(steamPath string) ([]LoginUser, error) {
+	return GetLoginUsersFS(vfs.OS(), vfs.Abs(steamPath))
+}
+
+// GetLoginUsersFS is like GetLoginUsers, reading from fsys.
+func GetLoginUsersFS(fsys fs.FS, steamPath string) ([]LoginUser, error) {
+	cfg, err := readKeyValuesFile(fsys, path.Join(steamPath, "config", "loginusers.vdf"))
+	if err != nil {
+		return nil, err
+	}
+
+	users := cfg.Child("users")
+	if users == nil || !users.Section {
+		return nil, fmt.Errorf("loginusers.vdf missing users")
+	}
+
+	var logins []LoginUser
+	for _, m := range users.Children {
+		if !m.Section {
+			continue
+		}
+		id, err := ParseSteamID(m.Key)
+		if err != nil {
+			return nil, fmt.Errorf("loginusers.vdf: invalid steam id %q", m.Key)
+		}
+
+		u := LoginUser{SteamID: id}
+		u.AccountName, _ = m.Get("AccountName")
+		u.PersonaName, _ = m.Get("PersonaName")
+		u.MostRecent = getBool(m, "MostRecent")
+		u.RememberPassword = getBool(m, "RememberPassword")
+		u.AllowAutoLogin = getBool(m, "AllowAutoLogin")
+		u.WantsOfflineMode = getBool(m, "WantsOfflineMode")
+		u.SkipOfflineModeWarning = getBool(m, "SkipOfflineModeWarning")
+
+		var ts int64
+		if err := parseIntKey(m, "Timestamp", &ts); err != nil {
+			return nil, fmt.Errorf("loginusers.vdf: user %v: %v", id, err)
+		}
+		if ts != 0 {
+			u.Timestamp = time.Unix(ts, 0)
+		}
+
+		userData := path.Join(filepath.ToSlash(steamPath), "userdata", strconv.FormatUint(uint64(u.SteamID.AccountID()), 10))
+		if isDir(fsys, userData) {
+			u.UserDataPath = path.Clean(userData)
+		}
+
+		logins = append(logins, u)
+	}
+
+	sort.Slice(logins, func(i, j int) bool {
+		a, b := logins[i], logins[j]
+		if a.MostRecent != b.MostRecent {
+			return a.MostRecent
+		}
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.SteamID < b.SteamID
+	})
+
+	return logins, nil
+}
@@ -0,0 +1,68 @@
+package steam
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+const loginUsersVdf = `"users"
+{
+	"76561197960287930"
+	{
+		"AccountName"		"gaben"
+		"PersonaName"		"Rabscuttle"
+		"RememberPassword"		"1"
+		"MostRecent"		"0"
+		"Timestamp"		"1462000000"
+	}
+	"76561198000000001"
+	{
+		"AccountName"		"player"
+		"PersonaName"		"Player One"
+		"RememberPassword"		"0"
+		"WantsOfflineMode"		"1"
+		"AllowAutoLogin"		"1"
+		"mostrecent"		"1"
+		"Timestamp"		"1461000000"
+	}
+}
+`
+
+func TestGetLoginUsers(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"config/loginusers.vdf":              loginUsersVdf,
+		"userdata/39734273/config/local.vdf": "",
+	})
+	defer os.RemoveAll(root)
+
+	users, err := GetLoginUsers(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", users)
+	}
+
+	u := users[0]
+	if u.SteamID != 76561198000000001 || !u.MostRecent || u.AccountName != "player" ||
+		!u.WantsOfflineMode || !u.AllowAutoLogin || u.RememberPassword {
+		t.Errorf("most recent user parsed incorrectly: %+v", u)
+	}
+	if u.SteamID.AccountID() != 39734273 {
+		t.Errorf("expected account id 39734273, got %v", u.SteamID.AccountID())
+	}
+	if u.UserDataPath != path.Join(root, "userdata", "39734273") {
+		t.Errorf("unexpected userdata path %v", u.UserDataPath)
+	}
+
+	u = users[1]
+	if u.AccountName != "gaben" || u.PersonaName != "Rabscuttle" || !u.RememberPassword ||
+		!u.Timestamp.Equal(time.Unix(1462000000, 0)) {
+		t.Errorf("second user parsed incorrectly: %+v", u)
+	}
+	if u.UserDataPath != "" {
+		t.Errorf("expected no userdata path, got %v", u.UserDataPath)
+	}
+}
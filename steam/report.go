@@ -0,0 +1,152 @@
+package steam
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// AppUsage is the disk usage of an app installed in a library.
+type AppUsage struct {
+	AppID int
+	Name  string
+	State InstallState
+	// SizeOnDisk is the size of the install as recorded by Steam.
+	SizeOnDisk int64
+	// WorkshopSize is the size of the app's workshop content in the
+	// library, as recorded by Steam.
+	WorkshopSize int64
+	// DirSizes maps the directories of ReportOptions.AppDirs that exist in
+	// the install to their size in bytes. Unlike the other sizes, these are
+	// measured by walking the directory.
+	DirSizes map[string]int64
+}
+
+// LibraryReport summarizes the disk usage of a library.
+type LibraryReport struct {
+	Library
+	// FreeSpace and TotalSpace describe the file system holding the
+	// library. Both are zero if unknown, such as for reports read through
+	// an fs.FS.
+	FreeSpace  uint64
+	TotalSpace uint64
+	// Apps are the apps installed in the library, largest first.
+	Apps []AppUsage
+}
+
+// Used returns the bytes taken by the apps in the library and their
+// workshop content.
+func (r *LibraryReport) Used() int64 {
+	var used int64
+	for _, a := range r.Apps {
+		used += a.SizeOnDisk + a.WorkshopSize
+	}
+	return used
+}
+
+// ReportOptions control what Report measures.
+type ReportOptions struct {
+	// AppDirs lists, per app id, directories relative to the app's install
+	// directory to measure, such as "csgo/replays" for CS:GO.
+	AppDirs map[int][]string
+}
+
+// Report summarizes the disk usage of every library of the Steam
+// installation at steamPath. Libraries that cannot be read are reported in a
+// ScanErrors returned together with the reports of the others.
+func Report(steamPath string, opts ReportOptions) ([]LibraryReport, error) {
+	reports, err := ReportFS(vfs.OS(), vfs.Abs(steamPath), opts)
+	for i := range reports {
+		r := &reports[i]
+		r.FreeSpace, r.TotalSpace, _ = diskSpace(r.Path)
+	}
+	return reports, err
+}
+
+// ReportFS is like Report, reading from fsys. Disk space is not reported.
+func ReportFS(fsys fs.FS, steamPath string, opts ReportOptions) ([]LibraryReport, error) {
+	libs, err := GetLibrariesFS(fsys, steamPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []LibraryReport
+	var errs ScanErrors
+	for _, l := range libs {
+		r, err := reportLibrary(fsys, l, opts)
+		if err != nil {
+			errs.Add("report", l.Path, err)
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports, errs.Err()
+}
+
+func reportLibrary(fsys fs.FS, l Library, opts ReportOptions) (LibraryReport, error) {
+	r := LibraryReport{Library: l}
+	manifests, err := GetAppManifestsFS(fsys, l.Path)
+	if err != nil {
+		return r, err
+	}
+
+	for _, m := range manifests {
+		a := AppUsage{
+			AppID:      m.AppID,
+			Name:       m.Name,
+			State:      m.InstallState(),
+			SizeOnDisk: m.SizeOnDisk,
+		}
+
+		items, err := GetWorkshopItemsFS(fsys, l.Path, m.AppID)
+		if err != nil && !os.IsNotExist(err) {
+			return r, err
+		}
+		for _, item := range items {
+			a.WorkshopSize += item.Size
+		}
+
+		for _, dir := range opts.AppDirs[m.AppID] {
+			size, err := dirSize(fsys, path.Join(m.InstallPath(), dir))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return r, err
+			}
+			if a.DirSizes == nil {
+				a.DirSizes = map[string]int64{}
+			}
+			a.DirSizes[dir] = size
+		}
+
+		r.Apps = append(r.Apps, a)
+	}
+
+	sort.SliceStable(r.Apps, func(i, j int) bool {
+		return r.Apps[i].SizeOnDisk > r.Apps[j].SizeOnDisk
+	})
+	return r, nil
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(fsys fs.FS, dir string) (int64, error) {
+	var size int64
+	err := fs.WalkDir(fsys, vfs.Name(dir), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
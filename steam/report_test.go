@@ -0,0 +1,89 @@
+package steam
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func TestReportFS(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Fsteam/steamapps/libraryfolders.vdf\n"+`"libraryfolders"
+{
+	"0" { "path" "/steam" }
+	"1" { "path" "/games" "label" "games" }
+	"2" { "path" "/unplugged" }
+}`,
+		"Fsteam/steamapps/appmanifest_228980.acf\n"+oldManifest,
+		"Fgames/steamapps/appmanifest_730.acf\n"+csgoManifest,
+		"Fgames/steamapps/appmanifest_440.acf\n"+`"AppState" { "appid" "440" "name" "Team Fortress 2" "StateFlags" "4" "installdir" "Team Fortress 2" "SizeOnDisk" "20000000000" }`,
+		"Fgames/steamapps/workshop/appworkshop_730.acf\n"+`"AppWorkshop"
+{
+	"WorkshopItemsInstalled"
+	{
+		"125438255" { "size" "100" }
+		"125499116" { "size" "200" }
+	}
+}`,
+		"Fgames/steamapps/common/Counter-Strike Global Offensive/csgo/replays/1.dem\n12345",
+		"Fgames/steamapps/common/Counter-Strike Global Offensive/csgo/replays/2.dem\n678",
+	)
+
+	reports, err := ReportFS(fsys, "/steam", ReportOptions{
+		AppDirs: map[int][]string{730: {"csgo/replays", "csgo/missing"}},
+	})
+	errs, ok := err.(ScanErrors)
+	if !ok || len(errs) != 1 || errs[0].Path != "/unplugged" {
+		t.Errorf("expected the unplugged library to be reported, got %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected two reports, got %+v", reports)
+	}
+
+	if r := reports[0]; r.Path != "/steam" || len(r.Apps) != 1 || r.Apps[0].AppID != 228980 || r.Used() != 0 {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	r := reports[1]
+	expected := []AppUsage{
+		{AppID: 440, Name: "Team Fortress 2", State: Installed, SizeOnDisk: 20000000000},
+		{
+			AppID:        730,
+			Name:         "Counter-Strike: Global Offensive",
+			State:        Installed,
+			SizeOnDisk:   15000000000,
+			WorkshopSize: 300,
+			DirSizes:     map[string]int64{"csgo/replays": 8},
+		},
+	}
+	if r.Path != "/games" || r.Label != "games" || !reflect.DeepEqual(r.Apps, expected) {
+		t.Errorf("expected apps %+v, got %+v", expected, r)
+	}
+	if r.Used() != 35000000300 {
+		t.Errorf("expected 35000000300 bytes used, got %d", r.Used())
+	}
+	if r.FreeSpace != 0 || r.TotalSpace != 0 {
+		t.Errorf("expected no disk space through an fs.FS, got %d/%d", r.FreeSpace, r.TotalSpace)
+	}
+}
+
+func TestReportDiskSpace(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/appmanifest_228980.acf": oldManifest,
+		"config/config.vdf":                configVdf,
+	})
+	defer os.RemoveAll(root)
+	if _, _, err := diskSpace(root); err != nil {
+		t.Skip(err)
+	}
+
+	reports, err := Report(root, ReportOptions{})
+	if len(reports) == 0 {
+		t.Fatalf("expected a report of the install, got %v", err)
+	}
+	if r := reports[0]; r.TotalSpace == 0 || r.FreeSpace > r.TotalSpace {
+		t.Errorf("unexpected disk space %d free of %d", r.FreeSpace, r.TotalSpace)
+	}
+}
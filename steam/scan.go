@@ -0,0 +1,165 @@
+package steam
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// ScanErrors lists the problems met while scanning several libraries or
+// directories, one entry per path. A scan that returns a ScanErrors carries
+// on past each problem, so whatever it returns alongside is still valid.
+type ScanErrors []*fs.PathError
+
+func (e ScanErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+	return fmt.Sprintf("%d paths failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Add records that op failed on p with err. The path of a *fs.PathError err
+// is replaced with p, as file systems report names rather than the paths
+// callers passed in.
+func (e *ScanErrors) Add(op, p string, err error) {
+	*e = append(*e, scanError(op, p, err))
+}
+
+func scanError(op, p string, err error) *fs.PathError {
+	if pe, ok := err.(*fs.PathError); ok {
+		err = pe.Err
+	}
+	return &fs.PathError{Op: op, Path: p, Err: err}
+}
+
+// Err returns e as an error, or nil if e is empty.
+func (e ScanErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// DefaultWorkers is the number of paths scanned at once when
+// ScanOptions.Workers is zero. Library scans are bound by disk seeks rather
+// than CPU, so it is kept small.
+const DefaultWorkers = 4
+
+// ScanOptions control the concurrent scans of the Context functions.
+type ScanOptions struct {
+	// FS is the file system scanned. If nil, the host file system is
+	// scanned and relative paths are made absolute first.
+	FS fs.FS
+	// Workers is the number of paths scanned at once, DefaultWorkers if
+	// zero or less.
+	Workers int
+}
+
+func (o ScanOptions) fsys() fs.FS {
+	if o.FS == nil {
+		return vfs.OS()
+	}
+	return o.FS
+}
+
+// path returns p as it should be passed to a scan of o.fsys().
+func (o ScanOptions) path(p string) string {
+	if o.FS == nil {
+		return vfs.Abs(p)
+	}
+	return path.Clean(filepath.ToSlash(p))
+}
+
+func (o ScanOptions) paths(ps []string) []string {
+	cleaned := make([]string, len(ps))
+	for i, p := range ps {
+		cleaned[i] = o.path(p)
+	}
+	return cleaned
+}
+
+// ScanFunc scans the path p of fsys, passing each result to found. An error
+// that is not a ScanErrors or *fs.PathError is reported as a "scan" error of
+// p.
+type ScanFunc func(ctx context.Context, fsys fs.FS, p string, found func(string)) error
+
+// ScanPaths calls scan for each of paths, running up to opts.Workers scans at
+// once, and passes their results to found as they come in. Calls to found are
+// not concurrent, and stop once ctx is done.
+//
+// ScanPaths returns ctx.Err() if ctx was done before every path was scanned.
+// Otherwise the errors of the scans are returned as a ScanErrors, in the
+// order of paths. Paths are cleaned, and made absolute when scanning the host
+// file system, before they are passed to scan.
+func ScanPaths(ctx context.Context, paths []string, opts ScanOptions, scan ScanFunc, found func(string)) error {
+	fsys := opts.fsys()
+	paths = opts.paths(paths)
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	var mu sync.Mutex
+	emit := func(r string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() == nil && found != nil {
+			found(r)
+		}
+	}
+
+	errs := make([]ScanErrors, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := scan(ctx, fsys, paths[i], emit)
+				switch err := err.(type) {
+				case nil:
+				case ScanErrors:
+					errs[i] = err
+				case *fs.PathError:
+					errs[i] = ScanErrors{err}
+				default:
+					errs[i].Add("scan", paths[i], err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var all ScanErrors
+	for _, e := range errs {
+		all = append(all, e...)
+	}
+	return all.Err()
+}
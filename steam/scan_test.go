@@ -0,0 +1,117 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func TestScanPaths(t *testing.T) {
+	var paths []string
+	for i := 0; i < 10; i++ {
+		paths = append(paths, fmt.Sprintf("/library%d", i))
+	}
+
+	var active, peak int32
+	scan := func(ctx context.Context, fsys fs.FS, p string, found func(string)) error {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&peak)
+			if n <= m || atomic.CompareAndSwapInt32(&peak, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		switch p {
+		case "/library3":
+			return errors.New("bad library")
+		case "/library7":
+			return &fs.PathError{Op: "stat", Path: p + "/steamapps", Err: fs.ErrNotExist}
+		}
+		found(p)
+		return nil
+	}
+
+	var results []string
+	err := ScanPaths(context.Background(), paths, ScanOptions{FS: vfstest.Tree(), Workers: 3}, scan, func(p string) {
+		results = append(results, p)
+	})
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent scans, got %d", peak)
+	}
+
+	sort.Strings(results)
+	expected := append(append([]string{}, paths[:3]...), paths[4:7]...)
+	expected = append(expected, paths[8:]...)
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+
+	errs, ok := err.(ScanErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two scan errors, got %v", err)
+	}
+	if errs[0].Op != "scan" || errs[0].Path != "/library3" || errs[1].Op != "stat" || errs[1].Path != "/library7/steamapps" {
+		t.Errorf("unexpected scan errors %v", errs)
+	}
+}
+
+func TestScanPathsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scan := func(ctx context.Context, fsys fs.FS, p string, found func(string)) error {
+		found(p)
+		return nil
+	}
+	var results []string
+	err := ScanPaths(ctx, []string{"/a", "/b", "/c", "/d"}, ScanOptions{FS: vfstest.Tree(), Workers: 1}, scan, func(p string) {
+		results = append(results, p)
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected results to stop after cancelling, got %v", results)
+	}
+}
+
+func TestGetLibraryPathsContext(t *testing.T) {
+	fsys := vfstest.Tree(
+		"Fsteam/steamapps/libraryfolders.vdf\n"+oldLibraryFolders,
+		"Fsteam/config/config.vdf\n"+configVdf,
+		"Dmnt/other/SteamLibrary/steamapps",
+	)
+
+	var paths []string
+	err := GetLibraryPathsContext(context.Background(), "/steam", ScanOptions{FS: fsys}, func(p string) {
+		paths = append(paths, p)
+	})
+	sort.Strings(paths)
+	expected := []string{"/mnt/other/SteamLibrary", "/steam"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+
+	errs, ok := err.(ScanErrors)
+	if !ok || len(errs) != 2 || errs[0].Path != "/mnt/games/SteamLibrary/steamapps" || errs[1].Path != "/mnt/legacy/SteamLibrary/steamapps" {
+		t.Errorf("expected the unreachable libraries to be reported, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := GetLibraryPathsContext(ctx, "/steam", ScanOptions{FS: fsys}, nil); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
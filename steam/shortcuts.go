@@ -0,0 +1,101 @@
+package steam
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// Shortcut is a non-Steam game added to the library of a user, as stored in
+// userdata/<accountid>/config/shortcuts.vdf.
+type Shortcut struct {
+	// AppID is the id Steam generated for the shortcut.
+	AppID         uint32
+	AppName       string
+	Exe           string
+	StartDir      string
+	Icon          string
+	ShortcutPath  string
+	LaunchOptions string
+
+	IsHidden           bool
+	AllowDesktopConfig bool
+	AllowOverlay       bool
+	OpenVR             bool
+
+	LastPlayTime time.Time
+	Tags         []string
+}
+
+// ReadShortcuts decodes a shortcuts.vdf file.
+func ReadShortcuts(r io.Reader) ([]Shortcut, error) {
+	data, err := ReadBinaryVDF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root := data.Child("shortcuts")
+	if root == nil || !root.Section {
+		return nil, fmt.Errorf("shortcuts.vdf missing shortcuts")
+	}
+
+	var shortcuts []Shortcut
+	for _, n := range numericChildren(root) {
+		if !n.Section {
+			continue
+		}
+		get := func(key string) string {
+			v, _ := n.Get(key)
+			return v
+		}
+
+		s := Shortcut{
+			AppName:            get("AppName"),
+			Exe:                get("Exe"),
+			StartDir:           get("StartDir"),
+			Icon:               get("icon"),
+			ShortcutPath:       get("ShortcutPath"),
+			LaunchOptions:      get("LaunchOptions"),
+			IsHidden:           get("IsHidden") == "1",
+			AllowDesktopConfig: get("AllowDesktopConfig") == "1",
+			AllowOverlay:       get("AllowOverlay") == "1",
+			OpenVR:             get("OpenVR") == "1",
+		}
+		// the app id is written as a signed int32
+		if id, err := strconv.ParseInt(get("appid"), 10, 32); err == nil {
+			s.AppID = uint32(id)
+		}
+		if t, err := strconv.ParseInt(get("LastPlayTime"), 10, 64); err == nil && t != 0 {
+			s.LastPlayTime = time.Unix(t, 0)
+		}
+		for _, tag := range numericChildren(n.Child("tags")) {
+			s.Tags = append(s.Tags, tag.Value)
+		}
+
+		shortcuts = append(shortcuts, s)
+	}
+
+	return shortcuts, nil
+}
+
+// GetShortcuts reads the non-Steam games of the user whose userdata
+// directory is userDataPath. See LoginUser.UserDataPath.
+func GetShortcuts(userDataPath string) ([]Shortcut, error) {
+	return GetShortcutsFS(vfs.OS(), vfs.Abs(userDataPath))
+}
+
+// GetShortcutsFS is like GetShortcuts, reading from fsys.
+func GetShortcutsFS(fsys fs.FS, userDataPath string) ([]Shortcut, error) {
+	f, err := fsys.Open(vfs.Name(path.Join(userDataPath, "config", "shortcuts.vdf")))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadShortcuts(f)
+}
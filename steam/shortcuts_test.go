@@ -0,0 +1,78 @@
+package steam
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
+)
+
+func TestGetShortcuts(t *testing.T) {
+	var b bvdfBuilder
+	b.begin("shortcuts").
+		begin("0").
+		int32("appid", -1234567890).
+		str("AppName", "Demo Viewer").
+		str("Exe", `"C:\Tools\viewer.exe"`).
+		str("StartDir", `"C:\Tools\"`).
+		str("icon", "").
+		str("ShortcutPath", "").
+		str("LaunchOptions", "-demo").
+		int32("IsHidden", 0).
+		int32("AllowDesktopConfig", 1).
+		int32("AllowOverlay", 1).
+		int32("OpenVR", 0).
+		int32("LastPlayTime", 1462300000).
+		begin("tags").str("0", "tools").str("1", "csgo").end().
+		end().
+		end().end()
+
+	root := makeSteamRoot(t, map[string]string{
+		"userdata/22202/config/shortcuts.vdf": b.String(),
+	})
+	defer os.RemoveAll(root)
+
+	shortcuts, err := GetShortcuts(root + "/userdata/22202")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Shortcut{{
+		AppID:              uint32(3060399406),
+		AppName:            "Demo Viewer",
+		Exe:                `"C:\Tools\viewer.exe"`,
+		StartDir:           `"C:\Tools\"`,
+		LaunchOptions:      "-demo",
+		AllowDesktopConfig: true,
+		AllowOverlay:       true,
+		LastPlayTime:       time.Unix(1462300000, 0),
+		Tags:               []string{"tools", "csgo"},
+	}}
+	if !reflect.DeepEqual(shortcuts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, shortcuts)
+	}
+}
+
+func TestGetShortcutsFS(t *testing.T) {
+	var b bvdfBuilder
+	b.begin("shortcuts").
+		begin("1").int32("appid", 7).str("AppName", "second").end().
+		begin("0").int32("appid", 5).str("AppName", "first").end().
+		end().end()
+
+	fsys := vfstest.Tree("F/steam/userdata/22202/config/shortcuts.vdf\n" + b.String())
+	shortcuts, err := GetShortcutsFS(fsys, "/steam/userdata/22202")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Shortcut{{AppID: 5, AppName: "first"}, {AppID: 7, AppName: "second"}}
+	if !reflect.DeepEqual(shortcuts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, shortcuts)
+	}
+
+	if _, err := GetShortcutsFS(fsys, "/steam/userdata/1"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
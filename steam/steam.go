@@ -1,53 +1,136 @@
 package steam
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io/fs"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 
-	"github.com/andygrunwald/vdf"
+	"github.com/ajmadsen/replayanalyzer/keyvalues"
+	"github.com/ajmadsen/replayanalyzer/vfs"
 )
 
 var (
 	keyMatcher = regexp.MustCompile(`^BaseInstallFolder_\d+`)
 )
 
+// GetLibraryPaths returns the root of every library of the Steam installation
+// at steamPath, starting with the installation itself. See GetLibraries.
 func GetLibraryPaths(steamPath string) ([]string, error) {
+	return GetLibraryPathsFS(vfs.OS(), vfs.Abs(steamPath))
+}
+
+// GetLibraryPathsFS is like GetLibraryPaths, reading from fsys.
+func GetLibraryPathsFS(fsys fs.FS, steamPath string) ([]string, error) {
+	libs, err := GetLibrariesFS(fsys, steamPath)
+	if err != nil {
+		return nil, err
+	}
+
 	var libraryPaths []string
+	for _, l := range libs {
+		libraryPaths = append(libraryPaths, l.Path)
+	}
 
-	libraryPaths = append(libraryPaths, path.Clean(filepath.ToSlash(steamPath)))
+	return libraryPaths, nil
+}
 
-	configFileStr := path.Join(steamPath, "config", "config.vdf")
-	configFile, err := os.Open(configFileStr)
+// GetLibraryPathsContext is a concurrent GetLibraryPaths for libraries on
+// slow or removable drives. After reading the library configuration, it
+// checks every library for a steamapps directory and passes those that have
+// one to found as they respond. Libraries that cannot be reached are
+// reported in a ScanErrors instead. See ScanPaths.
+func GetLibraryPathsContext(ctx context.Context, steamPath string, opts ScanOptions, found func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	libraryPaths, err := GetLibraryPathsFS(opts.fsys(), opts.path(steamPath))
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	scan := func(ctx context.Context, fsys fs.FS, l string, found func(string)) error {
+		steamapps := path.Join(l, "steamapps")
+		if _, err := fs.Stat(fsys, vfs.Name(steamapps)); err != nil {
+			return scanError("stat", steamapps, err)
+		}
+		found(l)
+		return nil
 	}
-	defer configFile.Close()
+	return ScanPaths(ctx, libraryPaths, opts, scan, found)
+}
 
-	configParser := vdf.NewParser(configFile)
-	config, err := configParser.Parse()
+// readConfigLibraryPaths reads the BaseInstallFolder_N library paths older
+// Steam clients store in config.vdf. Newer clients may leave out the whole
+// section, which yields no paths rather than an error.
+func readConfigLibraryPaths(fsys fs.FS, configFileStr string) ([]string, error) {
+	config, err := readKeyValuesFile(fsys, configFileStr)
 	if err != nil {
 		return nil, err
 	}
-	var ok bool
 
 	cfgPath := []string{"InstallConfigStore", "Software", "Valve", "Steam"}
 	nav := config
 	for _, s := range cfgPath {
-		if nav, ok = nav[s].(map[string]interface{}); !ok {
-			return nil, fmt.Errorf("config.vdf missing %s", s)
+		if nav = nav.Child(s); nav == nil || !nav.Section {
+			return nil, nil
 		}
 	}
 
-	for k := range nav {
-		if keyMatcher.Match([]byte(k)) {
-			if p, ok := nav[k].(string); ok {
-				libraryPaths = append(libraryPaths, path.Clean(filepath.ToSlash(p)))
-			}
+	var libraryPaths []string
+	for _, n := range nav.Children {
+		if keyMatcher.MatchString(n.Key) && !n.Section {
+			libraryPaths = append(libraryPaths, path.Clean(filepath.ToSlash(n.Value)))
 		}
 	}
 
 	return libraryPaths, nil
 }
+
+// readKeyValuesFile parses the text KeyValues file name in fsys.
+func readKeyValuesFile(fsys fs.FS, name string) (*keyvalues.Node, error) {
+	f, err := fsys.Open(vfs.Name(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return keyvalues.ParseNamed(f, name)
+}
+
+// isDir reports whether p is a directory in fsys.
+func isDir(fsys fs.FS, p string) bool {
+	info, err := fs.Stat(fsys, vfs.Name(p))
+	return err == nil && info.IsDir()
+}
+
+// parseIntKey parses the decimal value of key in n into dst, which must be a
+// *int or *int64. Missing keys leave dst untouched.
+func parseIntKey(n *keyvalues.Node, key string, dst interface{}) error {
+	s, ok := n.Get(key)
+	if !ok || s == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", key, s)
+	}
+	switch dst := dst.(type) {
+	case *int:
+		*dst = int(v)
+	case *int64:
+		*dst = v
+	default:
+		panic("parseIntKey: unsupported destination type")
+	}
+	return nil
+}
+
+// getBool reports whether key in n is set to "1".
+func getBool(n *keyvalues.Node, key string) bool {
+	s, _ := n.Get(key)
+	return s == "1"
+}
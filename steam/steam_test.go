@@ -3,13 +3,32 @@ package steam
 import (
 	"os"
 	"path"
+	"reflect"
 	"testing"
+
+	"github.com/ajmadsen/replayanalyzer/vfs/vfstest"
 )
 
 func TestGetSteamPath(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"config/config.vdf": configVdf,
+		"steamapps/.keep":   "",
+	})
+	defer os.RemoveAll(root)
+
+	old, set := os.LookupEnv("STEAM_PATH")
+	os.Setenv("STEAM_PATH", root)
+	defer func() {
+		if set {
+			os.Setenv("STEAM_PATH", old)
+		} else {
+			os.Unsetenv("STEAM_PATH")
+		}
+	}()
+
 	steamPath, err := GetInstallPath()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	testPaths := []string{
@@ -26,26 +45,27 @@ func TestGetSteamPath(t *testing.T) {
 }
 
 func TestGetLibraryPaths(t *testing.T) {
-	steamPath, err := GetInstallPath()
-	if err != nil {
-		t.Error(err)
-	}
+	fsys := vfstest.Tree(
+		"Fsteam/steamapps/libraryfolders.vdf\n"+oldLibraryFolders,
+		"Fsteam/config/config.vdf\n"+configVdf,
+	)
 
-	strings, err := GetLibraryPaths(steamPath)
+	paths, err := GetLibraryPathsFS(fsys, "/steam")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	t.Logf("Found paths: %v\n", strings)
+	expected := []string{
+		"/steam",
+		"/mnt/games/SteamLibrary",
+		"/mnt/other/SteamLibrary",
+		"/mnt/legacy/SteamLibrary",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
 
-	for _, p := range strings {
-		newPath := path.Join(p, "steamapps")
-		info, err := os.Stat(newPath)
-		if err != nil {
-			t.Error(err)
-		}
-		if !info.IsDir() {
-			t.Errorf("%v is not a directory despite being found by getLibraryPaths()", newPath)
-		}
+	if _, err := GetLibraryPathsFS(fsys, "/missing"); err == nil {
+		t.Error("expected an error for a directory without library configuration")
 	}
 }
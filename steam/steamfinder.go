@@ -1,7 +1,16 @@
-// +build !windows
+//go:build !windows && !linux && !darwin
+// +build !windows,!linux,!darwin
 
 package steam
 
-func GetInstallPath() (string, error) {
-	panic("getSteamPath not implemented for this platform")
+func systemRegistry() Registry {
+	return nil
+}
+
+func wellKnownDirs() []string {
+	return nil
+}
+
+func findSteamProcesses() ([]string, error) {
+	return nil, nil
 }
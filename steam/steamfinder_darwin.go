@@ -0,0 +1,15 @@
+package steam
+
+import (
+	"path/filepath"
+)
+
+func installCandidates(home string) []string {
+	return []string{
+		filepath.Join(home, "Library", "Application Support", "Steam"),
+	}
+}
+
+func findSteamProcesses() ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,51 @@
+package steam
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// installCandidates lists the places the native, Flatpak and Snap Steam
+// packages keep their root, in order of preference.
+func installCandidates(home string) []string {
+	return []string{
+		filepath.Join(home, ".steam", "steam"),
+		filepath.Join(home, ".steam", "root"),
+		filepath.Join(home, ".local", "share", "Steam"),
+		filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".steam", "steam"),
+		filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam"),
+		filepath.Join(home, "snap", "steam", "common", ".steam", "steam"),
+		filepath.Join(home, "snap", "steam", "common", ".local", "share", "Steam"),
+	}
+}
+
+func findSteamProcesses() ([]string, error) {
+	return findSteamProcessesIn("/proc")
+}
+
+// findSteamProcessesIn returns the roots of the Steam clients running
+// according to the proc filesystem at proc. The client binary lives in
+// ubuntu12_32 below the root.
+func findSteamProcessesIn(proc string) ([]string, error) {
+	entries, err := os.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join(proc, e.Name(), "exe"))
+		if err != nil || filepath.Base(exe) != "steam" {
+			continue
+		}
+		dir := filepath.Dir(exe)
+		if b := filepath.Base(dir); b == "ubuntu12_32" || b == "ubuntu12_64" {
+			roots = append(roots, filepath.Dir(dir))
+		}
+	}
+	return roots, nil
+}
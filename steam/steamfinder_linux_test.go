@@ -0,0 +1,87 @@
+package steam
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWellKnownDirsFakeHome(t *testing.T) {
+	home, err := ioutil.TempDir("", "steamhome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	home, err = filepath.EvalSymlinks(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+
+	if p, err := DirsLocator(wellKnownDirs()...).Locate(); err != nil || len(p) != 0 {
+		t.Errorf("found installs %v in an empty home: %v", p, err)
+	}
+
+	// flatpak install only
+	flatpak := filepath.Join(home, ".var/app/com.valvesoftware.Steam/.local/share/Steam")
+	if err := os.MkdirAll(filepath.Join(flatpak, "steamapps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Chain{DirsLocator(wellKnownDirs()...)}.Locate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, []string{filepath.ToSlash(flatpak)}) {
+		t.Errorf("expected %v, got %v", flatpak, p)
+	}
+
+	// the native install, reached through the ~/.steam/steam symlink, comes
+	// first and is only reported once
+	native := filepath.Join(home, ".local/share/Steam")
+	if err := os.MkdirAll(filepath.Join(native, "steamapps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".steam"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(native, filepath.Join(home, ".steam", "steam")); err != nil {
+		t.Fatal(err)
+	}
+	p, err = Chain{DirsLocator(wellKnownDirs()...)}.Locate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{path.Clean(filepath.ToSlash(native)), filepath.ToSlash(flatpak)}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %v, got %v", expected, p)
+	}
+}
+
+func TestFindSteamProcesses(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steam/steamapps/libraryfolders.vdf": "",
+		"steam/ubuntu12_32/steam":            "",
+		"proc/self/status":                   "",
+		"proc/1/status":                      "",
+		"proc/1234/status":                   "",
+	})
+	defer os.RemoveAll(root)
+
+	if err := os.Symlink("/usr/bin/bash", filepath.Join(root, "proc/1/exe")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "steam/ubuntu12_32/steam"), filepath.Join(root, "proc/1234/exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := findSteamProcessesIn(filepath.Join(root, "proc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roots, []string{filepath.Join(root, "steam")}) {
+		t.Errorf("unexpected process roots %v", roots)
+	}
+}
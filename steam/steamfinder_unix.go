@@ -0,0 +1,22 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package steam
+
+import (
+	"os"
+)
+
+func systemRegistry() Registry {
+	return nil
+}
+
+// wellKnownDirs returns the install locations under the current user's home
+// directory.
+func wellKnownDirs() []string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return nil
+	}
+	return installCandidates(home)
+}
@@ -1,20 +1,73 @@
 package steam
 
 import (
-	"golang.org/x/sys/windows/registry"
+	"os"
 	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 )
 
-func GetInstallPath() (string, error) {
-	key, err := registry.OpenKey(registry.CURRENT_USER, `Software\Valve\Steam`, registry.QUERY_VALUE)
+type winRegistry struct{}
+
+func (winRegistry) StringValue(root RegistryRoot, path, name string) (string, error) {
+	k := registry.CURRENT_USER
+	if root == RegistryLocalMachine {
+		k = registry.LOCAL_MACHINE
+	}
+
+	key, err := registry.OpenKey(k, path, registry.QUERY_VALUE)
 	if err != nil {
 		return "", err
 	}
+	defer key.Close()
+
+	p, _, err := key.GetStringValue(name)
+	return p, err
+}
 
-	p, _, err := key.GetStringValue("SteamPath")
+func systemRegistry() Registry {
+	return winRegistry{}
+}
+
+func wellKnownDirs() []string {
+	var dirs []string
+	for _, env := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+		if p := os.Getenv(env); p != "" {
+			dirs = append(dirs, filepath.Join(p, "Steam"))
+		}
+	}
+	return dirs
+}
+
+// findSteamProcesses returns the directories of running steam.exe processes.
+func findSteamProcesses() ([]string, error) {
+	snap, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer windows.CloseHandle(snap)
 
-	return filepath.Clean(filepath.ToSlash(p)), nil
+	var roots []string
+	var pe windows.ProcessEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	for err = windows.Process32First(snap, &pe); err == nil; err = windows.Process32Next(snap, &pe) {
+		if !strings.EqualFold(windows.UTF16ToString(pe.ExeFile[:]), "steam.exe") {
+			continue
+		}
+		h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pe.ProcessID)
+		if err != nil {
+			continue
+		}
+		buf := make([]uint16, windows.MAX_LONG_PATH)
+		n := uint32(len(buf))
+		err = windows.QueryFullProcessImageName(h, 0, &buf[0], &n)
+		windows.CloseHandle(h)
+		if err == nil {
+			roots = append(roots, filepath.Dir(windows.UTF16ToString(buf[:n])))
+		}
+	}
+	return roots, nil
 }
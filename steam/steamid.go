@@ -0,0 +1,246 @@
+package steam
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SteamID identifies a Steam account. Its value is the 64-bit form used by
+// the Steam Web API and loginusers.vdf, which packs the universe, account
+// type, instance and account id into one integer.
+type SteamID uint64
+
+// Universe is the Steam universe an account belongs to.
+type Universe uint8
+
+const (
+	UniverseInvalid Universe = iota
+	UniversePublic
+	UniverseBeta
+	UniverseInternal
+	UniverseDev
+)
+
+// AccountType is the kind of Steam account.
+type AccountType uint8
+
+const (
+	AccountTypeInvalid AccountType = iota
+	AccountTypeIndividual
+	AccountTypeMultiseat
+	AccountTypeGameServer
+	AccountTypeAnonGameServer
+	AccountTypePending
+	AccountTypeContentServer
+	AccountTypeClan
+	AccountTypeChat
+	AccountTypeConsoleUser
+	AccountTypeAnonUser
+)
+
+// instances used by Steam3 ids
+const (
+	// InstanceDesktop is the instance of ordinary user accounts.
+	InstanceDesktop = 1
+
+	instanceMask      = 0xfffff
+	chatInstanceClan  = (instanceMask + 1) >> 1
+	chatInstanceLobby = (instanceMask + 1) >> 2
+)
+
+// Steam3 account type letters, indexed by AccountType. Console users have no
+// letter of their own.
+const steam3Letters = "IUMGAPCgT?a"
+
+var (
+	steam2Matcher = regexp.MustCompile(`^STEAM_([0-9]+):([01]):([0-9]+)$`)
+	steam3Matcher = regexp.MustCompile(`^\[?([A-Za-z]):([0-9]+):([0-9]+)(?::([0-9]+))?\]?$`)
+)
+
+// NewSteamID assembles a SteamID from its parts.
+func NewSteamID(universe Universe, typ AccountType, instance uint32, accountID uint32) SteamID {
+	return SteamID(uint64(universe)<<56 |
+		uint64(typ&0xf)<<52 |
+		uint64(instance&instanceMask)<<32 |
+		uint64(accountID))
+}
+
+// NewIndividualSteamID returns the SteamID of the public individual account
+// with the given account id, the kind of account players use.
+func NewIndividualSteamID(accountID uint32) SteamID {
+	return NewSteamID(UniversePublic, AccountTypeIndividual, InstanceDesktop, accountID)
+}
+
+// ParseSteamID parses any of the textual SteamID forms:
+//
+//	76561197960287930  SteamID64
+//	STEAM_0:0:11101    Steam2
+//	[U:1:22202]        Steam3, brackets optional
+//	22202              bare account id of an individual account
+//
+// Decimal values that fit in 32 bits are taken as account ids.
+func ParseSteamID(s string) (SteamID, error) {
+	s = strings.TrimSpace(s)
+
+	if m := steam2Matcher.FindStringSubmatch(s); m != nil {
+		universe, err := strconv.ParseUint(m[1], 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid steam id %q: %v", s, err)
+		}
+		z, err := strconv.ParseUint(m[3], 10, 31)
+		if err != nil {
+			return 0, fmt.Errorf("invalid steam id %q: %v", s, err)
+		}
+		// Older engines print the public universe as 0
+		if universe == 0 {
+			universe = uint64(UniversePublic)
+		}
+		accountID := uint32(z)<<1 | uint32(m[2][0]-'0')
+		return NewSteamID(Universe(universe), AccountTypeIndividual, InstanceDesktop, accountID), nil
+	}
+
+	if m := steam3Matcher.FindStringSubmatch(s); m != nil {
+		letter := m[1][0]
+		var typ AccountType
+		if letter == 'c' || letter == 'L' {
+			typ = AccountTypeChat
+		} else if idx := strings.IndexByte(steam3Letters, letter); idx >= 0 {
+			typ = AccountType(idx)
+		} else {
+			return 0, fmt.Errorf("invalid steam id %q: unknown account type %c", s, letter)
+		}
+		universe, err := strconv.ParseUint(m[2], 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid steam id %q: %v", s, err)
+		}
+		accountID, err := strconv.ParseUint(m[3], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid steam id %q: %v", s, err)
+		}
+
+		var instance uint32
+		switch letter {
+		case 'U':
+			instance = InstanceDesktop
+		case 'c':
+			instance = chatInstanceClan
+		case 'L':
+			instance = chatInstanceLobby
+		}
+		if m[4] != "" {
+			n, err := strconv.ParseUint(m[4], 10, 20)
+			if err != nil {
+				return 0, fmt.Errorf("invalid steam id %q: %v", s, err)
+			}
+			instance = uint32(n)
+		}
+		return NewSteamID(Universe(universe), typ, instance, uint32(accountID)), nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid steam id %q", s)
+	}
+	if n <= 0xffffffff {
+		return NewIndividualSteamID(uint32(n)), nil
+	}
+	return SteamID(n), nil
+}
+
+// AccountID returns the 32-bit account id. Userdata directories and demo
+// player info use this number.
+func (id SteamID) AccountID() uint32 {
+	return uint32(id)
+}
+
+// Instance returns the account instance.
+func (id SteamID) Instance() uint32 {
+	return uint32(id>>32) & instanceMask
+}
+
+// Type returns the account type.
+func (id SteamID) Type() AccountType {
+	return AccountType(id>>52) & 0xf
+}
+
+// Universe returns the universe the account belongs to.
+func (id SteamID) Universe() Universe {
+	return Universe(id >> 56)
+}
+
+// IsValid reports whether id has a known universe and account type.
+func (id SteamID) IsValid() bool {
+	return id.Universe() > UniverseInvalid && id.Universe() <= UniverseDev &&
+		id.Type() > AccountTypeInvalid && id.Type() <= AccountTypeAnonUser
+}
+
+// String returns the SteamID64 form.
+func (id SteamID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// Steam2 returns the STEAM_X:Y:Z form. The universe is printed as is, so
+// public accounts are rendered as STEAM_1 like CS:GO does, not STEAM_0.
+func (id SteamID) Steam2() string {
+	a := id.AccountID()
+	return fmt.Sprintf("STEAM_%d:%d:%d", id.Universe(), a&1, a>>1)
+}
+
+// Steam3 returns the [T:U:ID] form.
+func (id SteamID) Steam3() string {
+	typ := id.Type()
+	letter := byte('I')
+	if int(typ) < len(steam3Letters) {
+		letter = steam3Letters[typ]
+	}
+	instance := id.Instance()
+	if typ == AccountTypeChat {
+		switch {
+		case instance&chatInstanceClan != 0:
+			letter = 'c'
+		case instance&chatInstanceLobby != 0:
+			letter = 'L'
+		}
+	}
+
+	switch typ {
+	case AccountTypeAnonGameServer, AccountTypeMultiseat:
+		return fmt.Sprintf("[%c:%d:%d:%d]", letter, id.Universe(), id.AccountID(), instance)
+	}
+	return fmt.Sprintf("[%c:%d:%d]", letter, id.Universe(), id.AccountID())
+}
+
+// MarshalText encodes id in the SteamID64 form. JSON encodes the value as a
+// string, as JavaScript numbers cannot hold 64-bit ids.
+func (id SteamID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText accepts any form understood by ParseSteamID.
+func (id *SteamID) UnmarshalText(b []byte) error {
+	v, err := ParseSteamID(string(b))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// UnmarshalJSON accepts JSON numbers in addition to strings.
+func (id *SteamID) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		s, err := strconv.Unquote(string(b))
+		if err != nil {
+			return err
+		}
+		return id.UnmarshalText([]byte(s))
+	}
+	if string(b) == "null" {
+		return nil
+	}
+	return id.UnmarshalText(b)
+}
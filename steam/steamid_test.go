@@ -0,0 +1,103 @@
+package steam
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSteamID(t *testing.T) {
+	gaben := SteamID(76561197960287930)
+	tests := map[string]SteamID{
+		"76561197960287930":    gaben,
+		"STEAM_0:0:11101":      gaben,
+		"STEAM_1:0:11101":      gaben,
+		"[U:1:22202]":          gaben,
+		"U:1:22202":            gaben,
+		"22202":                gaben,
+		"[A:1:1234:5]":         NewSteamID(UniversePublic, AccountTypeAnonGameServer, 5, 1234),
+		"[G:1:4321]":           NewSteamID(UniversePublic, AccountTypeGameServer, 0, 4321),
+		"[g:1:4777282]":        NewSteamID(UniversePublic, AccountTypeClan, 0, 4777282),
+		"[L:1:42]":             NewSteamID(UniversePublic, AccountTypeChat, chatInstanceLobby, 42),
+		" 76561198000000001\n": NewIndividualSteamID(39734273),
+	}
+
+	for s, expected := range tests {
+		id, err := ParseSteamID(s)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", s, err)
+			continue
+		}
+		if id != expected {
+			t.Errorf("parsed %q as %v, expected %v", s, id, expected)
+		}
+	}
+
+	for _, s := range []string{"", "STEAM_0:2:1", "[Q:1:1]", "U:1", "steam", "-1"} {
+		if id, err := ParseSteamID(s); err == nil {
+			t.Errorf("expected %q to fail, got %v", s, id)
+		}
+	}
+}
+
+func TestSteamIDFormats(t *testing.T) {
+	id := SteamID(76561197960287930)
+	if id.AccountID() != 22202 || id.Instance() != InstanceDesktop ||
+		id.Type() != AccountTypeIndividual || id.Universe() != UniversePublic || !id.IsValid() {
+		t.Errorf("unexpected parts for %v: %v %v %v %v", id, id.AccountID(), id.Instance(), id.Type(), id.Universe())
+	}
+
+	tests := []struct {
+		id             SteamID
+		steam2, steam3 string
+	}{
+		{id, "STEAM_1:0:11101", "[U:1:22202]"},
+		{NewIndividualSteamID(39734273), "STEAM_1:1:19867136", "[U:1:39734273]"},
+		{NewSteamID(UniversePublic, AccountTypeAnonGameServer, 5, 1234), "STEAM_1:0:617", "[A:1:1234:5]"},
+		{NewSteamID(UniversePublic, AccountTypeChat, chatInstanceClan, 7), "STEAM_1:1:3", "[c:1:7]"},
+	}
+	for _, tt := range tests {
+		if s := tt.id.Steam2(); s != tt.steam2 {
+			t.Errorf("expected steam2 %v, got %v", tt.steam2, s)
+		}
+		if s := tt.id.Steam3(); s != tt.steam3 {
+			t.Errorf("expected steam3 %v, got %v", tt.steam3, s)
+		}
+		back, err := ParseSteamID(tt.id.Steam3())
+		if err != nil || back != tt.id {
+			t.Errorf("steam3 %v did not round trip: %v %v", tt.id.Steam3(), back, err)
+		}
+	}
+
+	if SteamID(0).IsValid() {
+		t.Error("zero steam id reported valid")
+	}
+}
+
+func TestSteamIDJSON(t *testing.T) {
+	type player struct {
+		ID SteamID `json:"id"`
+	}
+
+	b, err := json.Marshal(player{SteamID(76561197960287930)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"76561197960287930"}` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+
+	for _, s := range []string{
+		`{"id":"76561197960287930"}`,
+		`{"id":76561197960287930}`,
+		`{"id":"STEAM_0:0:11101"}`,
+	} {
+		var p player
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			t.Errorf("failed to decode %s: %v", s, err)
+			continue
+		}
+		if p.ID != 76561197960287930 {
+			t.Errorf("decoded %s as %v", s, p.ID)
+		}
+	}
+}
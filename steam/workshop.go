@@ -0,0 +1,94 @@
+package steam
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// WorkshopItem is a Steam Workshop item installed in a library.
+type WorkshopItem struct {
+	// ID is the published file id of the item.
+	ID          uint64
+	AppID       int
+	Size        int64
+	TimeUpdated time.Time
+	Manifest    string
+
+	// Path is the directory the item's content is in, or empty if the
+	// content is not on disk.
+	Path string
+}
+
+// WorkshopContentPath returns the directory holding the workshop content of
+// appID in the library at libraryPath.
+func WorkshopContentPath(libraryPath string, appID int) string {
+	return path.Join(filepath.ToSlash(libraryPath), "steamapps", "workshop", "content", strconv.Itoa(appID))
+}
+
+// GetWorkshopItems returns the workshop items of appID installed in the
+// library at libraryPath, as listed by steamapps/workshop/appworkshop_<appid>.acf,
+// ordered by id. The returned error satisfies os.IsNotExist if the library
+// has no workshop content for the app.
+func GetWorkshopItems(libraryPath string, appID int) ([]WorkshopItem, error) {
+	return GetWorkshopItemsFS(vfs.OS(), vfs.Abs(libraryPath), appID)
+}
+
+// GetWorkshopItemsFS is like GetWorkshopItems, reading from fsys.
+func GetWorkshopItemsFS(fsys fs.FS, libraryPath string, appID int) ([]WorkshopItem, error) {
+	name := path.Join(filepath.ToSlash(libraryPath), "steamapps", "workshop", fmt.Sprintf("appworkshop_%d.acf", appID))
+	acf, err := readKeyValuesFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	root := acf.Child("AppWorkshop")
+	if root == nil || !root.Section {
+		return nil, fmt.Errorf("%v: missing AppWorkshop", name)
+	}
+	installed := root.Child("WorkshopItemsInstalled")
+	if installed == nil {
+		return nil, nil
+	}
+
+	contentPath := WorkshopContentPath(libraryPath, appID)
+	var items []WorkshopItem
+	for _, n := range installed.Children {
+		id, err := strconv.ParseUint(n.Key, 10, 64)
+		if err != nil || !n.Section {
+			return nil, fmt.Errorf("%v: invalid workshop item %q", name, n.Key)
+		}
+
+		item := WorkshopItem{ID: id, AppID: appID}
+		item.Manifest, _ = n.Get("manifest")
+		var updated int64
+		if err := parseIntKey(n, "size", &item.Size); err != nil {
+			return nil, fmt.Errorf("%v: item %d: %v", name, id, err)
+		}
+		if err := parseIntKey(n, "timeupdated", &updated); err != nil {
+			return nil, fmt.Errorf("%v: item %d: %v", name, id, err)
+		}
+		if updated != 0 {
+			item.TimeUpdated = time.Unix(updated, 0)
+		}
+
+		p := path.Join(contentPath, n.Key)
+		if isDir(fsys, p) {
+			item.Path = p
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
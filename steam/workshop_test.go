@@ -0,0 +1,74 @@
+package steam
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+const appWorkshop730 = `"AppWorkshop"
+{
+	"appid"		"730"
+	"SizeOnDisk"		"123456789"
+	"NeedsUpdate"		"0"
+	"WorkshopItemsInstalled"
+	{
+		"125438255"
+		{
+			"size"		"98765432"
+			"timeupdated"		"1462300000"
+			"manifest"		"1234567890123456789"
+		}
+		"1255421310"
+		{
+			"size"		"24691357"
+			"timeupdated"		"1461000000"
+			"manifest"		"987654321"
+		}
+	}
+	"WorkshopItemDetails"
+	{
+		"125438255"
+		{
+			"manifest"		"1234567890123456789"
+			"timeupdated"		"1462300000"
+		}
+	}
+}
+`
+
+func TestGetWorkshopItems(t *testing.T) {
+	root := makeSteamRoot(t, map[string]string{
+		"steamapps/workshop/appworkshop_730.acf":                         appWorkshop730,
+		"steamapps/workshop/content/730/125438255/de_cache_workshop.bsp": "",
+	})
+	defer os.RemoveAll(root)
+
+	items, err := GetWorkshopItems(root, 730)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %+v", items)
+	}
+
+	expected := WorkshopItem{
+		ID:          125438255,
+		AppID:       730,
+		Size:        98765432,
+		TimeUpdated: time.Unix(1462300000, 0),
+		Manifest:    "1234567890123456789",
+		Path:        path.Join(root, "steamapps/workshop/content/730/125438255"),
+	}
+	if items[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, items[0])
+	}
+	if items[1].ID != 1255421310 || items[1].Path != "" {
+		t.Errorf("expected item 1255421310 without content, got %+v", items[1])
+	}
+
+	if _, err := GetWorkshopItems(root, 440); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// Package vfs adapts the paths used by the steam and csgo packages to
+// io/fs, so discovery can run against the host file system, an in-memory
+// tree or a zipped snapshot of a Steam directory alike.
+//
+// Paths throughout this module are cleaned and slash separated, such as
+// "/home/user/.steam/steam" or "C:/Program Files (x86)/Steam". Name turns
+// such a path into a name for an fs.FS, and OS returns the file system those
+// names refer to on the host.
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type osFS struct{}
+
+// OS returns the host file system. Names are absolute paths without the
+// leading slash, as returned by Name.
+func OS() fs.FS {
+	return osFS{}
+}
+
+func (osFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	switch {
+	case filepath.Separator == '\\' && strings.HasPrefix(name, uncPrefix):
+		name = "//" + strings.TrimPrefix(name, uncPrefix)
+	case filepath.VolumeName(name) == "":
+		name = "/" + name
+	}
+	return filepath.FromSlash(name), nil
+}
+
+func (f osFS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (f osFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (f osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (f osFS) ReadFile(name string) ([]byte, error) {
+	p, err := f.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+// uncPrefix replaces the leading // of UNC paths in names, the way Windows
+// spells them in its \\?\UNC\ namespace.
+const uncPrefix = "UNC/"
+
+// isUNC reports whether the slash separated path p is a UNC path such as
+// //server/share/Steam.
+func isUNC(p string) bool {
+	return len(p) > 2 && p[0] == '/' && p[1] == '/' && p[2] != '/'
+}
+
+// clean is path.Clean keeping the leading // of a UNC path.
+func clean(p string) string {
+	p = filepath.ToSlash(p)
+	if isUNC(p) {
+		return "/" + path.Clean(p)
+	}
+	return path.Clean(p)
+}
+
+// Name returns the fs.FS name of p, a slash separated path. The name of a
+// UNC path such as //server/share/Steam is UNC/server/share/Steam, which OS
+// opens as \\server\share\Steam on Windows.
+func Name(p string) string {
+	p = clean(p)
+	if isUNC(p) {
+		return uncPrefix + p[2:]
+	}
+	p = strings.TrimLeft(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// Abs makes p absolute and slash separated, so that Name(Abs(p)) refers to p
+// in OS.
+func Abs(p string) string {
+	if a, err := filepath.Abs(p); err == nil {
+		p = a
+	}
+	return clean(p)
+}
@@ -0,0 +1,54 @@
+package vfs
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"/home/user/.steam/steam":       "home/user/.steam/steam",
+		"C:/Program Files (x86)/Steam/": "C:/Program Files (x86)/Steam",
+		"steam/../steam/steamapps":      "steam/steamapps",
+		"//server/share/Steam/":         "UNC/server/share/Steam",
+		"///home/user":                  "home/user",
+		"/":                             ".",
+		"":                              ".",
+	}
+	for p, expected := range tests {
+		if n := Name(p); n != expected {
+			t.Errorf("Name(%q): expected %q, got %q", p, expected, n)
+		}
+	}
+}
+
+func TestOS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "steamapps", "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "steamapps", "appmanifest_730.acf"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := Name(Abs(dir))
+	sub, err := fs.Sub(OS(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "steamapps/appmanifest_730.acf", "steamapps/common"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := OS().Open("/" + root); err == nil {
+		t.Error("expected an invalid name to be rejected")
+	}
+}
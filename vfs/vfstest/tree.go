@@ -0,0 +1,46 @@
+// Package vfstest provides in-memory file systems for the tests of packages
+// built on vfs.
+package vfstest
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing/fstest"
+
+	"github.com/ajmadsen/replayanalyzer/vfs"
+)
+
+// Tree builds an in-memory file system for tests from a list of entries.
+// An entry is "D" followed by a directory name, or "F" followed by a file
+// name and optionally a newline and the file's contents:
+//
+//	vfstest.Tree(
+//		"Dsteam/steamapps",
+//		"Fsteam/steamapps/appmanifest_730.acf\n\"AppState\" { ... }",
+//	)
+//
+// Parent directories are implied. The result can be edited further, for
+// example to set modification times.
+func Tree(entries ...string) fstest.MapFS {
+	m := fstest.MapFS{}
+	for _, e := range entries {
+		if e == "" {
+			panic("vfstest: empty tree entry")
+		}
+		t, e := e[0], e[1:]
+		switch t {
+		case 'D':
+			m[vfs.Name(e)] = &fstest.MapFile{Mode: fs.ModeDir | 0755}
+		case 'F':
+			name, contents := e, ""
+			if nl := strings.Index(e, "\n"); nl >= 0 {
+				name, contents = e[:nl], e[nl+1:]
+			}
+			m[vfs.Name(name)] = &fstest.MapFile{Data: []byte(contents), Mode: 0644}
+		default:
+			panic(fmt.Sprintf("vfstest: invalid tree entry type %q", t))
+		}
+	}
+	return m
+}
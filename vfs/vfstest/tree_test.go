@@ -0,0 +1,22 @@
+package vfstest
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTree(t *testing.T) {
+	fsys := Tree(
+		"Dsteam/steamapps/common",
+		"Fsteam/steamapps/appmanifest_730.acf\n\"AppState\"\n{\n}",
+		"Fsteam/config/config.vdf",
+	)
+	if err := fstest.TestFS(fsys, "steam/steamapps/common", "steam/steamapps/appmanifest_730.acf", "steam/config/config.vdf"); err != nil {
+		t.Error(err)
+	}
+	b, err := fs.ReadFile(fsys, "steam/steamapps/appmanifest_730.acf")
+	if err != nil || string(b) != "\"AppState\"\n{\n}" {
+		t.Errorf("unexpected contents %q: %v", b, err)
+	}
+}